@@ -21,3 +21,15 @@ type Bus interface {
 // ErrClosed indicates the bus or endpoint has been closed.
 var ErrClosed = errors.New("canbus: closed")
 
+// BatchReceiver is implemented by buses that can return several frames per
+// call, such as SocketCAN via recvmmsg. ReceiveBatch blocks until at least
+// one frame is available and returns the number of frames written.
+type BatchReceiver interface {
+	ReceiveBatch(frames []Frame) (int, error)
+}
+
+// InterfaceReceiver is implemented by buses that can report the interface a
+// frame arrived on, such as a SocketCAN socket bound to all interfaces.
+type InterfaceReceiver interface {
+	ReceiveFrom() (Frame, string, error)
+}
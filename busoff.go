@@ -0,0 +1,142 @@
+package canbus
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BusOffEventKind identifies a step of bus-off recovery.
+type BusOffEventKind uint8
+
+const (
+	BusOffDetected      BusOffEventKind = iota // a bus-off error frame was received
+	BusOffRestartFailed                        // a restart attempt returned an error
+	BusOffRestarted                            // the interface was restarted
+	BusOffGaveUp                               // MaxAttempts restarts failed
+)
+
+func (k BusOffEventKind) String() string {
+	switch k {
+	case BusOffDetected:
+		return "detected"
+	case BusOffRestartFailed:
+		return "restart failed"
+	case BusOffRestarted:
+		return "restarted"
+	case BusOffGaveUp:
+		return "gave up"
+	default:
+		return fmt.Sprintf("BusOffEventKind(%d)", uint8(k))
+	}
+}
+
+// BusOffEvent reports the progress of a BusOffRecoverer.
+type BusOffEvent struct {
+	Kind    BusOffEventKind
+	Iface   string
+	Attempt int   // restart attempt, starting at 1; 0 for BusOffDetected
+	Err     error // restart error for BusOffRestartFailed and BusOffGaveUp
+}
+
+// BusOffRecoveryOptions configures a BusOffRecoverer.
+type BusOffRecoveryOptions struct {
+	// Backoff is the delay before each restart attempt; attempt n waits
+	// Backoff.Delay(n-1).
+	Backoff BackoffPolicy
+	// MaxAttempts bounds the restarts tried for one bus-off; zero retries
+	// until a restart succeeds or the context is cancelled.
+	MaxAttempts int
+	// Restart restarts the interface; nil uses RestartCANInterface.
+	Restart func(iface string) error
+	// OnEvent, if set, is called synchronously for every recovery event.
+	OnEvent func(BusOffEvent)
+}
+
+// BusOffRecoverer watches error frames for bus-off and restarts the CAN
+// interface, as operators of unattended gateways would by hand. The mux
+// must read from a SocketCAN bus whose ErrorMask includes ErrorClassBusOff,
+// otherwise no bus-off frames are delivered. It is an alternative to the
+// kernel's restart-ms (see LinuxCANInterfaceOptions.RestartMs) that adds
+// backoff, attempt limits and event reporting.
+type BusOffRecoverer struct {
+	iface string
+	mux   *Mux
+	opts  BusOffRecoveryOptions
+}
+
+// NewBusOffRecoverer returns a recoverer for iface fed by mux. Call Run to
+// start it.
+func NewBusOffRecoverer(iface string, mux *Mux, opts BusOffRecoveryOptions) *BusOffRecoverer {
+	if opts.Restart == nil {
+		opts.Restart = RestartCANInterface
+	}
+	return &BusOffRecoverer{iface: iface, mux: mux, opts: opts}
+}
+
+// isBusOff matches error frames reporting bus-off.
+func isBusOff(f Frame) bool {
+	return f.Error && ErrorClass(f.ID).Has(ErrorClassBusOff)
+}
+
+// Run watches for bus-off until ctx is done, returning ctx.Err(). It
+// returns early with an error wrapping ErrClosed if the mux closes, or with
+// the last restart error once MaxAttempts restarts of one bus-off fail.
+// Bus-off frames received while a restart is in progress are treated as
+// part of the same event.
+func (r *BusOffRecoverer) Run(ctx context.Context) error {
+	ch, cancel := r.mux.Subscribe(isBusOff, 16)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-ch:
+			if !ok {
+				return fmt.Errorf("%w: bus-off recovery for %s: %w", ErrClosed, r.iface, r.mux.Err())
+			}
+		}
+		r.emit(BusOffEvent{Kind: BusOffDetected, Iface: r.iface})
+		if err := r.recover(ctx); err != nil {
+			return err
+		}
+		// Drop bus-off reports that queued up during the restart.
+		for drained := false; !drained; {
+			select {
+			case <-ch:
+			default:
+				drained = true
+			}
+		}
+	}
+}
+
+// recover restarts the interface with backoff until it succeeds.
+func (r *BusOffRecoverer) recover(ctx context.Context) error {
+	for attempt := 1; r.opts.MaxAttempts <= 0 || attempt <= r.opts.MaxAttempts; attempt++ {
+		t := time.NewTimer(r.opts.Backoff.Delay(attempt - 1))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		err := r.opts.Restart(r.iface)
+		if err == nil {
+			r.emit(BusOffEvent{Kind: BusOffRestarted, Iface: r.iface, Attempt: attempt})
+			return nil
+		}
+		r.emit(BusOffEvent{Kind: BusOffRestartFailed, Iface: r.iface, Attempt: attempt, Err: err})
+		if attempt == r.opts.MaxAttempts {
+			r.emit(BusOffEvent{Kind: BusOffGaveUp, Iface: r.iface, Attempt: attempt, Err: err})
+			return fmt.Errorf("canbus: bus-off recovery for %s gave up after %d attempts: %w", r.iface, attempt, err)
+		}
+	}
+	return nil
+}
+
+func (r *BusOffRecoverer) emit(e BusOffEvent) {
+	if r.opts.OnEvent != nil {
+		r.opts.OnEvent(e)
+	}
+}
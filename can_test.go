@@ -2,7 +2,14 @@ package canbus
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -74,6 +81,66 @@ func TestFrame_Validate_Marshal_Unmarshal_String(t *testing.T) {
 	}
 }
 
+func TestNewLoopbackPair(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	if err := a.Send(MustFrame(0x1, []byte{1})); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := b.Receive(); err != nil || f.ID != 0x1 {
+		t.Fatalf("receive: %v %v", f, err)
+	}
+	closeFn()
+	if err := a.Send(MustFrame(0x1, nil)); !errors.Is(err, ErrClosed) {
+		t.Fatalf("send after close: %v", err)
+	}
+	if _, err := b.Receive(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("receive after close: %v", err)
+	}
+}
+
+func TestLoopbackBus_Tap(t *testing.T) {
+	bus := NewLoopbackBus()
+	tap, cancel := bus.Tap()
+	a, b := bus.Open(), bus.Open()
+	var wg sync.WaitGroup
+	for _, ep := range []Bus{a, b} {
+		wg.Add(1)
+		go func(ep Bus) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				_ = ep.Send(MustFrame(uint32(i), nil))
+			}
+		}(ep)
+	}
+	for _, ep := range []Bus{a, b} {
+		for i := 0; i < 10; i++ {
+			if _, err := ep.Receive(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	wg.Wait()
+	for i := 0; i < 20; i++ {
+		select {
+		case <-tap:
+		case <-time.After(time.Second):
+			t.Fatalf("tap saw %d of 20 frames", i)
+		}
+	}
+	cancel()
+	if _, ok := <-tap; ok {
+		t.Fatal("tap channel should be closed after cancel")
+	}
+	cancel()
+
+	// Closing the bus closes remaining taps.
+	tap2, _ := bus.Tap()
+	bus.Close()
+	if _, ok := <-tap2; ok {
+		t.Fatal("tap channel should be closed with the bus")
+	}
+}
+
 func TestLoopbackBus_SendReceive_MultiEndpoint(t *testing.T) {
 	bus := NewLoopbackBus()
 	defer bus.Close()
@@ -160,6 +227,13 @@ func TestFilters_Basics(t *testing.T) {
 	if !ExtendedOnly()(f3) || ExtendedOnly()(f1) {
 		t.Fatalf("ExtendedOnly failure")
 	}
+	ext := Frame{ID: 0x100, Extended: true}
+	if !ByStandardID(0x100)(f1) || ByStandardID(0x100)(ext) || ByStandardID(0x100)(f2) {
+		t.Fatalf("ByStandardID failure")
+	}
+	if !ByExtendedID(0x100)(ext) || ByExtendedID(0x100)(f1) || !ByExtendedID(0x1ABCDEFF)(f3) {
+		t.Fatalf("ByExtendedID failure")
+	}
 	data := f1
 	data.RTR = false
 	if !DataOnly()(data) {
@@ -179,6 +253,79 @@ func TestFilters_Basics(t *testing.T) {
 	if Not(ByID(0x100))(f1) || !Not(ByID(0x999))(f1) {
 		t.Fatalf("Not failure")
 	}
+
+	// Identifier filters ignore error frames, whose ID holds class bits.
+	busOff := Frame{ID: uint32(ErrorClassBusOff), Error: true, Len: 8}
+	for name, flt := range map[string]FrameFilter{
+		"ByID":         ByID(0x040),
+		"ByStandardID": ByStandardID(0x040),
+		"ByExtendedID": ByExtendedID(0x040),
+		"ByIDs":        ByIDs(0x040),
+		"ByRange":      ByRange(0, 0x1FFFFFFF),
+		"ByMask":       ByMask(0, 0),
+		"StandardOnly": StandardOnly(),
+		"ExtendedOnly": ExtendedOnly(),
+	} {
+		if flt(busOff) {
+			t.Fatalf("%s matched an error frame", name)
+		}
+	}
+}
+
+func TestFilters_DataContent(t *testing.T) {
+	f := MustFrame(0x181, []byte{0x43, 0x00, 0x10, 0x00})
+	if !ByByteAt(0, 0x43)(f) || !ByByteAt(3, 0x00)(f) || ByByteAt(1, 0x01)(f) {
+		t.Fatalf("ByByteAt failure")
+	}
+	if ByByteAt(4, 0x00)(f) || ByByteAt(-1, 0x43)(f) {
+		t.Fatalf("ByByteAt should not match past the payload")
+	}
+	if !ByDataPrefix([]byte{0x43, 0x00})(f) || ByDataPrefix([]byte{0x43, 0x01})(f) {
+		t.Fatalf("ByDataPrefix failure")
+	}
+	if ByDataPrefix([]byte{0x43, 0x00, 0x10, 0x00, 0x00})(f) {
+		t.Fatalf("ByDataPrefix should not match a longer prefix")
+	}
+	if !ByDataPrefix(nil)(MustFrame(0x181, nil)) {
+		t.Fatalf("empty prefix should match")
+	}
+	// 0x43 is an expedited SDO upload response, scs=2.
+	if !ByCommandSpecifier(2)(f) || ByCommandSpecifier(3)(f) || ByCommandSpecifier(2)(MustFrame(0x181, nil)) {
+		t.Fatalf("ByCommandSpecifier failure")
+	}
+	rtr := Frame{ID: 0x181, RTR: true, Len: 4}
+	if ByByteAt(0, 0)(rtr) || ByDataPrefix([]byte{0})(rtr) || ByCommandSpecifier(0)(rtr) {
+		t.Fatalf("RTR frames carry no data and should not match")
+	}
+}
+
+func TestFilters_AllOfAnyOf(t *testing.T) {
+	f := MustFrame(0x185, []byte{1, 2})
+	calls := 0
+	counting := func(m bool) FrameFilter {
+		return func(Frame) bool { calls++; return m }
+	}
+
+	all := AllOf(StandardOnly(), nil, ByRange(0x180, 0x1FF), DataOnly(), LenExactly(2))
+	if !all(f) || all(MustFrame(0x285, []byte{1, 2})) || all(MustFrame(0x185, []byte{1})) {
+		t.Fatalf("AllOf failure")
+	}
+	if AllOf(counting(false), counting(true))(f) || calls != 1 {
+		t.Fatalf("AllOf should stop at the first mismatch, calls=%d", calls)
+	}
+
+	calls = 0
+	oneOf := AnyOf(ByID(0x100), nil, ByID(0x185))
+	if !oneOf(f) || oneOf(MustFrame(0x186, nil)) {
+		t.Fatalf("AnyOf failure")
+	}
+	if !AnyOf(counting(true), counting(false))(f) || calls != 1 {
+		t.Fatalf("AnyOf should stop at the first match, calls=%d", calls)
+	}
+
+	if AllOf() != nil || AllOf(nil, nil) != nil || AnyOf() != nil || AnyOf(nil) != nil {
+		t.Fatalf("empty compositions should be nil")
+	}
 }
 
 func TestMux_Subscribe_Filtering_And_Close(t *testing.T) {
@@ -262,3 +409,1765 @@ func ExampleLoopbackBus() {
 	fmt.Printf("ID=%03X LEN=%d DATA=%x\n", f.ID, f.Len, f.Data[:f.Len])
 	// Output: ID=123 LEN=2 DATA=6869
 }
+
+func TestFrame_MarshalText_RoundTrip(t *testing.T) {
+	cases := []struct {
+		frame Frame
+		text  string
+	}{
+		{MustFrame(0x123, []byte{0xDE, 0xAD}), "123#DEAD"},
+		{MustFrame(0x7, nil), "007#"},
+		{Frame{ID: 0x1ABCDEFF, Extended: true, Len: 1, Data: [8]byte{0x01}}, "1ABCDEFF#01"},
+		{Frame{ID: 0x12, Extended: true}, "00000012#"},
+		{Frame{ID: 0x123, RTR: true}, "123#R"},
+		{Frame{ID: 0x1ABCDEFF, Extended: true, RTR: true, Len: 4}, "1ABCDEFF#R4"},
+		{Frame{ID: 0x040, Error: true, Len: 8}, "20000040#0000000000000000"},
+	}
+	for _, tc := range cases {
+		b, err := tc.frame.MarshalText()
+		if err != nil {
+			t.Fatalf("%v: MarshalText() error = %v", tc.frame, err)
+		}
+		if string(b) != tc.text {
+			t.Fatalf("MarshalText() = %q, want %q", b, tc.text)
+		}
+		var g Frame
+		if err := g.UnmarshalText(b); err != nil {
+			t.Fatalf("%q: UnmarshalText() error = %v", b, err)
+		}
+		if g != tc.frame {
+			t.Fatalf("%q: roundtrip mismatch: got %+v want %+v", b, g, tc.frame)
+		}
+	}
+
+	var g Frame
+	if err := g.UnmarshalText([]byte("123#DE.AD.BE")); err != nil || g.Len != 3 || g.Data[2] != 0xBE {
+		t.Fatalf("dotted data: %+v err=%v", g, err)
+	}
+	for _, bad := range []string{"123", "1234#00", "800#", "123#ABC", "123#001122334455667788", "123#R9", "12G#"} {
+		if err := g.UnmarshalText([]byte(bad)); err == nil {
+			t.Fatalf("UnmarshalText(%q) should fail", bad)
+		}
+	}
+}
+
+func TestMustStandardAndExtendedFrame(t *testing.T) {
+	std := MustStandardFrame(0x123, []byte{1, 2})
+	if std.Extended || std.ID != 0x123 || std.Len != 2 {
+		t.Fatalf("standard frame mismatch: %+v", std)
+	}
+	ext := MustExtendedFrame(0x12, []byte{1})
+	if !ext.Extended || ext.ID != 0x12 || ext.Len != 1 {
+		t.Fatalf("extended frame mismatch: %+v", ext)
+	}
+
+	mustPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatalf("%s should panic", name)
+			}
+		}()
+		fn()
+	}
+	mustPanic("standard id > 0x7FF", func() { MustStandardFrame(0x800, nil) })
+	mustPanic("extended id > 29 bits", func() { MustExtendedFrame(0x20000000, nil) })
+	mustPanic("standard len > 8", func() { MustStandardFrame(0x1, make([]byte, 9)) })
+	mustPanic("extended len > 8", func() { MustExtendedFrame(0x1, make([]byte, 9)) })
+}
+
+func TestMux_Snapshot(t *testing.T) {
+	bus := NewLoopbackBus()
+	defer bus.Close()
+
+	plain := NewMux(bus.Open())
+	defer plain.Close()
+	if plain.Snapshot() != nil {
+		t.Fatalf("snapshot should be nil without WithStats")
+	}
+
+	m := NewMux(bus.Open(), WithStats(2))
+	defer m.Close()
+	all, cancel := m.Subscribe(nil, 8)
+	defer cancel()
+
+	producer := bus.Open()
+	defer producer.Close()
+	for _, f := range []Frame{
+		MustFrame(0x100, []byte{1}),
+		MustFrame(0x100, []byte{2}),
+		MustFrame(0x200, nil),
+		MustFrame(0x300, nil), // beyond the 2-identifier bound
+	} {
+		if err := producer.Send(f); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case <-all:
+		case <-time.After(200 * time.Millisecond):
+			t.Fatalf("timeout waiting for frame %d", i)
+		}
+	}
+
+	snap := m.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("expected 2 tracked ids, got %d", len(snap))
+	}
+	st := snap[0x100]
+	if st.Count != 2 || st.Last.Data[0] != 2 || st.LastSeen.IsZero() {
+		t.Fatalf("0x100 stat mismatch: %+v", st)
+	}
+	if snap[0x200].Count != 1 {
+		t.Fatalf("0x200 stat mismatch: %+v", snap[0x200])
+	}
+	if _, ok := snap[0x300]; ok {
+		t.Fatalf("0x300 should not be tracked")
+	}
+}
+
+func TestLoopbackBus_SendReport(t *testing.T) {
+	bus := NewLoopbackBus()
+	defer bus.Close()
+
+	a := bus.Open()
+	b := bus.Open()
+	defer a.Close()
+	defer b.Close()
+
+	r, ok := a.(DeliveryReporter)
+	if !ok {
+		t.Fatalf("loopback endpoint should implement DeliveryReporter")
+	}
+	rep, err := r.SendReport(MustFrame(0x1, nil))
+	if err != nil {
+		t.Fatalf("send report: %v", err)
+	}
+	if rep.Delivered != 1 || rep.Closed != 0 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+
+	_ = b.Close()
+	rep, err = r.SendReport(MustFrame(0x1, nil))
+	if err != nil {
+		t.Fatalf("send report: %v", err)
+	}
+	if rep.Delivered != 0 {
+		t.Fatalf("frame should reach no live endpoint: %+v", rep)
+	}
+}
+
+func TestLoopbackBus_OpenBuffered(t *testing.T) {
+	// Drop mode: a full buffer discards frames and counts them.
+	bus := NewLoopbackBusWithOptions(LoopbackOptions{DropWhenFull: true})
+	defer bus.Close()
+	tx := bus.Open()
+	rx := bus.OpenBuffered(2)
+	r := tx.(DeliveryReporter)
+	var dropped int
+	for i := 0; i < 5; i++ {
+		rep, err := r.SendReport(MustFrame(uint32(i), nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dropped += rep.Dropped
+	}
+	if got := rx.(DropCounter).Dropped(); got != 3 || dropped != 3 {
+		t.Fatalf("dropped: counter=%d reports=%d, want 3", got, dropped)
+	}
+	for i := 0; i < 2; i++ {
+		if f, err := rx.Receive(); err != nil || f.ID != uint32(i) {
+			t.Fatalf("receive %d: %v %v", i, f, err)
+		}
+	}
+
+	// Block mode (default): a full buffer applies backpressure to the sender.
+	bbus := NewLoopbackBus()
+	defer bbus.Close()
+	btx := bbus.Open()
+	brx := bbus.OpenBuffered(1)
+	if err := btx.Send(MustFrame(0x1, nil)); err != nil {
+		t.Fatal(err)
+	}
+	sent := make(chan struct{})
+	go func() {
+		_ = btx.Send(MustFrame(0x2, nil))
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("send should block while the receiver buffer is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if _, err := brx.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("send did not resume after the receiver drained")
+	}
+	if got := brx.(DropCounter).Dropped(); got != 0 {
+		t.Fatalf("block mode dropped %d frames", got)
+	}
+}
+
+func TestLoopbackBus_Arbitration(t *testing.T) {
+	bus := NewLoopbackBusWithOptions(LoopbackOptions{Arbitration: true, ArbitrationWindow: 50 * time.Millisecond})
+	defer bus.Close()
+	rx := bus.Open()
+	frames := []Frame{
+		MustFrame(0x700, nil),
+		{ID: 0x100 << 18, Extended: true},
+		{ID: 0x100, RTR: true},
+		MustFrame(0x100, nil),
+		MustFrame(0x080, nil),
+	}
+	errs := make(chan error, len(frames))
+	for _, f := range frames {
+		tx := bus.Open()
+		go func(f Frame) { errs <- tx.Send(f) }(f)
+	}
+	for range frames {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []Frame{frames[4], frames[3], frames[2], frames[1], frames[0]}
+	for i, w := range want {
+		f, err := rx.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !f.Equal(w) {
+			t.Fatalf("frame %d = %v, want %v", i, f, w)
+		}
+	}
+
+	// Close releases sends still waiting for their round.
+	slow := NewLoopbackBusWithOptions(LoopbackOptions{Arbitration: true, ArbitrationWindow: time.Hour})
+	tx := slow.Open()
+	done := make(chan error, 1)
+	go func() { done <- tx.Send(MustFrame(0x1, nil)) }()
+	time.Sleep(10 * time.Millisecond)
+	slow.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("send after close: %v, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("send not released by Close")
+	}
+}
+
+func TestLoopbackBus_ArbitrationResponder(t *testing.T) {
+	// An endpoint that replies from its receive loop must not stall the
+	// arbiter once its buffer fills.
+	for _, arb := range []bool{false, true} {
+		bus := NewLoopbackBusWithOptions(LoopbackOptions{Arbitration: arb})
+		tx := bus.Open()
+		echo := bus.OpenBuffered(1)
+		go func() {
+			for {
+				f, err := echo.Receive()
+				if err != nil {
+					return
+				}
+				f.ID++
+				if echo.Send(f) != nil {
+					return
+				}
+			}
+		}()
+		const n = 200
+		go func() {
+			for i := 0; i < n; i++ {
+				if tx.Send(MustFrame(0x100, []byte{byte(i)})) != nil {
+					return
+				}
+			}
+		}()
+		replies := make(chan Frame)
+		go func() {
+			for {
+				f, err := tx.Receive()
+				if err != nil {
+					return
+				}
+				replies <- f
+			}
+		}()
+		for i := 0; i < n; i++ {
+			select {
+			case f := <-replies:
+				if f.ID != 0x101 || f.Data[0] != byte(i) {
+					t.Fatalf("arbitration %v: reply %d = %v", arb, i, f)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("arbitration %v: stalled after %d replies", arb, i)
+			}
+		}
+		_ = bus.Close()
+	}
+}
+
+func TestLoopbackBus_Delay(t *testing.T) {
+	var mu sync.Mutex
+	var slept []time.Duration
+	bus := NewLoopbackBusWithOptions(LoopbackOptions{
+		MinDelay: 2 * time.Millisecond,
+		MaxDelay: 5 * time.Millisecond,
+		Sleep: func(d time.Duration) {
+			mu.Lock()
+			slept = append(slept, d)
+			mu.Unlock()
+		},
+	})
+	defer bus.Close()
+	tx, rx := bus.Open(), bus.Open()
+	for i := 0; i < 20; i++ {
+		if err := tx.Send(MustFrame(uint32(i), nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if f, err := rx.Receive(); err != nil || f.ID != uint32(i) {
+			t.Fatalf("receive %d: %v %v", i, f, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(slept) != 20 {
+		t.Fatalf("slept %d times, want 20", len(slept))
+	}
+	for _, d := range slept {
+		if d < 2*time.Millisecond || d > 5*time.Millisecond {
+			t.Fatalf("delay %v outside [2ms, 5ms]", d)
+		}
+	}
+
+	// Real clock: delivery is held back by at least MinDelay.
+	slow := NewLoopbackBusWithOptions(LoopbackOptions{MinDelay: 20 * time.Millisecond})
+	defer slow.Close()
+	stx, srx := slow.Open(), slow.Open()
+	start := time.Now()
+	if err := stx.Send(MustFrame(0x1, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srx.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	if el := time.Since(start); el < 20*time.Millisecond {
+		t.Fatalf("delivered after %v, want >= 20ms", el)
+	}
+}
+
+func TestLoopbackBus_InjectFault(t *testing.T) {
+	var calls atomic.Int32
+	bus := NewLoopbackBusWithOptions(LoopbackOptions{
+		InjectFault: func(f Frame) (Frame, bool) {
+			if calls.Add(1) == 1 {
+				return f, false // lost for the first destination
+			}
+			f.Data[0] ^= 0xFF // corrupted for the second
+			return f, true
+		},
+	})
+	defer bus.Close()
+	tx := bus.Open()
+	rx1, rx2 := bus.Open(), bus.Open()
+	rep, err := tx.(DeliveryReporter).SendReport(MustFrame(0x10, []byte{0x0F}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Delivered != 1 || rep.Lost != 1 || calls.Load() != 2 {
+		t.Fatalf("report %+v after %d calls", rep, calls.Load())
+	}
+	got := make(chan Frame, 2)
+	for _, rx := range []Bus{rx1, rx2} {
+		go func(rx Bus) {
+			if f, err := rx.Receive(); err == nil {
+				got <- f
+			}
+		}(rx)
+	}
+	select {
+	case f := <-got:
+		if f.Data[0] != 0xF0 {
+			t.Fatalf("expected corrupted payload, got %v", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no frame delivered")
+	}
+	select {
+	case f := <-got:
+		t.Fatalf("dropped copy was delivered: %v", f)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestLoopbackBus_RespondToRTR(t *testing.T) {
+	bus := NewLoopbackBus()
+	defer bus.Close()
+	master := bus.Open()
+	node := bus.Open()
+	node.(RTRResponder).RespondToRTR(0x705, MustFrame(0x705, []byte{0x05}))
+
+	// A data frame with the same identifier is not answered.
+	if err := master.Send(MustFrame(0x705, []byte{0x00})); err != nil {
+		t.Fatal(err)
+	}
+	if err := master.Send(Frame{ID: 0x705, RTR: true, Len: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if f, err := node.Receive(); err != nil || f.ID != 0x705 {
+			t.Fatalf("node receive %d: %v %v", i, f, err)
+		}
+	}
+	got := make(chan Frame, 1)
+	go func() {
+		if f, err := master.Receive(); err == nil {
+			got <- f
+		}
+	}()
+	select {
+	case f := <-got:
+		if f.RTR || f.Len != 1 || f.Data[0] != 0x05 {
+			t.Fatalf("unexpected reply %v", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no RTR reply")
+	}
+
+	// An extended remote frame with the same numeric id does not match.
+	if err := master.Send(Frame{ID: 0x705, Extended: true, RTR: true}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if f, err := master.Receive(); err == nil {
+			got <- f
+		}
+	}()
+	select {
+	case f := <-got:
+		t.Fatalf("unexpected reply to extended request: %v", f)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// A request dropped for a full endpoint is not answered.
+	lossy := NewLoopbackBusWithOptions(LoopbackOptions{DropWhenFull: true})
+	defer lossy.Close()
+	req := lossy.Open()
+	busy := lossy.OpenBuffered(0)
+	busy.(RTRResponder).RespondToRTR(0x705, MustFrame(0x705, []byte{0x05}))
+	rep, err := req.(DeliveryReporter).SendReport(Frame{ID: 0x705, RTR: true})
+	if err != nil || rep.Dropped != 1 {
+		t.Fatalf("SendReport = %+v, %v", rep, err)
+	}
+	go func() {
+		if f, err := req.Receive(); err == nil {
+			got <- f
+		}
+	}()
+	select {
+	case f := <-got:
+		t.Fatalf("unexpected reply to dropped request: %v", f)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestReconnectingBus(t *testing.T) {
+	lb := NewLoopbackBus()
+	defer lb.Close()
+	peer := lb.Open()
+	var mu sync.Mutex
+	var dials int
+	var eps []Bus
+	dial := func() (Bus, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		if dials == 1 {
+			return nil, errors.New("adapter not present")
+		}
+		ep := lb.Open()
+		eps = append(eps, ep)
+		return ep, nil
+	}
+	bus := NewReconnectingBus(dial, BackoffPolicy{Initial: time.Millisecond})
+
+	// The first dial fails; Send retries and succeeds on the second.
+	if err := bus.Send(MustFrame(0x1, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := peer.Receive(); err != nil || f.ID != 0x1 {
+		t.Fatalf("peer receive: %v %v", f, err)
+	}
+
+	// Simulate an unplug: the underlying endpoint dies under Receive.
+	got := make(chan Frame, 1)
+	go func() {
+		if f, err := bus.Receive(); err == nil {
+			got <- f
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	eps[0].Close()
+	mu.Unlock()
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(eps)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("bus did not reconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := peer.Send(MustFrame(0x2, nil)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case f := <-got:
+		if f.ID != 0x2 {
+			t.Fatalf("unexpected frame %v", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("receive did not resume after reconnect")
+	}
+
+	if err := bus.Send(Frame{ID: 0x800}); err == nil || errors.Is(err, ErrClosed) {
+		t.Fatalf("invalid frame: %v", err)
+	}
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Send(MustFrame(0x3, nil)); !errors.Is(err, ErrClosed) {
+		t.Fatalf("send after close: %v", err)
+	}
+	if _, err := bus.Receive(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("receive after close: %v", err)
+	}
+}
+
+func TestBackoffPolicy_Delay(t *testing.T) {
+	p := BackoffPolicy{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := p.Delay(i); got != w*time.Millisecond {
+			t.Fatalf("Delay(%d) = %v, want %v", i, got, w*time.Millisecond)
+		}
+	}
+	if got := (BackoffPolicy{}).Delay(0); got != 100*time.Millisecond {
+		t.Fatalf("zero policy Delay(0) = %v", got)
+	}
+}
+
+func TestMeteredBus(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	ma, m := NewMeteredBus(a)
+	if err := ma.Send(MustFrame(0x181, []byte{1, 2, 3})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Send(MustFrame(0x705, []byte{5})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ma.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ma.Send(Frame{ID: 0x800}); err == nil {
+		t.Fatal("expected invalid frame error")
+	}
+	closeFn()
+	if _, err := ma.Receive(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("receive after close: %v", err)
+	}
+	checks := []struct {
+		name      string
+		got, want uint64
+	}{
+		{"FramesSent", m.FramesSent.Load(), 1},
+		{"FramesReceived", m.FramesReceived.Load(), 1},
+		{"BytesSent", m.BytesSent.Load(), 3},
+		{"BytesReceived", m.BytesReceived.Load(), 1},
+		{"SendErrors", m.SendErrors.Load(), 1},
+		{"ReceiveErrors", m.ReceiveErrors.Load(), 1},
+		{"ByFunction[3]", m.ByFunction[3].Load(), 1},
+		{"ByFunction[14]", m.ByFunction[14].Load(), 1},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %d, want %d", c.name, c.got, c.want)
+		}
+	}
+	if got := FunctionBucket(Frame{ID: 0x1234<<11 | 0x705, Extended: true}); got != 14 {
+		t.Fatalf("extended bucket = %d, want 14", got)
+	}
+}
+
+func TestRecordingAndReplayBus(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	defer closeFn()
+	var log bytes.Buffer
+	rec := NewRecordingBus(a, &log)
+	if err := rec.Send(MustFrame(0x123, []byte{0xDE, 0xAD})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	in := MustFrame(0x456, []byte{1})
+	in.Timestamp = time.Unix(1705000000, 123456000)
+	if err := b.Send(in); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " tx 123#DEAD") || lines[1] != "(1705000000.123456) rx 456#01" {
+		t.Fatalf("unexpected log:\n%s", log.String())
+	}
+
+	// Replay skips sent frames and returns io.EOF at the end.
+	rb, err := NewReplayBus(strings.NewReader(log.String()), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := rb.Receive()
+	if err != nil || !f.Equal(in) || !f.Timestamp.Equal(in.Timestamp) {
+		t.Fatalf("replayed %v at %v, %v", f, f.Timestamp, err)
+	}
+	if _, err := rb.Receive(); err != io.EOF {
+		t.Fatalf("end of log: %v, want io.EOF", err)
+	}
+	if err := rb.Send(MustFrame(0x1, nil)); err != nil {
+		t.Fatalf("send on replay: %v", err)
+	}
+	rb.Close()
+	if _, err := rb.Receive(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("receive after close: %v", err)
+	}
+
+	// Realtime replay honours the recorded spacing.
+	timed := "(100.000000) can0 001#01\n\n(100.030000) can0 002#02\n"
+	rb, err = NewReplayBus(strings.NewReader(timed), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := rb.Receive(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if el := time.Since(start); el < 30*time.Millisecond {
+		t.Fatalf("realtime replay took %v, want >= 30ms", el)
+	}
+
+	if _, err := NewReplayBus(strings.NewReader("garbage\n"), false); err == nil {
+		t.Fatal("expected error for malformed log")
+	}
+}
+
+func TestMux_SubscribeBlocking(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	defer closeFn()
+	mux := NewMux(b)
+	defer mux.Close()
+	slow, cancelSlow := mux.SubscribeBlocking(nil, 0)
+	fast, cancelFast := mux.Subscribe(nil, 1)
+	defer cancelFast()
+
+	// The fast subscriber keeps receiving while the slow one is not read.
+	const n = 100
+	for i := 0; i < n; i++ {
+		if err := a.Send(MustFrame(uint32(i), nil)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case f := <-fast:
+			if f.ID != uint32(i) {
+				t.Fatalf("fast got %v, want id %d", f, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("fast subscriber stalled at frame %d", i)
+		}
+	}
+	// The slow subscriber receives every frame, in order.
+	for i := 0; i < n; i++ {
+		select {
+		case f := <-slow:
+			if f.ID != uint32(i) {
+				t.Fatalf("slow got %v, want id %d", f, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("slow subscriber missing frame %d", i)
+		}
+	}
+	cancelSlow()
+	select {
+	case _, ok := <-slow:
+		if ok {
+			t.Fatal("unexpected frame after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+
+	// Closing the mux closes blocking subscribers too.
+	pending, _ := mux.SubscribeBlocking(nil, 0)
+	if err := a.Send(MustFrame(0x1, nil)); err != nil {
+		t.Fatal(err)
+	}
+	mux.Close()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-pending:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("channel not closed after mux Close")
+		}
+	}
+}
+
+func TestMux_SubscribeBlockingOutboxLimit(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	defer closeFn()
+	const limit = 8
+	mux := NewMux(b, WithOutboxLimit(limit))
+	defer mux.Close()
+	stuck, cancelStuck := mux.SubscribeBlocking(nil, 0)
+	defer cancelStuck()
+	fast, cancelFast := mux.Subscribe(nil, 1)
+	defer cancelFast()
+
+	// Nobody reads stuck, so it accepts limit frames, counting the one its
+	// forwarder is blocked sending, and drops the rest.
+	const n = 50
+	for i := 0; i < n; i++ {
+		if err := a.Send(MustFrame(uint32(i), nil)); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-fast:
+		case <-time.After(time.Second):
+			t.Fatalf("fast subscriber stalled at frame %d", i)
+		}
+	}
+	if got, want := mux.TotalDropped(), uint64(n-limit); got != want {
+		t.Fatalf("TotalDropped = %d, want %d", got, want)
+	}
+	// The frames that fit are delivered in order once the reader resumes.
+	for i := 0; i < limit; i++ {
+		select {
+		case f := <-stuck:
+			if f.ID != uint32(i) {
+				t.Fatalf("got %v, want id %d", f, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing frame %d", i)
+		}
+	}
+	// Draining frees room for new frames.
+	if err := a.Send(MustFrame(0x7FF, nil)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case f := <-stuck:
+		if f.ID != 0x7FF {
+			t.Fatalf("got %v, want id 0x7FF", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("frame not delivered after draining")
+	}
+}
+
+func TestSubscriptionGroup(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	defer closeFn()
+	mux := NewMux(b)
+	defer mux.Close()
+	g := NewSubscriptionGroup(mux)
+	ch1, _ := g.Subscribe(nil, 1)
+	ch2, _ := g.SubscribeBlocking(nil, 1)
+	ch3, cancel3 := mux.Subscribe(nil, 1)
+	g.Add(cancel3)
+	other, cancelOther := mux.Subscribe(nil, 1)
+	defer cancelOther()
+
+
+	// Cancelling individually stops tracking, so the group does not grow
+	// with subscribe/cancel cycles.
+	for i := 0; i < 100; i++ {
+		_, cancel := g.Subscribe(nil, 1)
+		cancel()
+		_, cancel = g.SubscribeBlocking(nil, 1)
+		cancel()
+		_, cancel = mux.Subscribe(nil, 1)
+		g.Add(cancel)()
+	}
+	if n := g.Len(); n != 3 {
+		t.Fatalf("group tracks %d subscriptions, want 3", n)
+	}
+
+	g.CancelAll()
+	if n := g.Len(); n != 0 {
+		t.Fatalf("group tracks %d subscriptions after CancelAll", n)
+	}
+	for i, ch := range []<-chan Frame{ch1, ch2, ch3} {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Fatalf("channel %d delivered after CancelAll", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("channel %d not closed by CancelAll", i)
+		}
+	}
+	g.CancelAll()
+
+	// Subscriptions outside the group and the mux itself keep working.
+	if err := a.Send(MustFrame(0x1, nil)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case f := <-other:
+		if f.ID != 0x1 {
+			t.Fatalf("unexpected frame %v", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("unrelated subscription stopped receiving")
+	}
+	if mux.Err() != nil {
+		t.Fatalf("mux closed: %v", mux.Err())
+	}
+}
+
+func TestMux_DroppedFrames(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	defer closeFn()
+	mux := NewMux(b)
+	defer mux.Close()
+	full := mux.SubscribeWithStats(func(f Frame) bool { return f.ID < 0x100 }, 2)
+	defer full.Cancel()
+	last := mux.SubscribeWithStats(nil, 16)
+	defer last.Cancel()
+
+	for i := 0; i < 5; i++ {
+		if err := a.Send(MustFrame(uint32(i), nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A non-matching frame is never counted as dropped.
+	if err := a.Send(MustFrame(0x200, nil)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		select {
+		case <-last.C:
+		case <-time.After(time.Second):
+			t.Fatalf("frame %d not delivered", i)
+		}
+	}
+	if got := full.DroppedFrames(); got != 3 {
+		t.Fatalf("full.DroppedFrames() = %d, want 3", got)
+	}
+	if got := last.DroppedFrames(); got != 0 {
+		t.Fatalf("last.DroppedFrames() = %d, want 0", got)
+	}
+	if got := mux.TotalDropped(); got != 3 {
+		t.Fatalf("TotalDropped() = %d, want 3", got)
+	}
+}
+
+func TestMux_SubscribeOnce(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	defer closeFn()
+	mux := NewMux(b)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = a.Send(MustFrame(0x100, nil))
+		_ = a.Send(MustFrame(0x181, []byte{1}))
+	}()
+	f, err := mux.SubscribeOnce(context.Background(), func(f Frame) bool { return f.ID == 0x181 })
+	if err != nil || f.ID != 0x181 {
+		t.Fatalf("SubscribeOnce: %v %v", f, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := mux.SubscribeOnce(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("timeout: %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mux.Close()
+	}()
+	if _, err := mux.SubscribeOnce(context.Background(), nil); !errors.Is(err, ErrClosed) || !errors.Is(err, ErrMuxClosed) {
+		t.Fatalf("closed mux: %v", err)
+	}
+}
+
+func TestBusOffRecoverer(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	defer closeFn()
+	mux := NewMux(b)
+	defer mux.Close()
+	events := make(chan BusOffEvent, 16)
+	var restarts atomic.Int32
+	r := NewBusOffRecoverer("can0", mux, BusOffRecoveryOptions{
+		Backoff:     BackoffPolicy{Initial: time.Millisecond},
+		MaxAttempts: 3,
+		Restart: func(iface string) error {
+			if restarts.Add(1) == 1 {
+				return errors.New("device busy")
+			}
+			return nil
+		},
+		OnEvent: func(e BusOffEvent) { events <- e },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+	time.Sleep(10 * time.Millisecond)
+
+	// Controller warnings are not bus-off and are ignored.
+	if err := a.Send(Frame{ID: uint32(ErrorClassController), Error: true, Len: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Send(Frame{ID: uint32(ErrorClassBusOff), Error: true, Len: 8}); err != nil {
+		t.Fatal(err)
+	}
+	want := []BusOffEvent{
+		{Kind: BusOffDetected, Iface: "can0"},
+		{Kind: BusOffRestartFailed, Iface: "can0", Attempt: 1},
+		{Kind: BusOffRestarted, Iface: "can0", Attempt: 2},
+	}
+	for i, w := range want {
+		select {
+		case e := <-events:
+			if e.Kind != w.Kind || e.Iface != w.Iface || e.Attempt != w.Attempt || (e.Err != nil) != (w.Kind == BusOffRestartFailed) {
+				t.Fatalf("event %d = %+v, want %+v", i, e, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing event %d (%v)", i, w.Kind)
+		}
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run after cancel: %v", err)
+	}
+
+	// Every restart failing ends Run after MaxAttempts.
+	failing := NewBusOffRecoverer("can0", mux, BusOffRecoveryOptions{
+		Backoff:     BackoffPolicy{Initial: time.Millisecond},
+		MaxAttempts: 2,
+		Restart:     func(string) error { return errors.New("no such device") },
+	})
+	go func() { done <- failing.Run(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	if err := a.Send(Frame{ID: uint32(ErrorClassBusOff), Error: true, Len: 8}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "gave up after 2 attempts") {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not give up")
+	}
+}
+
+func TestMux_UpdateFilter(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	defer closeFn()
+	mux := NewMux(b)
+	defer mux.Close()
+	nodes := func(ids ...uint32) FrameFilter {
+		return func(f Frame) bool {
+			for _, id := range ids {
+				if f.ID == id {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	sub := mux.SubscribeWithStats(nodes(0x701), 16)
+	defer sub.Cancel()
+	all, cancelAll := mux.Subscribe(nil, 16)
+	defer cancelAll()
+
+	send := func(id uint32) {
+		t.Helper()
+		if err := a.Send(MustFrame(id, []byte{0x05})); err != nil {
+			t.Fatal(err)
+		}
+		// Wait until the mux has dispatched the frame.
+		select {
+		case <-all:
+		case <-time.After(time.Second):
+			t.Fatal("frame not dispatched")
+		}
+	}
+	send(0x701)
+	send(0x702)
+	mux.UpdateFilter(sub, nodes(0x701, 0x702))
+	send(0x702)
+	for _, want := range []uint32{0x701, 0x702} {
+		select {
+		case f := <-sub.C:
+			if f.ID != want {
+				t.Fatalf("got %v, want id %X", f, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("missing %X", want)
+		}
+	}
+	select {
+	case f := <-sub.C:
+		t.Fatalf("unexpected frame %v", f)
+	default:
+	}
+
+	// Updating a cancelled subscription is a no-op.
+	sub.Cancel()
+	mux.UpdateFilter(sub, nil)
+}
+
+func TestMux_SendInterceptors(t *testing.T) {
+	a, b, closeFn := NewLoopbackPair()
+	defer closeFn()
+	var order []string
+	mux := NewMux(a, WithSendInterceptors(
+		func(f Frame) (Frame, bool) {
+			order = append(order, "block")
+			return f, f.ID != 0x000 // block NMT
+		},
+		func(f Frame) (Frame, bool) {
+			order = append(order, "stamp")
+			f.Data[f.Len] = 0xAA
+			f.Len++
+			return f, true
+		},
+	))
+	defer mux.Close()
+	if err := mux.Send(MustFrame(0x000, []byte{0x81, 0x00})); err != nil {
+		t.Fatalf("dropped send: %v", err)
+	}
+	if err := mux.Send(MustFrame(0x181, []byte{0x01})); err != nil {
+		t.Fatal(err)
+	}
+	f, err := b.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ID != 0x181 || f.Len != 2 || f.Data[1] != 0xAA {
+		t.Fatalf("received %v, want stamped 0x181", f)
+	}
+	if got := strings.Join(order, ","); got != "block,block,stamp" {
+		t.Fatalf("interceptor order %q", got)
+	}
+
+	// Without interceptors Send forwards unchanged.
+	c, d, closeCD := NewLoopbackPair()
+	defer closeCD()
+	plain := NewMux(c)
+	defer plain.Close()
+	if err := plain.Send(MustFrame(0x1, []byte{1})); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := d.Receive(); err != nil || !f.Equal(MustFrame(0x1, []byte{1})) {
+		t.Fatalf("plain send: %v %v", f, err)
+	}
+}
+
+func TestCompileKernelFilter(t *testing.T) {
+	// Every standard ID plus a spread of extended ones, as data frames.
+	var frames []Frame
+	for id := uint32(0); id <= 0x7FF; id++ {
+		frames = append(frames, Frame{ID: id, Len: 1})
+	}
+	for _, id := range []uint32{0, 0x7F, 0x80, 0x100, 0x185, 0x7FF, 0x800, 0x12345, 0x18FF00F9, 0x1FFFFFFF} {
+		frames = append(frames, Frame{ID: id, Extended: true, Len: 1})
+	}
+	cases := []struct {
+		name string
+		spec KernelFilterSpec
+	}{
+		{"zero", KernelFilterSpec{}},
+		{"ByID", KernelByID(0x185)},
+		{"ByID extended only", KernelByID(0x12345)},
+		{"ByIDs", KernelByIDs(0x100, 0x185, 0x100, 0x18FF00F9)},
+		{"ByStandardID", KernelByStandardID(0x185)},
+		{"ByExtendedID", KernelByExtendedID(0x185)},
+		{"ByMask", KernelByMask(0x180, 0x780)},
+		{"ByRange", KernelByRange(0x801, 0x7F)},
+		{"StandardOnly", KernelStandardOnly()},
+		{"ExtendedOnly", KernelExtendedOnly()},
+		{"AllOf", KernelAllOf(KernelByRange(0x000, 0x7FF), KernelByMask(0x180, 0x780), KernelFilterSpec{})},
+		{"AnyOf", KernelAnyOf(KernelByExtendedID(0x18FF00F9), KernelAllOf(KernelStandardOnly(), KernelByRange(0x580, 0x5FF)))},
+		{"disjoint", KernelAllOf(KernelStandardOnly(), KernelExtendedOnly())},
+	}
+	for _, tc := range cases {
+		kfs, ok := CompileKernelFilter(tc.spec)
+		if !ok {
+			t.Fatalf("%s: not translated", tc.name)
+		}
+		for _, f := range frames {
+			want := tc.spec.Match(f)
+			if want != tc.spec.Filter().Match(f) {
+				t.Fatalf("%s: Match and Filter disagree on %s", tc.name, f)
+			}
+			got := false
+			for _, k := range kfs {
+				got = got || k.Match(f)
+			}
+			if got != want {
+				t.Fatalf("%s: frame %s: kernel %v, filter %v (%+v)", tc.name, f, got, want, kfs)
+			}
+		}
+	}
+
+	if kfs, _ := CompileKernelFilter(KernelByStandardID(0x185)); len(kfs) != 1 || kfs[0] != (CANFilter{ID: 0x185, Mask: 0x7FF}) {
+		t.Fatalf("ByStandardID compiled to %+v", kfs)
+	}
+	if KernelAllOf().Filter() != nil || KernelAnyOf(KernelFilterSpec{}).Filter() != nil {
+		t.Fatalf("empty combination should be the zero spec")
+	}
+	if _, ok := CompileKernelFilter(KernelByRange(0, 0x1FFFFFFF)); !ok {
+		t.Fatalf("full range should translate")
+	}
+	many := make([]uint32, 600)
+	for i := range many {
+		many[i] = uint32(0x1000 + i)
+	}
+	if _, ok := CompileKernelFilter(KernelByIDs(many...)); ok {
+		t.Fatalf("more than 512 kernel filters should not translate")
+	}
+	var nilFilter FrameFilter
+	if !nilFilter.Match(Frame{ID: 1}) {
+		t.Fatalf("nil FrameFilter should match everything")
+	}
+}
+
+func TestScanFrames(t *testing.T) {
+	frames := []Frame{
+		MustFrame(0x123, []byte{0xDE, 0xAD}),
+		{ID: 0x1ABCDEF, Extended: true, Len: 1, Data: [8]byte{0x42}},
+		{ID: 0x7FF, RTR: true, Len: 4},
+	}
+	var capture []byte
+	for _, f := range frames {
+		b, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		capture = append(capture, b...)
+	}
+	next := ScanFrames(bytes.NewReader(capture))
+	for i, want := range frames {
+		f, err := next()
+		if err != nil || !f.Equal(want) {
+			t.Fatalf("frame %d = %v, %v; want %v", i, f, err, want)
+		}
+	}
+	if _, err := next(); err != io.EOF {
+		t.Fatalf("end of capture: %v, want io.EOF", err)
+	}
+
+	next = ScanFrames(bytes.NewReader(append(capture[:16:16], 1, 2, 3)))
+	if _, err := next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated record: %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, err := next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("error should be sticky, got %v", err)
+	}
+}
+
+func TestFrame_UnmarshalBinary_ClassicAndFDLayouts(t *testing.T) {
+	classic, err := MustFrame(0x123, []byte{1, 2, 3}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	// FD layout with a payload that fits a classical frame decodes.
+	fd := make([]byte, 72)
+	copy(fd, classic)
+	var g Frame
+	if err := g.UnmarshalBinary(fd); err != nil {
+		t.Fatalf("fd layout with len<=8: %v", err)
+	}
+	if g.ID != 0x123 || g.Len != 3 || g.Data[2] != 3 {
+		t.Fatalf("fd layout decode mismatch: %+v", g)
+	}
+
+	// FD layout with a payload beyond 8 bytes is rejected as FD.
+	fd[4] = 12
+	if err := g.UnmarshalBinary(fd); !errors.Is(err, ErrFDFrame) {
+		t.Fatalf("fd layout len 12: got %v, want ErrFDFrame", err)
+	}
+
+	// Classical layout claiming more than 8 bytes is rejected as invalid length.
+	classic[4] = 12
+	if err := g.UnmarshalBinary(classic); !errors.Is(err, ErrInvalidLen) {
+		t.Fatalf("classic len 12: got %v, want ErrInvalidLen", err)
+	}
+}
+
+func TestFrame_StuffedBitRange(t *testing.T) {
+	cases := []struct {
+		name     string
+		frame    Frame
+		min, max int
+	}{
+		{"standard 0 bytes", MustStandardFrame(0x123, nil), 44, 52},
+		{"standard 8 bytes", MustStandardFrame(0x123, make([]byte, 8)), 108, 132},
+		{"extended 0 bytes", MustExtendedFrame(0x123, nil), 64, 77},
+		{"extended 8 bytes", MustExtendedFrame(0x123, make([]byte, 8)), 128, 157},
+		{"standard RTR len 8", Frame{ID: 0x123, RTR: true, Len: 8}, 44, 52},
+	}
+	for _, tc := range cases {
+		min, max := tc.frame.StuffedBitRange()
+		if min != tc.min || max != tc.max {
+			t.Fatalf("%s: StuffedBitRange() = %d, %d; want %d, %d", tc.name, min, max, tc.min, tc.max)
+		}
+	}
+}
+
+func TestSessionBus_RecordAndReplay(t *testing.T) {
+	bus := NewLoopbackBus()
+	defer bus.Close()
+	peer := bus.Open()
+	defer peer.Close()
+
+	// Peer answers each request with ID+0x80 and the same payload.
+	go func() {
+		for {
+			f, err := peer.Receive()
+			if err != nil {
+				return
+			}
+			_ = peer.Send(MustFrame(f.ID+0x80, f.Data[:f.Len]))
+		}
+	}()
+
+	// exchange is the protocol code under test.
+	exchange := func(b Bus) error {
+		for i := byte(1); i <= 2; i++ {
+			if err := b.Send(MustFrame(0x600, []byte{i})); err != nil {
+				return err
+			}
+			rsp, err := b.Receive()
+			if err != nil {
+				return err
+			}
+			if rsp.ID != 0x680 || rsp.Data[0] != i {
+				return fmt.Errorf("unexpected response %s", rsp)
+			}
+		}
+		return nil
+	}
+
+	rec := NewSessionBus(bus.Open())
+	defer rec.Close()
+	if err := exchange(rec); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	script := rec.Script()
+	if len(script) != 4 || script[0].Kind != EventSend || script[1].Kind != EventReceive {
+		t.Fatalf("unexpected script: %+v", script)
+	}
+
+	replay := ReplayBus(script)
+	if err := exchange(replay); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if err := replay.Verify(); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// A divergent send is reported.
+	bad := ReplayBus(script)
+	if err := bad.Send(MustFrame(0x601, []byte{1})); !errors.Is(err, ErrReplayMismatch) {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+	if err := ReplayBus(script).Verify(); !errors.Is(err, ErrReplayMismatch) {
+		t.Fatalf("expected unconsumed script error, got %v", err)
+	}
+
+	// Padding beyond Len and the timestamp are not part of the frame.
+	same := script[0].Frame
+	same.Data[7] = 0xFF
+	same.Timestamp = time.Now()
+	if err := ReplayBus(script).Send(same); err != nil {
+		t.Fatalf("equal frame reported as mismatch: %v", err)
+	}
+}
+
+// slowReturnBus delays returning from Send, as a driver might.
+type slowReturnBus struct{ Bus }
+
+func (b slowReturnBus) Send(f Frame) error {
+	err := b.Bus.Send(f)
+	time.Sleep(time.Millisecond)
+	return err
+}
+
+func TestSessionBus_SendOrderWithMux(t *testing.T) {
+	bus := NewLoopbackBus()
+	defer bus.Close()
+	peer := bus.Open()
+	go func() {
+		for {
+			f, err := peer.Receive()
+			if err != nil {
+				return
+			}
+			_ = peer.Send(MustFrame(f.ID+0x80, f.Data[:f.Len]))
+		}
+	}()
+
+	// A Mux reads the recorded bus while the test sends through it, and the
+	// inner Send returns only after the reply has had time to arrive.
+	rec := NewSessionBus(slowReturnBus{bus.Open()})
+	mux := NewMux(rec)
+	replies, _ := mux.Subscribe(ByID(0x680), 1)
+	for i := 0; i < 20; i++ {
+		if err := rec.Send(MustFrame(0x600, []byte{byte(i)})); err != nil {
+			t.Fatal(err)
+		}
+		<-replies
+	}
+	_ = mux.Close()
+	for i, e := range rec.Script() {
+		if want := EventKind(i % 2); e.Kind != want {
+			t.Fatalf("event %d is a %s, want %s", i, e.Kind, want)
+		}
+	}
+
+	// A failed send is left out of the script.
+	_ = rec.Close()
+	if err := rec.Send(MustFrame(0x600, nil)); err == nil {
+		t.Fatal("send on closed bus succeeded")
+	}
+	if script := rec.Script(); len(script) != 40 || script[39].Kind != EventReceive {
+		t.Fatalf("script after failed send has %d events", len(script))
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRecordingBus_SendOrderWithMux(t *testing.T) {
+	bus := NewLoopbackBus()
+	defer bus.Close()
+	peer := bus.Open()
+	go func() {
+		for {
+			f, err := peer.Receive()
+			if err != nil {
+				return
+			}
+			_ = peer.Send(MustFrame(f.ID+0x80, f.Data[:f.Len]))
+		}
+	}()
+
+	var log syncBuffer
+	rec := NewRecordingBus(slowReturnBus{bus.Open()}, &log)
+	mux := NewMux(rec)
+	defer mux.Close()
+	replies, _ := mux.Subscribe(ByID(0x680), 1)
+	for i := 0; i < 20; i++ {
+		if err := rec.Send(MustFrame(0x600, []byte{byte(i)})); err != nil {
+			t.Fatal(err)
+		}
+		<-replies
+	}
+
+	// Each request is logged before its reply, and stamped no later.
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 40 {
+		t.Fatalf("logged %d lines, want 40", len(lines))
+	}
+	var last time.Time
+	for i, line := range lines {
+		dir, ts, _, err := ParseCandumpLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{RecordTx, RecordRx}[i%2]; dir != want {
+			t.Fatalf("line %d is %q, want %s", i, line, want)
+		}
+		if ts.Before(last) {
+			t.Fatalf("line %d stamped %v, before %v", i, ts, last)
+		}
+		last = ts
+	}
+}
+
+func TestMux_ConcurrentCloseWithBus(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		bus := NewLoopbackBus()
+		rx := bus.Open()
+		tx := bus.Open()
+		m := NewMux(rx)
+		chs := make([]<-chan Frame, 4)
+		for j := range chs {
+			chs[j], _ = m.Subscribe(nil, 1)
+		}
+		go func() {
+			for {
+				if err := tx.Send(MustFrame(0x100, []byte{1})); err != nil {
+					return
+				}
+			}
+		}()
+
+		done := make(chan struct{}, 3)
+		go func() { _ = m.Close(); done <- struct{}{} }()
+		go func() { _ = m.Close(); done <- struct{}{} }()
+		go func() { _ = rx.Close(); done <- struct{}{} }()
+		for j := 0; j < 3; j++ {
+			<-done
+		}
+		for _, ch := range chs {
+			for range ch {
+			}
+		}
+		if ch, _ := m.Subscribe(nil, 1); ch != nil {
+			if _, ok := <-ch; ok {
+				t.Fatalf("subscribe after close should return a closed channel")
+			}
+		}
+		_ = bus.Close()
+	}
+}
+
+func TestFrame_Normalize(t *testing.T) {
+	dirty := Frame{ID: 0x123, Len: 2, Data: [8]byte{0xDE, 0xAD, 0xBE, 0xEF, 1, 2, 3, 4}}
+	clean := MustStandardFrame(0x123, []byte{0xDE, 0xAD})
+	if dirty == clean {
+		t.Fatal("test setup: frames should differ before Normalize")
+	}
+	dirty.Normalize()
+	if dirty != clean {
+		t.Fatalf("Normalize: got %v % X, want % X", dirty, dirty.Data, clean.Data)
+	}
+
+	rtr := Frame{ID: 0x123, RTR: true, Len: 4, Data: [8]byte{1, 2, 3, 4}}
+	rtr.Normalize()
+	if rtr.Data != ([8]byte{}) {
+		t.Fatalf("Normalize RTR: data % X not zeroed", rtr.Data)
+	}
+
+	// Received frames come back normalized even with dirty padding on the wire.
+	buf := make([]byte, canMTU)
+	binary.LittleEndian.PutUint32(buf[0:4], 0x123)
+	buf[4] = 2
+	copy(buf[8:], []byte{0xDE, 0xAD, 0xBE, 0xEF, 1, 2, 3, 4})
+	var got Frame
+	if err := got.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got != clean {
+		t.Fatalf("UnmarshalBinary: got % X, want % X", got.Data, clean.Data)
+	}
+}
+
+func TestFrame_EqualClone(t *testing.T) {
+	clean := MustStandardFrame(0x123, []byte{0xDE, 0xAD})
+	dirty := Frame{ID: 0x123, Len: 2, Data: [8]byte{0xDE, 0xAD, 0xBE, 0xEF}}
+	if !clean.Equal(dirty) || !dirty.Equal(clean) {
+		t.Fatal("frames differing only in padding should be Equal")
+	}
+	if c := dirty.Clone(); c != clean {
+		t.Fatalf("Clone: got % X, want % X", c.Data, clean.Data)
+	}
+
+	rtrA := Frame{ID: 0x123, RTR: true, Len: 4, Data: [8]byte{1, 2, 3, 4}}
+	rtrB := Frame{ID: 0x123, RTR: true, Len: 4, Data: [8]byte{9}}
+	if !rtrA.Equal(rtrB) {
+		t.Fatal("RTR frames with the same Len should be Equal regardless of data")
+	}
+
+	distinct := []Frame{
+		MustStandardFrame(0x124, []byte{0xDE, 0xAD}),
+		MustExtendedFrame(0x123, []byte{0xDE, 0xAD}),
+		MustStandardFrame(0x123, []byte{0xDE}),
+		MustStandardFrame(0x123, []byte{0xDE, 0xAE}),
+		{ID: 0x123, RTR: true, Len: 2},
+	}
+	for _, d := range distinct {
+		if clean.Equal(d) {
+			t.Fatalf("%v should not equal %v", clean, d)
+		}
+	}
+	if rtrA.Equal(Frame{ID: 0x123, RTR: true, Len: 3}) {
+		t.Fatal("RTR frames with different Len should not be Equal")
+	}
+}
+
+func TestMux_SubscribeAfterClose(t *testing.T) {
+	bus := NewLoopbackBus()
+	defer bus.Close()
+	mux := NewMux(bus.Open())
+	if err := mux.Err(); err != nil {
+		t.Fatalf("Err before Close = %v", err)
+	}
+	_ = mux.Close()
+
+	ch, cancel := mux.Subscribe(nil, 1)
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("expected closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription after Close hangs")
+	}
+	cancel() // no-op, must not panic
+	if err := mux.Err(); !errors.Is(err, ErrMuxClosed) {
+		t.Fatalf("Err after Close = %v, want ErrMuxClosed", err)
+	}
+
+	// A mux stopped by its bus reports the bus error too.
+	ep := bus.Open()
+	mux2 := NewMux(ep)
+	_ = ep.Close()
+	ch2, _ := mux2.Subscribe(nil, 1)
+	for range ch2 {
+	}
+	if err := mux2.Err(); !errors.Is(err, ErrMuxClosed) || !errors.Is(err, ErrClosed) {
+		t.Fatalf("Err after bus close = %v", err)
+	}
+}
+
+func TestFrame_Hash(t *testing.T) {
+	base := MustStandardFrame(0x123, []byte{1, 2})
+	distinct := []Frame{
+		base,
+		MustExtendedFrame(0x123, []byte{1, 2}),
+		MustStandardFrame(0x124, []byte{1, 2}),
+		MustStandardFrame(0x123, []byte{1, 2, 0}),
+		MustStandardFrame(0x123, []byte{2, 1}),
+		MustStandardFrame(0x123, nil),
+		{ID: 0x123, RTR: true, Len: 2},
+		{ID: 0x123, RTR: true},
+	}
+	seen := map[uint64]int{}
+	for i, f := range distinct {
+		h := f.Hash()
+		if j, ok := seen[h]; ok {
+			t.Fatalf("frames %d (%v) and %d (%v) collide", i, f, j, distinct[j])
+		}
+		seen[h] = i
+	}
+
+	// Padding and RTR data do not affect the hash.
+	dirty := base
+	dirty.Data[5] = 0xFF
+	if dirty.Hash() != base.Hash() {
+		t.Fatal("padding changed hash")
+	}
+	rtr := Frame{ID: 0x123, RTR: true, Len: 2, Data: [8]byte{9, 9}}
+	if rtr.Hash() != distinct[6].Hash() {
+		t.Fatal("RTR data changed hash")
+	}
+	// Stable across runs: FNV-1a over 23 01 00 00 | 00 | 02 | 01 02.
+	if got := base.Hash(); got != 0x9C65FBA41D212EBC {
+		t.Fatalf("hash 0x%X, want 0x9C65FBA41D212EBC", got)
+	}
+}
+
+func TestTimedFrame_WithinWindow(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var rec []TimedFrame
+	for i := 0; i < 10; i++ {
+		rec = append(rec, TimedFrame{Frame: MustStandardFrame(0x100+uint32(i), nil), Time: t0.Add(time.Duration(i) * 50 * time.Millisecond)})
+	}
+	// Trigger at frame 5 (t0+250ms); 100ms around it covers frames 3..7.
+	got := SelectTimed(rec, Around(rec[5].Time, 100*time.Millisecond))
+	if len(got) != 5 || got[0].Frame.ID != 0x103 || got[4].Frame.ID != 0x107 {
+		t.Fatalf("Around selected %v", got)
+	}
+	// Bounds are inclusive and may be given in either order.
+	got = SelectTimed(rec, WithinWindow(rec[2].Time, rec[0].Time))
+	if len(got) != 3 {
+		t.Fatalf("WithinWindow selected %d frames, want 3", len(got))
+	}
+	// Combined with a frame filter.
+	got = SelectTimed(rec, AndTimed(WithinWindow(t0, rec[9].Time), Timed(ByID(0x104))))
+	if len(got) != 1 || got[0].Frame.ID != 0x104 {
+		t.Fatalf("combined selected %v", got)
+	}
+}
+
+func TestCandumpLine_RoundTrip(t *testing.T) {
+	ts := time.Unix(1705000000, 123456000)
+	cases := []struct {
+		f    Frame
+		want string
+	}{
+		{MustStandardFrame(0x123, []byte{0xDE, 0xAD, 0xBE, 0xEF}), "(1705000000.123456) can0 123#DEADBEEF"},
+		{MustExtendedFrame(0x1ABCDEFF, nil), "(1705000000.123456) can0 1ABCDEFF#"},
+		{Frame{ID: 0x123, RTR: true}, "(1705000000.123456) can0 123#R"},
+		{Frame{ID: uint32(ErrorClassBusOff), Error: true, Len: 8}, "(1705000000.123456) can0 20000040#0000000000000000"},
+	}
+	for _, tc := range cases {
+		line := tc.f.AppendCandump("can0", ts)
+		if line != tc.want {
+			t.Fatalf("AppendCandump = %q, want %q", line, tc.want)
+		}
+		iface, gotTS, got, err := ParseCandumpLine(line)
+		if err != nil || iface != "can0" || !gotTS.Equal(ts) || got != tc.f {
+			t.Fatalf("ParseCandumpLine(%q) = %q %v %v %v", line, iface, gotTS, got, err)
+		}
+	}
+
+	// Leading zeros in the fraction and a missing timestamp.
+	if line := MustStandardFrame(0x001, nil).AppendCandump("vcan0", time.Unix(5, 7000)); line != "(5.000007) vcan0 001#" {
+		t.Fatalf("AppendCandump = %q", line)
+	}
+	iface, ts2, f, err := ParseCandumpLine("vcan0 7FF#01")
+	if err != nil || iface != "vcan0" || !ts2.IsZero() || f != MustStandardFrame(0x7FF, []byte{1}) {
+		t.Fatalf("no-timestamp parse: %q %v %v %v", iface, ts2, f, err)
+	}
+	if _, _, _, err := ParseCandumpLine("(12.x) can0 123#"); err == nil {
+		t.Fatal("expected timestamp error")
+	}
+}
+
+func TestSLCAN_RoundTrip(t *testing.T) {
+	cases := []struct {
+		f    Frame
+		want string
+	}{
+		{MustStandardFrame(0x123, []byte{0xDE, 0xAD}), "t1232DEAD"},
+		{MustStandardFrame(0x7FF, nil), "t7FF0"},
+		{MustExtendedFrame(0x1ABCDEFF, []byte{1, 2, 3, 4, 5, 6, 7, 8}), "T1ABCDEFF80102030405060708"},
+		{Frame{ID: 0x123, RTR: true, Len: 4}, "r1234"},
+		{Frame{ID: 0x00000001, Extended: true, RTR: true}, "R000000010"},
+	}
+	for _, tc := range cases {
+		got, err := tc.f.MarshalSLCAN()
+		if err != nil || got != tc.want {
+			t.Fatalf("MarshalSLCAN(%v) = %q, %v; want %q", tc.f, got, err, tc.want)
+		}
+		back, err := UnmarshalSLCAN(got + "\r")
+		if err != nil || back != tc.f {
+			t.Fatalf("UnmarshalSLCAN(%q) = %v, %v", got, back, err)
+		}
+	}
+
+	if _, err := (Frame{ID: uint32(ErrorClassBusOff), Error: true, Len: 8}).MarshalSLCAN(); err == nil {
+		t.Fatalf("MarshalSLCAN encoded an error frame")
+	}
+
+	for _, bad := range []string{"", "x1230", "t12", "t1239", "t1232DEA", "t1232DEADBE", "t1232ZZAD", "t8001", "TFFFFFFFF0", "r1234AA"} {
+		if _, err := UnmarshalSLCAN(bad); err == nil {
+			t.Fatalf("UnmarshalSLCAN(%q) accepted malformed input", bad)
+		}
+	}
+}
+
+func TestFrame_ErrorFrame(t *testing.T) {
+	buf := make([]byte, canMTU)
+	binary.LittleEndian.PutUint32(buf[0:4], 0x20000000|0x004|0x040|0x200)
+	buf[4] = 8
+	copy(buf[8:], []byte{0, ControllerTxPassive, 0, 0, 0, 0, 130, 7})
+	var f Frame
+	if err := f.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Error || f.Extended || f.RTR {
+		t.Fatalf("flags: %+v", f)
+	}
+	ef, err := f.DecodeError()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ef.Class.Has(ErrorClassBusOff|ErrorClassController) || ef.Class.Has(ErrorClassNoAck) {
+		t.Fatalf("class 0x%X", ef.Class)
+	}
+	if ef.Controller != ControllerTxPassive || ef.TxErrors != 130 || ef.RxErrors != 7 {
+		t.Fatalf("decoded %+v", ef)
+	}
+	out, err := f.MarshalBinary()
+	if err != nil || !bytes.Equal(out, buf) {
+		t.Fatalf("MarshalBinary round trip: % X, %v", out, err)
+	}
+	if s := f.String(); s != "00000244 [8] ERR 00 20 00 00 00 00 82 07" {
+		t.Fatalf("String: %q", s)
+	}
+
+	if _, err := MustFrame(0x123, nil).DecodeError(); !errors.Is(err, ErrNotErrorFrame) {
+		t.Fatalf("DecodeError on data frame: %v", err)
+	}
+}
+
+func TestFrame_TimestampIgnoredByEncoding(t *testing.T) {
+	f := MustStandardFrame(0x123, []byte{1, 2})
+	g := f
+	g.Timestamp = time.Unix(1700000000, 123456789)
+	if !f.Equal(g) || f.Hash() != g.Hash() {
+		t.Fatal("Timestamp should not affect Equal or Hash")
+	}
+	a, _ := f.MarshalBinary()
+	b, _ := g.MarshalBinary()
+	if !bytes.Equal(a, b) {
+		t.Fatalf("MarshalBinary differs: % X vs % X", a, b)
+	}
+}
+
+func TestCANFilter_Match(t *testing.T) {
+	std := CANFilter{ID: 0x180, Mask: 0x780}
+	if !std.Match(MustStandardFrame(0x185, nil)) || std.Match(MustStandardFrame(0x205, nil)) {
+		t.Fatal("standard mask match")
+	}
+	if std.Match(MustExtendedFrame(0x185, nil)) {
+		t.Fatal("standard filter must not match extended frames")
+	}
+	ext := CANFilter{ID: 0x1ABCDE00, Mask: 0x1FFFFF00, Extended: true}
+	if !ext.Match(MustExtendedFrame(0x1ABCDE42, nil)) || ext.Match(MustExtendedFrame(0x1ABCDF42, nil)) {
+		t.Fatal("extended mask match")
+	}
+	inv := CANFilter{ID: 0x123, Mask: 0x7FF, Inverted: true}
+	if inv.Match(MustStandardFrame(0x123, nil)) || !inv.Match(MustStandardFrame(0x124, nil)) {
+		t.Fatal("inverted filter")
+	}
+}
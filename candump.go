@@ -0,0 +1,79 @@
+package canbus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AppendCandump formats f as a candump log line (candump -l):
+//
+//	(1705000000.123456) can0 123#DEADBEEF
+//
+// The timestamp has microsecond resolution and is omitted when ts is the
+// zero time. The frame portion is the MarshalText form, so extended
+// identifiers use 8 hex digits and RTR frames are written as 123#R.
+func (f Frame) AppendCandump(iface string, ts time.Time) string {
+	b := make([]byte, 0, 48)
+	if !ts.IsZero() {
+		b = append(b, '(')
+		b = strconv.AppendInt(b, ts.Unix(), 10)
+		b = append(b, '.')
+		usec := strconv.Itoa(ts.Nanosecond() / 1000)
+		b = append(b, "000000"[len(usec):]...)
+		b = append(b, usec...)
+		b = append(b, ") "...)
+	}
+	b = append(b, iface...)
+	b = append(b, ' ')
+	return string(appendFrameText(b, f))
+}
+
+// ParseCandumpLine parses a line produced by AppendCandump or candump -l.
+// The leading "(sec.usec)" timestamp is optional; when missing, ts is the
+// zero time.
+func ParseCandumpLine(line string) (iface string, ts time.Time, f Frame, err error) {
+	fields := strings.Fields(line)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "(") {
+		ts, err = parseCandumpTime(fields[0])
+		if err != nil {
+			return "", time.Time{}, Frame{}, err
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 2 {
+		return "", time.Time{}, Frame{}, fmt.Errorf("canbus: malformed candump line %q", line)
+	}
+	if err := f.UnmarshalText([]byte(fields[1])); err != nil {
+		return "", time.Time{}, Frame{}, err
+	}
+	return fields[0], ts, f, nil
+}
+
+func parseCandumpTime(s string) (time.Time, error) {
+	if !strings.HasSuffix(s, ")") {
+		return time.Time{}, fmt.Errorf("canbus: malformed candump timestamp %q", s)
+	}
+	s = s[1 : len(s)-1]
+	secStr, fracStr, _ := strings.Cut(s, ".")
+	sec, err := strconv.ParseInt(secStr, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("canbus: malformed candump timestamp %q", s)
+	}
+	var nsec int64
+	if fracStr != "" {
+		if len(fracStr) > 9 {
+			fracStr = fracStr[:9]
+		}
+		frac, err := strconv.ParseInt(fracStr, 10, 64)
+		if err != nil || frac < 0 {
+			return time.Time{}, fmt.Errorf("canbus: malformed candump timestamp %q", s)
+		}
+		for i := len(fracStr); i < 9; i++ {
+			frac *= 10
+		}
+		nsec = frac
+	}
+	return time.Unix(sec, nsec), nil
+}
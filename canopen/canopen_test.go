@@ -2,8 +2,13 @@ package canopen
 
 import (
     "bytes"
+    "context"
     "encoding/binary"
+    "errors"
     "fmt"
+    "strings"
+    "sync"
+    "sync/atomic"
     "testing"
     "time"
 
@@ -512,3 +517,2106 @@ func TestSDOUploadLenientExpeditedOnly(t *testing.T) {
     if u32 != 0x44332211 { t.Fatalf("u32 mismatch: 0x%08X", u32) }
 }
 
+
+func TestBuildNMTBroadcastAndNode(t *testing.T) {
+    f := BuildNMTBroadcast(NMTResetNode)
+    var parsed NMT
+    if err := parsed.UnmarshalCANFrame(f); err != nil { t.Fatal(err) }
+    if parsed.Command != NMTResetNode || parsed.Node != 0 {
+        t.Fatalf("broadcast mismatch: cmd=%v node=%d", parsed.Command, parsed.Node)
+    }
+
+    f, err := BuildNMTNode(NMTStart, 0x12)
+    if err != nil { t.Fatal(err) }
+    if err := parsed.UnmarshalCANFrame(f); err != nil { t.Fatal(err) }
+    if parsed.Command != NMTStart || parsed.Node != 0x12 {
+        t.Fatalf("node mismatch: cmd=%v node=%d", parsed.Command, parsed.Node)
+    }
+
+    if _, err := BuildNMTNode(NMTStart, 0); err == nil {
+        t.Fatal("expected error for node 0")
+    }
+    if _, err := BuildNMTNode(NMTStart, 128); err == nil {
+        t.Fatal("expected error for node 128")
+    }
+}
+
+func TestSDOMatcher(t *testing.T) {
+    var f canbus.Frame
+    f.ID = COBID(FC_SDO_TX, 0x12)
+    f.Len = 8
+    f.Data[0] = byte(sdoSCSUploadSegment<<5) | (1 << 4)
+    binary.LittleEndian.PutUint16(f.Data[1:3], 0x2000)
+    f.Data[3] = 0x01
+
+    if !(SDOMatcher{Node: 0x12, Command: sdoSCSUploadSegment}).Match(f) {
+        t.Fatal("expected node/command match")
+    }
+    if (SDOMatcher{Node: 0x13, Command: sdoSCSUploadSegment}).Match(f) {
+        t.Fatal("unexpected match for other node")
+    }
+    if (SDOMatcher{Node: 0x12, Command: sdoSCSAbort}).Match(f) {
+        t.Fatal("unexpected match for other command")
+    }
+    one, zero := byte(1), byte(0)
+    if !(SDOMatcher{Node: 0x12, Command: sdoSCSUploadSegment, Toggle: &one}).Match(f) {
+        t.Fatal("expected toggle match")
+    }
+    if (SDOMatcher{Node: 0x12, Command: sdoSCSUploadSegment, Toggle: &zero}).Match(f) {
+        t.Fatal("unexpected toggle match")
+    }
+    idx, sub, other := uint16(0x2000), uint8(0x01), uint8(0x02)
+    if !(SDOMatcher{Node: 0x12, Command: sdoSCSUploadSegment, Index: &idx, Subindex: &sub}).Match(f) {
+        t.Fatal("expected index/subindex match")
+    }
+    if (SDOMatcher{Node: 0x12, Command: sdoSCSUploadSegment, Index: &idx, Subindex: &other}).Match(f) {
+        t.Fatal("unexpected subindex match")
+    }
+    f.ID = COBID(FC_SDO_RX, 0x12)
+    if (SDOMatcher{Node: 0x12, Command: sdoSCSUploadSegment}).Match(f) {
+        t.Fatal("unexpected match for client->server frame")
+    }
+}
+
+func TestFlyingMasterNegotiation(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    defer lb.Close()
+
+    newCandidate := func(self FlyingMaster) *FlyingMasterNegotiator {
+        mux := canbus.NewMux(lb.Open())
+        t.Cleanup(func() { _ = mux.Close() })
+        return NewFlyingMasterNegotiator(lb.Open(), mux, self)
+    }
+
+    a := newCandidate(FlyingMaster{Priority: 1, Node: 5})
+    defer a.Stop()
+    yielded := make(chan FlyingMaster, 1)
+    a.OnYield = func(w FlyingMaster) { yielded <- w }
+
+    w, err := a.Claim(50 * time.Millisecond)
+    if err != nil { t.Fatal(err) }
+    if w != (FlyingMaster{Priority: 1, Node: 5}) || !a.Active() {
+        t.Fatalf("a should be active master, winner=%+v", w)
+    }
+
+    // Lower-ranked candidate detects a and stays passive.
+    b := newCandidate(FlyingMaster{Priority: 2, Node: 3})
+    defer b.Stop()
+    w, err = b.Claim(50 * time.Millisecond)
+    if err != nil { t.Fatal(err) }
+    if w != (FlyingMaster{Priority: 1, Node: 5}) || b.Active() {
+        t.Fatalf("b should defer to a, winner=%+v", w)
+    }
+
+    // Higher-ranked candidate takes over; a yields.
+    c := newCandidate(FlyingMaster{Priority: 0, Node: 9})
+    defer c.Stop()
+    w, err = c.Claim(50 * time.Millisecond)
+    if err != nil { t.Fatal(err) }
+    if w != (FlyingMaster{Priority: 0, Node: 9}) || !c.Active() {
+        t.Fatalf("c should be active master, winner=%+v", w)
+    }
+    select {
+    case got := <-yielded:
+        if got != (FlyingMaster{Priority: 0, Node: 9}) { t.Fatalf("a yielded to %+v", got) }
+    case <-time.After(time.Second):
+        t.Fatal("a did not yield")
+    }
+    if a.Active() { t.Fatal("a should no longer be active") }
+
+    if !(FlyingMaster{Priority: 1, Node: 2}).Outranks(FlyingMaster{Priority: 1, Node: 3}) {
+        t.Fatal("equal priority should be won by lower node id")
+    }
+}
+
+func TestSDOArrayReadWrite(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    // Server stores expedited values for 0x3000 sub1..sub3 and aborts beyond.
+    table := map[uint8][]byte{}
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            fc, node, err := ParseCOBID(f.ID)
+            if err != nil || fc != FC_SDO_RX || node != 0x44 { continue }
+            sub := f.Data[3]
+            var rsp canbus.Frame
+            rsp.ID = COBID(FC_SDO_TX, node)
+            rsp.Len = 8
+            rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            switch {
+            case sub < 1 || sub > 3:
+                rsp.Data[0] = byte(sdoSCSAbort << 5)
+                binary.LittleEndian.PutUint32(rsp.Data[4:8], 0x06090011)
+            case f.Data[0]>>5 == sdoCCSDownloadInitiate:
+                _, _, _, data, _ := parseSDOExpeditedDownload(f)
+                table[sub] = data
+                rsp.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+            case f.Data[0]>>5 == sdoCCSUploadInitiate:
+                v := table[sub]
+                rsp.Data[0] = byte(sdoSCSUploadInitiate<<5) | (1 << 3) | (1 << 2) | byte(4-len(v))
+                copy(rsp.Data[4:], v)
+            }
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x44, mux, WithTimeout(time.Second))
+
+    values := [][]byte{{0x01}, {0x02, 0x03}, {0x04, 0x05, 0x06}}
+    if err := WriteArray(c, 0x3000, 1, values); err != nil { t.Fatalf("write array: %v", err) }
+
+    got, err := ReadArray(c, 0x3000, 1, 5)
+    var ab SDOAbort
+    if !errors.As(err, &ab) || ab.Code != 0x06090011 || ab.Subindex != 4 {
+        t.Fatalf("expected terminating abort at sub 4, got %v", err)
+    }
+    if len(got) != 3 {
+        t.Fatalf("expected 3 partial values, got %d", len(got))
+    }
+    for i := range values {
+        if !bytes.Equal(got[i], values[i]) { t.Fatalf("value %d: got % X want % X", i, got[i], values[i]) }
+    }
+
+    if err := WriteArray(c, 0x3000, 255, values); err == nil {
+        t.Fatal("expected subindex range error")
+    }
+}
+
+func TestSRDO(t *testing.T) {
+    s := SRDO{Number: 2, Data: []byte{0x12, 0x34, 0x00}}
+    normal, inverted, err := s.MarshalCANFrames()
+    if err != nil { t.Fatal(err) }
+    if normal.ID != 0x103 || inverted.ID != 0x104 {
+        t.Fatalf("srdo ids: 0x%X/0x%X", normal.ID, inverted.ID)
+    }
+    if inverted.Data[0] != 0xED || inverted.Data[2] != 0xFF {
+        t.Fatalf("inverted data: % X", inverted.Data[:inverted.Len])
+    }
+    var parsed SRDO
+    if err := parsed.UnmarshalCANFrames(normal, inverted); err != nil { t.Fatal(err) }
+    if parsed.Number != 2 || !bytes.Equal(parsed.Data, s.Data) {
+        t.Fatalf("srdo mismatch: %+v", parsed)
+    }
+
+    if n, i, err := SRDOCOBIDs(64); err != nil || n != 0x17F || i != 0x180 {
+        t.Fatalf("srdo 64 ids: 0x%X/0x%X err=%v", n, i, err)
+    }
+    if _, _, err := SRDOCOBIDs(65); err == nil { t.Fatal("expected error for SRDO 65") }
+
+    bad := inverted
+    bad.Data[1] ^= 0x01
+    if err := parsed.UnmarshalCANFrames(normal, bad); err == nil { t.Fatal("expected inversion mismatch") }
+    other := inverted
+    other.ID = 0x106
+    if err := parsed.UnmarshalCANFrames(normal, other); err == nil { t.Fatal("expected id pairing mismatch") }
+    short := inverted
+    short.Len = 2
+    if err := parsed.UnmarshalCANFrames(normal, short); err == nil { t.Fatal("expected length mismatch") }
+}
+
+func TestClassify(t *testing.T) {
+    counter := uint8(3)
+    cases := []struct {
+        name string
+        msg  FrameMarshaler
+    }{
+        {"nmt", NMT{Command: NMTStart, Node: 4}},
+        {"sync", SYNC{Counter: &counter}},
+        {"emcy", Emergency{Node: 5, ErrorCode: 0x8110, ErrorRegister: 0x11}},
+        {"time", TIME{Milliseconds: 1234, Days: 42}},
+        {"heartbeat", Heartbeat{Node: 6, State: StatePreOperational}},
+        {"pdo", PDOFrame{Function: FC_TPDO2, Node: 7, Data: []byte{1, 2}}},
+        {"sdo", SDOFrame{Function: FC_SDO_TX, Node: 8, Data: [8]byte{0x60}}},
+    }
+    for _, tc := range cases {
+        f, err := tc.msg.MarshalCANFrame()
+        if err != nil { t.Fatalf("%s: marshal: %v", tc.name, err) }
+        got, err := Classify(f)
+        if err != nil { t.Fatalf("%s: classify: %v", tc.name, err) }
+        switch m := got.(type) {
+        case NMT:
+            if tc.name != "nmt" || m.Node != 4 { t.Fatalf("%s: got %+v", tc.name, m) }
+        case SYNC:
+            if tc.name != "sync" || m.Counter == nil || *m.Counter != 3 { t.Fatalf("%s: got %+v", tc.name, m) }
+        case Emergency:
+            if tc.name != "emcy" || m.Node != 5 || m.ErrorCode != 0x8110 { t.Fatalf("%s: got %+v", tc.name, m) }
+        case TIME:
+            if tc.name != "time" || m.Milliseconds != 1234 || m.Days != 42 { t.Fatalf("%s: got %+v", tc.name, m) }
+        case Heartbeat:
+            if tc.name != "heartbeat" || m.Node != 6 || m.State != StatePreOperational { t.Fatalf("%s: got %+v", tc.name, m) }
+        case PDOFrame:
+            if tc.name != "pdo" || m.Function != FC_TPDO2 || m.Node != 7 || !bytes.Equal(m.Data, []byte{1, 2}) { t.Fatalf("%s: got %+v", tc.name, m) }
+        case SDOFrame:
+            if tc.name != "sdo" || m.Function != FC_SDO_TX || m.Node != 8 || m.Data[0] != 0x60 { t.Fatalf("%s: got %+v", tc.name, m) }
+        default:
+            t.Fatalf("%s: unexpected type %T", tc.name, got)
+        }
+    }
+
+    if _, err := Classify(canbus.Frame{ID: 0x18FEF100, Extended: true}); err == nil {
+        t.Fatal("expected error for extended frame")
+    }
+    if _, err := Classify(canbus.MustStandardFrame(0x7E5, nil)); err == nil {
+        t.Fatal("expected error for id outside CANopen ranges")
+    }
+}
+
+func TestDispatcherUnknownPolicy(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    defer lb.Close()
+    mux := canbus.NewMux(lb.Open())
+    defer mux.Close()
+    tx := lb.Open()
+
+    d := NewDispatcher(mux, 16)
+    defer d.Close()
+
+    heartbeats := make(chan Heartbeat, 1)
+    unknown := make(chan canbus.Frame, 2)
+    On(d, func(h Heartbeat) { heartbeats <- h })
+    d.OnUnknown(func(f canbus.Frame) { unknown <- f })
+
+    hb, _ := Heartbeat{Node: 3, State: StateOperational}.MarshalCANFrame()
+    j1939 := canbus.Frame{ID: 0x18FEF100, Extended: true, Len: 1}
+    sync, _ := SYNC{}.MarshalCANFrame() // classified, but no handler registered
+    for _, f := range []canbus.Frame{hb, j1939, sync} {
+        if err := tx.Send(f); err != nil { t.Fatal(err) }
+    }
+
+    select {
+    case h := <-heartbeats:
+        if h.Node != 3 { t.Fatalf("heartbeat node %d", h.Node) }
+    case <-time.After(time.Second):
+        t.Fatal("heartbeat not dispatched")
+    }
+    for _, want := range []uint32{j1939.ID, sync.ID} {
+        select {
+        case f := <-unknown:
+            if f.ID != want { t.Fatalf("catch-all got 0x%X, want 0x%X", f.ID, want) }
+        case <-time.After(time.Second):
+            t.Fatalf("catch-all did not receive 0x%X", want)
+        }
+    }
+
+    // Dropping policy: non-CANopen frames no longer reach the catch-all.
+    d.SetUnknownPolicy(UnknownDrop)
+    if err := tx.Send(j1939); err != nil { t.Fatal(err) }
+    if err := tx.Send(hb); err != nil { t.Fatal(err) }
+    <-heartbeats
+    select {
+    case f := <-unknown:
+        t.Fatalf("unexpected catch-all frame 0x%X", f.ID)
+    default:
+    }
+}
+
+func TestSubscribeSDOAborts(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    defer lb.Close()
+    mux := canbus.NewMux(lb.Open())
+    defer mux.Close()
+    tx := lb.Open()
+
+    events, cancel := SubscribeSDOAborts(mux, 4)
+
+    var abort canbus.Frame
+    abort.ID = COBID(FC_SDO_TX, 0x21)
+    abort.Len = 8
+    abort.Data[0] = byte(sdoSCSAbort << 5)
+    binary.LittleEndian.PutUint16(abort.Data[1:3], 0x6040)
+    binary.LittleEndian.PutUint32(abort.Data[4:8], 0x06010002)
+    var ok canbus.Frame
+    ok.ID = COBID(FC_SDO_TX, 0x21)
+    ok.Len = 8
+    ok.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+    if err := tx.Send(ok); err != nil { t.Fatal(err) }
+    if err := tx.Send(abort); err != nil { t.Fatal(err) }
+
+    select {
+    case ev := <-events:
+        if ev.Node != 0x21 || ev.Abort.Index != 0x6040 || ev.Abort.Code != 0x06010002 {
+            t.Fatalf("unexpected abort event: %+v", ev)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("abort not delivered")
+    }
+
+    cancel()
+    select {
+    case _, open := <-events:
+        if open { t.Fatal("unexpected extra event") }
+    case <-time.After(time.Second):
+        t.Fatal("events channel not closed after cancel")
+    }
+}
+
+func TestConfigureNodeGuarding(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    // Mock server acknowledges expedited downloads and records them.
+    written := make(chan [2]uint32, 2)
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            node, idx, sub, data, err := parseSDOExpeditedDownload(f)
+            if err != nil || node != 0x0C || sub != 0 { continue }
+            var v uint32
+            for i := len(data) - 1; i >= 0; i-- { v = v<<8 | uint32(data[i]) }
+            written <- [2]uint32{uint32(idx), v}
+            var rsp canbus.Frame
+            rsp.ID = COBID(FC_SDO_TX, node)
+            rsp.Len = 8
+            rsp.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+            rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x0C, mux, WithTimeout(time.Second))
+
+    if err := ConfigureNodeGuarding(c, 250*time.Millisecond, 3); err != nil { t.Fatal(err) }
+    if got := <-written; got != [2]uint32{0x100C, 250} { t.Fatalf("guard time write: %v", got) }
+    if got := <-written; got != [2]uint32{0x100D, 3} { t.Fatalf("life factor write: %v", got) }
+
+    if err := ConfigureNodeGuarding(c, 70*time.Second, 3); err == nil {
+        t.Fatal("expected guard time range error")
+    }
+}
+
+func TestWaitForEmergency(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    defer lb.Close()
+    mux := canbus.NewMux(lb.Open())
+    defer mux.Close()
+    tx := lb.Open()
+
+    go func() {
+        time.Sleep(20 * time.Millisecond)
+        for _, e := range []Emergency{
+            {Node: 0x07, ErrorCode: 0x3210, ErrorRegister: 0x05}, // other node
+            {Node: 0x06, ErrorCode: 0x1000, ErrorRegister: 0x01}, // other code
+            {Node: 0x06, ErrorCode: 0x3210, ErrorRegister: 0x05},
+        } {
+            f, _ := e.MarshalCANFrame()
+            _ = tx.Send(f)
+        }
+    }()
+
+    e, err := WaitForEmergency(mux, 0x06, 0x3210, time.Second)
+    if err != nil { t.Fatal(err) }
+    if e.Node != 0x06 || e.ErrorCode != 0x3210 || e.ErrorRegister != 0x05 {
+        t.Fatalf("unexpected emergency: %+v", e)
+    }
+
+    if _, err := WaitForEmergency(mux, 0x06, 0x3210, 30*time.Millisecond); !errors.Is(err, ErrReceiveTimeout) {
+        t.Fatalf("expected ErrReceiveTimeout, got %v", err)
+    }
+}
+
+func TestHeartbeatGuardingToggle(t *testing.T) {
+    f, err := Heartbeat{Node: 4, State: StateOperational}.MarshalCANFrame()
+    if err != nil { t.Fatal(err) }
+    if f.Data[0] != 0x05 { t.Fatalf("heartbeat byte 0x%02X", f.Data[0]) }
+
+    // Node guarding response with toggle set.
+    f.Data[0] = 0x80 | byte(StatePreOperational)
+    var hb Heartbeat
+    if err := hb.UnmarshalCANFrame(f); err != nil { t.Fatal(err) }
+    if !hb.Toggle || hb.State != StatePreOperational {
+        t.Fatalf("guard response mismatch: %+v", hb)
+    }
+    g, err := hb.MarshalCANFrame()
+    if err != nil { t.Fatal(err) }
+    if g.Data[0] != f.Data[0] { t.Fatalf("toggle roundtrip: 0x%02X", g.Data[0]) }
+}
+
+func TestPDOLayoutMixedByteOrder(t *testing.T) {
+    layout := PDOLayout{
+        {Name: "speed", Start: 0, Length: 16, Order: LittleEndian},
+        {Name: "torque", Start: 23, Length: 16, Order: BigEndian}, // bytes 2..3, MSB first
+        {Name: "mode", Start: 32, Length: 4, Order: LittleEndian},
+        {Name: "temp", Start: 47, Length: 12, Order: BigEndian}, // byte 5 bits 7..0, byte 6 bits 7..4
+    }
+    values := []uint64{0x1234, 0xABCD, 0x5, 0x9F3}
+    data, err := layout.Pack(values)
+    if err != nil { t.Fatal(err) }
+    want := []byte{0x34, 0x12, 0xAB, 0xCD, 0x05, 0x9F, 0x30}
+    if !bytes.Equal(data, want) {
+        t.Fatalf("pack: got % X want % X", data, want)
+    }
+    got, err := layout.Unpack(data)
+    if err != nil { t.Fatal(err) }
+    for i := range values {
+        if got[i] != values[i] { t.Fatalf("field %s: got 0x%X want 0x%X", layout[i].Name, got[i], values[i]) }
+    }
+
+    overlap := PDOLayout{
+        {Name: "a", Start: 0, Length: 16, Order: LittleEndian},
+        {Name: "b", Start: 15, Length: 8, Order: BigEndian}, // byte 1 overlaps a
+    }
+    if err := overlap.Validate(); err == nil { t.Fatal("expected overlap error") }
+    if _, err := (PDOLayout{{Name: "x", Start: 60, Length: 8}}).Pack([]uint64{1}); err == nil {
+        t.Fatal("expected out of range error")
+    }
+    if _, err := (PDOLayout{{Name: "x", Start: 0, Length: 4}}).Pack([]uint64{0x10}); err == nil {
+        t.Fatal("expected value overflow error")
+    }
+}
+
+func TestSDODownloadContextCancelSendsAbort(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    // Server accepts the segmented initiate, then stalls and reports aborts.
+    aborts := make(chan canbus.Frame, 2)
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            switch f.Data[0] >> 5 {
+            case sdoCCSDownloadInitiate:
+                var rsp canbus.Frame
+                rsp.ID = COBID(FC_SDO_TX, 0x31)
+                rsp.Len = 8
+                rsp.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+                rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+                _ = server.Send(rsp)
+            case sdoCCSAbort:
+                aborts <- f
+            }
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x31, mux)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    time.AfterFunc(50*time.Millisecond, cancel)
+    err := c.DownloadContext(ctx, 0x1F50, 0x01, make([]byte, 64))
+    if !errors.Is(err, context.Canceled) {
+        t.Fatalf("expected context.Canceled, got %v", err)
+    }
+    select {
+    case f := <-aborts:
+        if code := binary.LittleEndian.Uint32(f.Data[4:8]); code != 0x05040000 || binary.LittleEndian.Uint16(f.Data[1:3]) != 0x1F50 {
+            t.Fatalf("unexpected abort frame % X", f.Data)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("abort not sent")
+    }
+
+    // A caller-specified abort code via the cancel cause.
+    cctx, ccancel := context.WithCancelCause(context.Background())
+    time.AfterFunc(20*time.Millisecond, func() { ccancel(SDOAbort{Code: 0x08000021}) })
+    if _, err := c.UploadContext(cctx, 0x1F50, 0x02); err == nil {
+        t.Fatal("expected error")
+    }
+    select {
+    case f := <-aborts:
+        if code := binary.LittleEndian.Uint32(f.Data[4:8]); code != 0x08000021 {
+            t.Fatalf("unexpected abort code 0x%08X", code)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("abort not sent")
+    }
+}
+
+func TestSchedulerDrivesProducers(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    tx := lb.Open()
+    rx := lb.Open()
+    defer func() { _ = tx.Close(); _ = rx.Close() }()
+
+    clock := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+    s := NewScheduler(tx, nil)
+    s.Add(NewSYNCWriter(tx, 10*time.Millisecond, true))
+    hb := NewHeartbeatProducer(tx, 0x09, 15*time.Millisecond)
+    hb.SetState(StateOperational)
+    s.Add(hb)
+    s.Add(NewTIMEWriter(tx, 20*time.Millisecond, func() time.Time { return clock }))
+    s.Start()
+    defer s.Stop()
+
+    var syncs, hbs, times int
+    deadline := time.After(2 * time.Second)
+    for syncs < 3 || hbs < 2 || times < 2 {
+        select {
+        case <-deadline:
+            t.Fatalf("timeout: sync=%d hb=%d time=%d", syncs, hbs, times)
+        default:
+        }
+        f, err := rx.Receive()
+        if err != nil { t.Fatal(err) }
+        msg, err := Classify(f)
+        if err != nil { t.Fatal(err) }
+        switch m := msg.(type) {
+        case SYNC:
+            syncs++
+        case Heartbeat:
+            if m.Node != 0x09 || m.State != StateOperational { t.Fatalf("heartbeat %+v", m) }
+            hbs++
+        case TIME:
+            if !m.Time().Equal(clock) { t.Fatalf("time %v", m.Time()) }
+            times++
+        }
+    }
+}
+
+func TestCOBIDExtRoundTrip(t *testing.T) {
+    for _, prefix := range []uint32{0, 1, 0x155, 0x3FFFF} {
+        id := COBIDExt(FC_SDO_TX, 0x42, prefix)
+        fc, node, p, err := ParseCOBIDExt(id)
+        if err != nil || fc != FC_SDO_TX || node != 0x42 || p != prefix {
+            t.Fatalf("prefix 0x%X: id=0x%X fc=%v node=%d p=0x%X err=%v", prefix, id, fc, node, p, err)
+        }
+        f := canbus.Frame{ID: id, Extended: true}
+        if !CANopenSDOResponseExt(0x42, prefix)(f) || !CANopenExtAny(FC_SDO_TX, prefix)(f) {
+            t.Fatalf("prefix 0x%X: extended filters did not match", prefix)
+        }
+        if CANopenSDOResponse(0x42)(f) {
+            t.Fatal("standard filter matched extended frame")
+        }
+    }
+    if _, _, _, err := ParseCOBIDExt(0x20000000); err == nil {
+        t.Fatal("expected error for id beyond 29 bits")
+    }
+}
+
+func TestSDOClientExtendedCOBID(t *testing.T) {
+    const prefix = 0x1234
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func(){ _ = client.Close(); _ = server.Close() }()
+
+    // Server stores expedited downloads and answers uploads, extended only.
+    go func(){
+        var stored [4]byte
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            if !CANopenSDORequestExt(0x22, prefix)(f) { continue }
+            rsp := canbus.Frame{ID: COBIDExt(FC_SDO_TX, 0x22, prefix), Extended: true, Len: 8}
+            rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            switch (f.Data[0]>>5)&0x7 {
+            case sdoCCSDownloadInitiate:
+                copy(stored[:], f.Data[4:8])
+                rsp.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+            case sdoCCSUploadInitiate:
+                rsp.Data[0] = byte(sdoSCSUploadInitiate<<5) | 0x0C
+                copy(rsp.Data[4:8], stored[:])
+            default:
+                continue
+            }
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x22, mux, WithTimeout(time.Second), WithExtendedCOBID(prefix))
+    if err := c.WriteU32(0x2000, 0x01, 0xCAFEBABE); err != nil { t.Fatal(err) }
+    v, err := c.ReadU32(0x2000, 0x01)
+    if err != nil || v != 0xCAFEBABE {
+        t.Fatalf("read 0x%08X err=%v", v, err)
+    }
+}
+
+func TestPDOInhibitMonitor(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    tx := lb.Open()
+    mux := canbus.NewMux(lb.Open())
+    defer func(){ _ = tx.Close(); _ = mux.Close() }()
+
+    tpdo := COBID(FC_TPDO1, 0x05)
+    mon := NewPDOInhibitMonitor(mux, map[uint32]time.Duration{tpdo: 50 * time.Millisecond}, 16)
+    defer mon.Close()
+
+    // Compliant pair: gap well above the inhibit time.
+    if err := tx.Send(canbus.MustStandardFrame(tpdo, []byte{1})); err != nil { t.Fatal(err) }
+    time.Sleep(80 * time.Millisecond)
+    if err := tx.Send(canbus.MustStandardFrame(tpdo, []byte{2})); err != nil { t.Fatal(err) }
+    // Flood: immediate retransmission violates the inhibit time.
+    if err := tx.Send(canbus.MustStandardFrame(tpdo, []byte{3})); err != nil { t.Fatal(err) }
+    // Other COB-IDs are ignored.
+    other := COBID(FC_TPDO1, 0x06)
+    _ = tx.Send(canbus.MustStandardFrame(other, []byte{1}))
+    _ = tx.Send(canbus.MustStandardFrame(other, []byte{1}))
+
+    select {
+    case v := <-mon.Violations():
+        if v.COBID != tpdo || v.Inhibit != 50*time.Millisecond || v.Gap >= v.Inhibit {
+            t.Fatalf("unexpected violation %+v", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("no violation reported")
+    }
+    select {
+    case v := <-mon.Violations():
+        t.Fatalf("unexpected extra violation %+v", v)
+    case <-time.After(30 * time.Millisecond):
+    }
+
+    // Frame timestamps, when present, take precedence over arrival time.
+    stamped := COBID(FC_TPDO2, 0x05)
+    mon2 := NewPDOInhibitMonitor(mux, map[uint32]time.Duration{stamped: 50 * time.Millisecond}, 16)
+    defer mon2.Close()
+    base := time.Unix(1000, 0)
+    for _, at := range []time.Duration{0, 100 * time.Millisecond, 110 * time.Millisecond} {
+        f := canbus.MustStandardFrame(stamped, []byte{1})
+        f.Timestamp = base.Add(at)
+        if err := tx.Send(f); err != nil { t.Fatal(err) }
+    }
+    select {
+    case v := <-mon2.Violations():
+        if v.Gap != 10*time.Millisecond || !v.At.Equal(base.Add(110*time.Millisecond)) {
+            t.Fatalf("unexpected violation %+v", v)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("no violation reported")
+    }
+}
+
+func TestReadAllPDOConfig(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    type key struct { idx uint16; sub uint8 }
+    u8 := func(v uint8) []byte { return []byte{v} }
+    u16 := func(v uint16) []byte { return binary.LittleEndian.AppendUint16(nil, v) }
+    u32 := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+    od := map[key][]byte{
+        // TPDO1: enabled, inhibit 10 ms, sub5 (event timer) not implemented.
+        {0x1800, 0}: u8(5), {0x1800, 1}: u32(0x181), {0x1800, 2}: u8(0xFE), {0x1800, 3}: u16(100),
+        {0x1A00, 0}: u8(2), {0x1A00, 1}: u32(0x60410010), {0x1A00, 2}: u32(0x60640020),
+        // RPDO1: disabled via bit 31, no mapping.
+        {0x1400, 0}: u8(2), {0x1400, 1}: u32(0x80000201), {0x1400, 2}: u8(0xFF),
+        {0x1600, 0}: u8(0),
+    }
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            fc, node, err := ParseCOBID(f.ID)
+            if err != nil || fc != FC_SDO_RX || node != 0x45 || f.Data[0]>>5 != sdoCCSUploadInitiate { continue }
+            idx := binary.LittleEndian.Uint16(f.Data[1:3])
+            var rsp canbus.Frame
+            rsp.ID = COBID(FC_SDO_TX, node)
+            rsp.Len = 8
+            rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            v, ok := od[key{idx, f.Data[3]}]
+            switch {
+            case ok:
+                rsp.Data[0] = byte(sdoSCSUploadInitiate<<5) | (1 << 3) | (1 << 2) | byte(4-len(v))
+                copy(rsp.Data[4:], v)
+            case od[key{idx, 0}] != nil:
+                rsp.Data[0] = byte(sdoSCSAbort << 5)
+                binary.LittleEndian.PutUint32(rsp.Data[4:8], 0x06090011)
+            default:
+                rsp.Data[0] = byte(sdoSCSAbort << 5)
+                binary.LittleEndian.PutUint32(rsp.Data[4:8], 0x06020000)
+            }
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x45, mux, WithTimeout(time.Second))
+
+    cfg, err := ReadAllPDOConfig(c)
+    if err != nil { t.Fatal(err) }
+    t1 := cfg.TPDO[0]
+    if !t1.Present || !t1.Enabled || t1.COBID != 0x181 || t1.TransmissionType != 0xFE ||
+        t1.InhibitTime != 10*time.Millisecond || t1.EventTimer != 0 {
+        t.Fatalf("TPDO1 %+v", t1)
+    }
+    want := []PDOMapping{{0x6041, 0x00, 16}, {0x6064, 0x00, 32}}
+    if len(t1.Mappings) != 2 || t1.Mappings[0] != want[0] || t1.Mappings[1] != want[1] {
+        t.Fatalf("TPDO1 mappings %+v", t1.Mappings)
+    }
+    r1 := cfg.RPDO[0]
+    if !r1.Present || r1.Enabled || r1.COBID != 0x201 || len(r1.Mappings) != 0 {
+        t.Fatalf("RPDO1 %+v", r1)
+    }
+    for i := 1; i < 4; i++ {
+        if cfg.TPDO[i].Present || cfg.RPDO[i].Present {
+            t.Fatalf("PDO %d unexpectedly present", i+1)
+        }
+    }
+}
+
+func TestSDOQueuePriorityAndOrder(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    defer client.Close()
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    q := NewSDOQueue(NewSDOClient(client, 0x10, mux))
+
+    // Hold the worker so the following submissions queue up behind it.
+    release := make(chan struct{})
+    started := make(chan struct{})
+    first := q.Submit(0, func(*SDOClient) ([]byte, error) {
+        close(started)
+        <-release
+        return nil, nil
+    })
+    <-started
+
+    var mu sync.Mutex
+    var order []string
+    op := func(name string) SDOOp {
+        return func(*SDOClient) ([]byte, error) {
+            mu.Lock()
+            order = append(order, name)
+            mu.Unlock()
+            return []byte(name), nil
+        }
+    }
+    results := []<-chan SDOResult{
+        q.Submit(0, op("bulk1")),
+        q.Submit(0, op("bulk2")),
+        q.Submit(10, op("urgent1")),
+        q.Submit(5, op("normal")),
+        q.Submit(10, op("urgent2")),
+    }
+    close(release)
+    <-first
+    for _, r := range results {
+        if res := <-r; res.Err != nil { t.Fatal(res.Err) }
+    }
+    want := []string{"urgent1", "urgent2", "normal", "bulk1", "bulk2"}
+    if fmt.Sprint(order) != fmt.Sprint(want) {
+        t.Fatalf("order %v, want %v", order, want)
+    }
+
+    // Under load, equal priorities stay FIFO however they interleave with
+    // others. Priorities are strict, so the low ones wait for all high ones.
+    hold := func() (release chan struct{}, done <-chan SDOResult) {
+        release = make(chan struct{})
+        started := make(chan struct{})
+        done = q.Submit(100, func(*SDOClient) ([]byte, error) {
+            close(started)
+            <-release
+            return nil, nil
+        })
+        <-started
+        return release, done
+    }
+    mu.Lock()
+    order = nil
+    mu.Unlock()
+    release, held := hold()
+    results = results[:0]
+    var wantHigh, wantLow []string
+    for i := 0; i < 50; i++ {
+        high, low := fmt.Sprintf("high%d", i), fmt.Sprintf("low%d", i)
+        wantHigh, wantLow = append(wantHigh, high), append(wantLow, low)
+        results = append(results, q.Submit(1, op(low)), q.Submit(2, op(high)))
+    }
+    close(release)
+    <-held
+    for _, r := range results {
+        if res := <-r; res.Err != nil { t.Fatal(res.Err) }
+    }
+    if fmt.Sprint(order) != fmt.Sprint(append(wantHigh, wantLow...)) {
+        t.Fatalf("order under load %v", order)
+    }
+
+    // Close fails transfers still queued behind the running one and waits
+    // for the running one to finish.
+    release, held = hold()
+    queued := []<-chan SDOResult{q.Submit(0, op("queued1")), q.Submit(5, op("queued2"))}
+    closed := make(chan struct{})
+    go func() { q.Close(); close(closed) }()
+    for _, r := range queued {
+        if res := <-r; !errors.Is(res.Err, canbus.ErrClosed) {
+            t.Fatalf("queued transfer on close: %v", res.Err)
+        }
+    }
+    select {
+    case <-closed:
+        t.Fatal("Close returned before the running transfer finished")
+    case <-time.After(10 * time.Millisecond):
+    }
+    close(release)
+    if res := <-held; res.Err != nil { t.Fatal(res.Err) }
+    <-closed
+    if res := <-q.Submit(0, op("late")); !errors.Is(res.Err, canbus.ErrClosed) {
+        t.Fatalf("submit after close: %v", res.Err)
+    }
+}
+
+func TestSDO24BitAccessors(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    // Server stores one expedited value and echoes it back on upload.
+    var stored []byte
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            fc, node, err := ParseCOBID(f.ID)
+            if err != nil || fc != FC_SDO_RX || node != 0x46 { continue }
+            var rsp canbus.Frame
+            rsp.ID = COBID(FC_SDO_TX, node)
+            rsp.Len = 8
+            rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            switch f.Data[0] >> 5 {
+            case sdoCCSDownloadInitiate:
+                _, _, _, stored, _ = parseSDOExpeditedDownload(f)
+                rsp.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+            case sdoCCSUploadInitiate:
+                rsp.Data[0] = byte(sdoSCSUploadInitiate<<5) | (1 << 3) | (1 << 2) | byte(4-len(stored))
+                copy(rsp.Data[4:], stored)
+            }
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x46, mux, WithTimeout(time.Second))
+
+    if err := c.WriteU24(0x2000, 0, 0xABCDEF); err != nil { t.Fatal(err) }
+    if !bytes.Equal(stored, []byte{0xEF, 0xCD, 0xAB}) { t.Fatalf("stored % X", stored) }
+    if v, err := c.ReadU24(0x2000, 0); err != nil || v != 0xABCDEF {
+        t.Fatalf("u24 0x%X err=%v", v, err)
+    }
+    for _, want := range []int32{-1, -(1 << 23), 1<<23 - 1, 42} {
+        if err := c.WriteI24(0x2001, 0, want); err != nil { t.Fatal(err) }
+        if v, err := c.ReadI24(0x2001, 0); err != nil || v != want {
+            t.Fatalf("i24 %d: got %d err=%v", want, v, err)
+        }
+    }
+    if err := c.WriteU24(0x2000, 0, 0x1000000); err == nil { t.Fatal("expected u24 range error") }
+    if err := c.WriteI24(0x2000, 0, 1<<23); err == nil { t.Fatal("expected i24 range error") }
+}
+
+func TestHeartbeatConsumerEntry(t *testing.T) {
+    v, err := HeartbeatConsumerEntry(0x05, 1500*time.Millisecond)
+    if err != nil { t.Fatal(err) }
+    if v != 0x000505DC {
+        t.Fatalf("entry 0x%08X, want 0x000505DC", v)
+    }
+    node, timeout := ParseHeartbeatConsumerEntry(v)
+    if node != 0x05 || timeout != 1500*time.Millisecond {
+        t.Fatalf("parsed node=%d timeout=%v", node, timeout)
+    }
+    // Reserved high bits are ignored; zero time means disabled.
+    if node, timeout := ParseHeartbeatConsumerEntry(0xFF7F0000); node != 0x7F || timeout != 0 {
+        t.Fatalf("parsed node=%d timeout=%v", node, timeout)
+    }
+    if _, err := HeartbeatConsumerEntry(0, time.Second); err == nil { t.Fatal("expected node id error") }
+    if _, err := HeartbeatConsumerEntry(1, 70*time.Second); err == nil { t.Fatal("expected range error") }
+}
+
+func TestSDORTTHook(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    // Server acknowledges download initiates and segments.
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            fc, node, err := ParseCOBID(f.ID)
+            if err != nil || fc != FC_SDO_RX || node != 0x47 { continue }
+            var rsp canbus.Frame
+            rsp.ID = COBID(FC_SDO_TX, node)
+            rsp.Len = 8
+            switch f.Data[0] >> 5 {
+            case sdoCCSDownloadInitiate:
+                rsp.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+                rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            case sdoCCSDownloadSegment:
+                rsp.Data[0] = byte(sdoSCSDownloadSegment<<5) | f.Data[0]&(1<<4)
+            default:
+                continue
+            }
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    var rtts []SDORoundTrip
+    c := NewSDOClient(client, 0x47, mux, WithTimeout(time.Second), WithRTTHook(func(r SDORoundTrip) {
+        rtts = append(rtts, r)
+    }))
+
+    if err := c.WriteU16(0x2000, 0x01, 1); err != nil { t.Fatal(err) }
+    if err := c.Download(0x2001, 0x00, make([]byte, 10)); err != nil { t.Fatal(err) }
+
+    // Expedited initiate, segmented initiate, then two segments.
+    wantSeg := []bool{false, false, true, true}
+    if len(rtts) != len(wantSeg) {
+        t.Fatalf("got %d RTT samples, want %d: %+v", len(rtts), len(wantSeg), rtts)
+    }
+    for i, r := range rtts {
+        if r.Segment != wantSeg[i] || r.Node != 0x47 || r.RTT <= 0 {
+            t.Fatalf("sample %d: %+v", i, r)
+        }
+    }
+    if rtts[0].Index != 0x2000 || rtts[3].Index != 0x2001 {
+        t.Fatalf("indices %+v", rtts)
+    }
+}
+
+func TestSDORTTHookConcurrent(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    // Server acknowledges expedited download initiates.
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            fc, node, err := ParseCOBID(f.ID)
+            if err != nil || fc != FC_SDO_RX || node != 0x47 || f.Data[0]>>5 != sdoCCSDownloadInitiate { continue }
+            var rsp canbus.Frame
+            rsp.ID = COBID(FC_SDO_TX, node)
+            rsp.Len = 8
+            rsp.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+            rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    var mu sync.Mutex
+    samples := map[uint16]int{}
+    c := NewSDOClient(client, 0x47, mux, WithTimeout(time.Second), WithRTTHook(func(r SDORoundTrip) {
+        mu.Lock()
+        samples[r.Index]++
+        mu.Unlock()
+    }))
+
+    // Transfers on one client run concurrently; each is timed on its own.
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func(index uint16) {
+            defer wg.Done()
+            for j := 0; j < 10; j++ {
+                if err := c.WriteU8(index, 0, 1); err != nil { t.Error(err); return }
+            }
+        }(uint16(0x2000 + i))
+    }
+    wg.Wait()
+    for i := 0; i < 8; i++ {
+        if n := samples[uint16(0x2000+i)]; n != 10 {
+            t.Fatalf("index 0x%04X: %d samples, want 10", 0x2000+i, n)
+        }
+    }
+}
+
+func TestNMTMasterBootstrap(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    master := lb.Open()
+    device := lb.Open()
+    defer func() { _ = master.Close(); _ = device.Close() }()
+
+    // Simulated nodes 0x11 and 0x12 answer NMT with heartbeats; 0x13 is absent.
+    go func() {
+        for {
+            f, err := device.Receive()
+            if err != nil { return }
+            var cmd NMT
+            if cmd.UnmarshalCANFrame(f) != nil || cmd.Node != 0 { continue }
+            state := StateOperational
+            if cmd.Command == NMTResetCommunication {
+                state = StateBootup
+            } else if cmd.Command != NMTStart {
+                continue
+            }
+            for _, node := range []NodeID{0x11, 0x12} {
+                hb, _ := Heartbeat{Node: node, State: state}.MarshalCANFrame()
+                _ = device.Send(hb)
+            }
+        }
+    }()
+
+    mux := canbus.NewMux(master)
+    defer mux.Close()
+    m := NewNMTMaster(master, mux, 200*time.Millisecond)
+
+    if err := m.Bootstrap(context.Background(), NewNodeSet(0x11, 0x12)); err != nil {
+        t.Fatalf("bootstrap: %v", err)
+    }
+
+    err := m.Bootstrap(context.Background(), NewNodeSet(0x11, 0x13))
+    var ne NodeError
+    if !errors.As(err, &ne) || ne.Node != 0x13 || !errors.Is(err, ErrStateTimeout) {
+        t.Fatalf("expected timeout for node 0x13, got %v", err)
+    }
+    if strings.Contains(err.Error(), "0x11") {
+        t.Fatalf("node 0x11 unexpectedly failed: %v", err)
+    }
+
+    // Invalid node ids are rejected rather than treated as already reached.
+    for _, node := range []NodeID{0, 128} {
+        if err := m.WaitForState(context.Background(), node, StateOperational); err == nil {
+            t.Fatalf("WaitForState(%d) succeeded", node)
+        }
+    }
+
+    if n := NewNodeSet(1, 0x11, 63, 64, 127, 0, 128).Len(); n != 5 {
+        t.Fatalf("Len = %d, want 5", n)
+    }
+}
+
+func TestSDOString(t *testing.T) {
+    rx := func(b ...byte) canbus.Frame { return canbus.MustStandardFrame(COBID(FC_SDO_RX, 0x05), b) }
+    tx := func(b ...byte) canbus.Frame { return canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x05), b) }
+    cases := []struct {
+        f    canbus.Frame
+        want string
+    }{
+        {rx(0x2B, 0x00, 0x20, 0x01, 0xAA, 0xBB, 0, 0), "sdo rx node=0x05 download initiate expedited s=1 n=2 idx=2000:01 data=AABB"},
+        {rx(0x21, 0x50, 0x1F, 0x01, 0x20, 0, 0, 0), "sdo rx node=0x05 download initiate segmented s=1 idx=1F50:01 size=32"},
+        {tx(0x43, 0x18, 0x10, 0x01, 1, 2, 3, 4), "sdo tx node=0x05 upload initiate response expedited s=1 n=0 idx=1018:01 data=01020304"},
+        {rx(0x40, 0x18, 0x10, 0x01, 0, 0, 0, 0), "sdo rx node=0x05 upload initiate idx=1018:01"},
+        {tx(0x60, 0x00, 0x20, 0x01, 0, 0, 0, 0), "sdo tx node=0x05 download initiate response idx=2000:01"},
+        {tx(0x17, 1, 2, 3, 4, 0, 0, 0), "sdo tx node=0x05 upload segment toggle=1 last n=3 data=01020304"},
+        {rx(0x00, 1, 2, 3, 4, 5, 6, 7), "sdo rx node=0x05 download segment toggle=0 n=0 data=01020304050607"},
+        {rx(0x70, 0, 0, 0, 0, 0, 0, 0), "sdo rx node=0x05 upload segment request toggle=1"},
+        {tx(0x30, 0, 0, 0, 0, 0, 0, 0), "sdo tx node=0x05 download segment response toggle=1"},
+        {tx(0x80, 0x00, 0x60, 0x00, 0x00, 0x00, 0x02, 0x06), "sdo tx node=0x05 abort idx=6000:00 code=0x06020000 (object does not exist)"},
+        {rx(0xC6, 0x00, 0x1F, 0x01, 0x00, 0x01, 0, 0), "sdo rx node=0x05 block download initiate crc=1 s=1 idx=1F00:01 size=256"},
+        {tx(0xA2, 0x7F, 0x7F, 0, 0, 0, 0, 0), "sdo tx node=0x05 block download ack seqno=127 blksize=127"},
+        {rx(0xA3, 0, 0, 0, 0, 0, 0, 0), "sdo rx node=0x05 block upload start"},
+        {canbus.MustStandardFrame(0x705, []byte{0x05}), "705 [1] 05"},
+    }
+    for _, tc := range cases {
+        if got := SDOString(tc.f); got != tc.want {
+            t.Errorf("SDOString(%v)\n got %q\nwant %q", tc.f, got, tc.want)
+        }
+    }
+}
+
+func TestSDOUploadFourByteExpeditedEncodings(t *testing.T) {
+    // Spec-mode and CiA 301 (classic) encodings of an expedited upload
+    // response, including n=0 (all four bytes significant).
+    cases := []struct {
+        cmd  byte
+        want []byte
+    }{
+        {0x4C, []byte{1, 2, 3, 4}}, // spec mode, n=0
+        {0x43, []byte{1, 2, 3, 4}}, // classic, n=0
+        {0x42, []byte{1, 2, 3, 4}}, // classic, size not indicated
+        {0x47, []byte{1, 2, 3}},    // classic, n=1
+        {0x4E, []byte{1, 2}},       // spec mode, n=2
+        {0x4B, []byte{1, 2}},       // classic, n=2
+        {0x4F, []byte{1}},          // both, n=3
+    }
+    for _, tc := range cases {
+        f := canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x10), []byte{tc.cmd, 0x18, 0x10, 0x01, 1, 2, 3, 4})
+        _, idx, sub, data, err := parseSDOExpeditedUploadResponse(f)
+        if err != nil || idx != 0x1018 || sub != 0x01 || !bytes.Equal(data, tc.want) {
+            t.Fatalf("cmd 0x%02X: data=% X err=%v", tc.cmd, data, err)
+        }
+    }
+
+    for _, cmd := range []byte{0x4C, 0x43} {
+        lb := canbus.NewLoopbackBus()
+        client := lb.Open()
+        server := lb.Open()
+        go func(cmd byte) {
+            for {
+                f, err := server.Receive()
+                if err != nil { return }
+                if f.ID != COBID(FC_SDO_RX, 0x10) { continue }
+                rsp := canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x10), []byte{cmd, f.Data[1], f.Data[2], f.Data[3], 0x78, 0x56, 0x34, 0x12})
+                _ = server.Send(rsp)
+            }
+        }(cmd)
+        mux := canbus.NewMux(client)
+        c := NewSDOClient(client, 0x10, mux, WithTimeout(time.Second))
+        v, err := c.ReadU32(0x1018, 0x01)
+        _ = mux.Close(); _ = client.Close(); _ = server.Close()
+        if err != nil || v != 0x12345678 {
+            t.Fatalf("cmd 0x%02X: ReadU32 = 0x%08X err=%v", cmd, v, err)
+        }
+    }
+}
+
+func TestSDOUploadExpeditedWithoutSize(t *testing.T) {
+    // Legacy servers answer with e=1, s=0 and 4 data bytes.
+    for _, cmd := range []byte{0x42, 0x48} {
+        lb := canbus.NewLoopbackBus()
+        client := lb.Open()
+        server := lb.Open()
+        go func(cmd byte) {
+            for {
+                f, err := server.Receive()
+                if err != nil { return }
+                if f.ID != COBID(FC_SDO_RX, 0x12) { continue }
+                rsp := canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x12), []byte{cmd, f.Data[1], f.Data[2], f.Data[3], 0xDE, 0xAD, 0xBE, 0xEF})
+                _ = server.Send(rsp)
+            }
+        }(cmd)
+        mux := canbus.NewMux(client)
+        c := NewSDOClient(client, 0x12, mux, WithTimeout(time.Second))
+        got, err := c.Upload(0x2100, 0x02)
+        _ = mux.Close(); _ = client.Close(); _ = server.Close()
+        if err != nil || !bytes.Equal(got, []byte{0xDE, 0xAD, 0xBE, 0xEF}) {
+            t.Fatalf("cmd 0x%02X: upload % X err=%v", cmd, got, err)
+        }
+    }
+}
+
+func TestSDOUploadSegmentToggleNotAlternated(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    aborts := make(chan canbus.Frame, 1)
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            if f.ID != COBID(FC_SDO_RX, 0x13) { continue }
+            var rsp []byte
+            switch f.Data[0] >> 5 {
+            case sdoCCSUploadInitiate:
+                // Segmented, size indicated: 14 bytes.
+                rsp = []byte{0x41, f.Data[1], f.Data[2], f.Data[3], 14, 0, 0, 0}
+            case sdoCCSUploadSegment:
+                // Broken server: always answers with toggle 0.
+                rsp = []byte{0x00, 1, 2, 3, 4, 5, 6, 7}
+            case sdoCCSAbort:
+                aborts <- f
+                continue
+            default:
+                continue
+            }
+            _ = server.Send(canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x13), rsp))
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x13, mux, WithTimeout(time.Second))
+    _, err := c.Upload(0x2200, 0x01)
+    if !errors.Is(err, ErrSDOToggle) {
+        t.Fatalf("expected ErrSDOToggle, got %v", err)
+    }
+    select {
+    case f := <-aborts:
+        if code := binary.LittleEndian.Uint32(f.Data[4:8]); code != 0x05030000 {
+            t.Fatalf("abort code 0x%08X, want 0x05030000", code)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("client did not abort the transfer")
+    }
+}
+
+func TestSDOUploadEmptyFinalSegment(t *testing.T) {
+    // c=1, n=7: last segment with no data bytes.
+    data, last, err := parseSDOUploadSegmentData(canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x14), []byte{0x1F, 0xAA, 0, 0, 0, 0, 0, 0}))
+    if err != nil || !last || len(data) != 0 {
+        t.Fatalf("empty final segment: data=% X last=%v err=%v", data, last, err)
+    }
+
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+    payload := []byte("fourteen bytes")
+    go func() {
+        seg := 0
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            if f.ID != COBID(FC_SDO_RX, 0x14) { continue }
+            var rsp []byte
+            switch f.Data[0] >> 5 {
+            case sdoCCSUploadInitiate:
+                // Segmented, size not indicated.
+                rsp = []byte{0x40, f.Data[1], f.Data[2], f.Data[3], 0, 0, 0, 0}
+            case sdoCCSUploadSegment:
+                toggle := f.Data[0] & 0x10
+                if seg < 2 {
+                    rsp = append([]byte{toggle}, payload[seg*7:seg*7+7]...)
+                } else {
+                    // Final segment carries no data; padding must be ignored.
+                    rsp = []byte{toggle | 7<<1 | 1, 0xEE, 0xEE, 0xEE, 0xEE, 0xEE, 0xEE, 0xEE}
+                }
+                seg++
+            default:
+                continue
+            }
+            _ = server.Send(canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x14), rsp))
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x14, mux, WithTimeout(time.Second))
+    got, err := c.Upload(0x2300, 0x00)
+    if err != nil || !bytes.Equal(got, payload) {
+        t.Fatalf("upload %q err=%v, want %q", got, err, payload)
+    }
+}
+
+func TestSDOTimeoutDistinctFromClosed(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    defer client.Close()
+    mux := canbus.NewMux(lb.Open())
+
+    // No server on the bus: the request times out.
+    c := NewSDOClient(client, 0x15, mux, WithTimeout(20*time.Millisecond))
+    _, err := c.Upload(0x1000, 0x00)
+    if !errors.Is(err, ErrSDOTimeout) || errors.Is(err, canbus.ErrClosed) {
+        t.Fatalf("expected ErrSDOTimeout, got %v", err)
+    }
+
+    // Closing the mux mid-transfer reports closure instead.
+    go func() {
+        time.Sleep(20 * time.Millisecond)
+        _ = mux.Close()
+    }()
+    c = NewSDOClient(client, 0x15, mux, WithTimeout(time.Second))
+    if _, err := c.Upload(0x1000, 0x00); !errors.Is(err, canbus.ErrClosed) {
+        t.Fatalf("expected canbus.ErrClosed, got %v", err)
+    }
+}
+
+func TestSDOTimeoutWithBusLatency(t *testing.T) {
+    lb := canbus.NewLoopbackBusWithOptions(canbus.LoopbackOptions{MinDelay: 40 * time.Millisecond, MaxDelay: 50 * time.Millisecond})
+    defer lb.Close()
+    client := lb.Open()
+    server := lb.Open()
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil {
+                return
+            }
+            if f.ID != COBID(FC_SDO_RX, 0x16) || f.Data[0] != 0x40 {
+                continue
+            }
+            rsp := []byte{0x43, f.Data[1], f.Data[2], f.Data[3], 0x01, 0x00, 0x00, 0x00}
+            _ = server.Send(canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x16), rsp))
+        }
+    }()
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+
+    // The response takes longer than the timeout: the client gives up.
+    c := NewSDOClient(client, 0x16, mux, WithTimeout(20*time.Millisecond))
+    if _, err := c.Upload(0x1000, 0x00); !errors.Is(err, ErrSDOTimeout) {
+        t.Fatalf("expected ErrSDOTimeout, got %v", err)
+    }
+
+    // A timeout above the latency succeeds.
+    c = NewSDOClient(client, 0x16, mux, WithTimeout(time.Second))
+    got, err := c.Upload(0x1000, 0x00)
+    if err != nil || !bytes.Equal(got, []byte{0x01, 0x00, 0x00, 0x00}) {
+        t.Fatalf("upload %x err=%v", got, err)
+    }
+}
+
+func TestSDOWithInjectedFaults(t *testing.T) {
+    var mode atomic.Int32 // 0: pass, 1: drop responses, 2: corrupt responses into an abort
+    lb := canbus.NewLoopbackBusWithOptions(canbus.LoopbackOptions{
+        InjectFault: func(f canbus.Frame) (canbus.Frame, bool) {
+            if f.ID != COBID(FC_SDO_TX, 0x17) {
+                return f, true
+            }
+            switch mode.Load() {
+            case 1:
+                return f, false
+            case 2:
+                f.Data[0] = 0x80
+            }
+            return f, true
+        },
+    })
+    defer lb.Close()
+    client := lb.Open()
+    server := lb.Open()
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil {
+                return
+            }
+            if f.ID != COBID(FC_SDO_RX, 0x17) || f.Data[0] != 0x40 {
+                continue
+            }
+            rsp := []byte{0x43, f.Data[1], f.Data[2], f.Data[3], 0x01, 0x00, 0x00, 0x00}
+            _ = server.Send(canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x17), rsp))
+        }
+    }()
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x17, mux, WithTimeout(50*time.Millisecond))
+
+    mode.Store(1)
+    if _, err := c.Upload(0x1000, 0x00); !errors.Is(err, ErrSDOTimeout) {
+        t.Fatalf("dropped response: expected ErrSDOTimeout, got %v", err)
+    }
+    mode.Store(2)
+    var abort SDOAbort
+    if _, err := c.Upload(0x1000, 0x00); !errors.As(err, &abort) {
+        t.Fatalf("corrupted response: expected SDOAbort, got %v", err)
+    }
+    mode.Store(0)
+    if got, err := c.Upload(0x1000, 0x00); err != nil || !bytes.Equal(got, []byte{0x01, 0x00, 0x00, 0x00}) {
+        t.Fatalf("clean upload %x err=%v", got, err)
+    }
+}
+
+func TestSDOSegmentAbortCrossTalk(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    defer lb.Close()
+    client := lb.Open()
+    server := lb.Open()
+    abort := func(index uint16, subindex uint8) canbus.Frame {
+        var f canbus.Frame
+        f.ID = COBID(FC_SDO_TX, 0x18)
+        f.Len = 8
+        f.Data[0] = byte(sdoSCSAbort << 5)
+        binary.LittleEndian.PutUint16(f.Data[1:3], index)
+        f.Data[3] = subindex
+        binary.LittleEndian.PutUint32(f.Data[4:8], 0x06090011)
+        return f
+    }
+    // abortMux is the multiplexor the server uses to abort the second
+    // segment; a nil value lets the transfer complete.
+    abortMux := make(chan *[3]byte, 2)
+    go func() {
+        var mux *[3]byte
+        for {
+            f, err := server.Receive()
+            if err != nil {
+                return
+            }
+            if f.ID != COBID(FC_SDO_RX, 0x18) {
+                continue
+            }
+            rsp := canbus.Frame{ID: COBID(FC_SDO_TX, 0x18), Len: 8}
+            switch f.Data[0] >> 5 {
+            case sdoCCSDownloadInitiate:
+                mux = <-abortMux
+                rsp.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+                copy(rsp.Data[1:4], f.Data[1:4])
+            case sdoCCSDownloadSegment:
+                if f.Data[0]&0x10 != 0 && mux != nil {
+                    _ = server.Send(abort(binary.LittleEndian.Uint16(mux[0:2]), mux[2]))
+                    continue
+                }
+                // A stray abort for another object precedes every ack.
+                _ = server.Send(abort(0x3000, 0x00))
+                rsp.Data[0] = byte(sdoSCSDownloadSegment<<5) | f.Data[0]&0x10
+            default:
+                continue
+            }
+            _ = server.Send(rsp)
+        }
+    }()
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x18, mux, WithTimeout(time.Second))
+    data := bytes.Repeat([]byte{0x55}, 20)
+
+    abortMux <- nil
+    if err := c.Download(0x2000, 0x01, data); err != nil {
+        t.Fatalf("stray abort for 3000:00 failed the transfer: %v", err)
+    }
+
+    // An abort without a multiplexor still ends the segment phase.
+    abortMux <- &[3]byte{}
+    var ab SDOAbort
+    if err := c.Download(0x2000, 0x01, data); !errors.As(err, &ab) || ab.Code != 0x06090011 {
+        t.Fatalf("expected SDOAbort 0x06090011, got %v", err)
+    }
+}
+
+func TestMeasureSDOThroughput(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    defer lb.Close()
+    client := lb.Open()
+    server := lb.Open()
+    payload := []byte("twenty-one bytes long")
+    go func() {
+        off := 0
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            if f.ID != COBID(FC_SDO_RX, 0x19) { continue }
+            var rsp []byte
+            switch f.Data[0] >> 5 {
+            case sdoCCSUploadInitiate:
+                off = 0
+                rsp = []byte{0x41, f.Data[1], f.Data[2], f.Data[3], byte(len(payload)), 0, 0, 0}
+            case sdoCCSUploadSegment:
+                cmd := f.Data[0] & 0x10
+                if off+7 >= len(payload) {
+                    cmd |= 1
+                }
+                rsp = append([]byte{cmd}, payload[off:off+7]...)
+                off += 7
+            default:
+                continue
+            }
+            _ = server.Send(canbus.MustStandardFrame(COBID(FC_SDO_TX, 0x19), rsp))
+        }
+    }()
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    c := NewSDOClient(client, 0x19, mux, WithTimeout(time.Second))
+
+    res, err := MeasureSDOThroughput(c, 0x2400, 0x00, 50)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if res.Transfers != 3 || res.Bytes != 63 || res.Duration <= 0 || res.BytesPerSecond() <= 0 {
+        t.Fatalf("unexpected result %+v (%.0f B/s)", res, res.BytesPerSecond())
+    }
+    if res, err := MeasureSDOThroughput(c, 0x2400, 0x00, 0); err != nil || res.Transfers != 1 {
+        t.Fatalf("single upload: %+v %v", res, err)
+    }
+    if (SDOThroughput{}).BytesPerSecond() != 0 {
+        t.Fatal("empty measurement should report 0 B/s")
+    }
+}
+
+func TestKernelFilters(t *testing.T) {
+    fs := KernelFilters(COBID(FC_TPDO1, 5), COBID(FC_EMCY, 5))
+    if len(fs) != 2 {
+        t.Fatalf("got %d filters", len(fs))
+    }
+    match := func(f canbus.Frame) bool {
+        for _, k := range fs {
+            if k.Match(f) { return true }
+        }
+        return false
+    }
+    if !match(canbus.MustStandardFrame(0x185, nil)) || !match(canbus.MustStandardFrame(0x085, nil)) {
+        t.Fatal("expected TPDO1 and EMCY of node 5 to pass")
+    }
+    if match(canbus.MustStandardFrame(0x186, nil)) || match(canbus.MustExtendedFrame(0x185, nil)) {
+        t.Fatal("unexpected match")
+    }
+}
+
+func TestParseFrameCOBID(t *testing.T) {
+    fc, node, err := ParseFrameCOBID(canbus.MustStandardFrame(0x705, []byte{0x05}))
+    if err != nil || fc != FC_NMT_ERRCTRL || node != 0x05 {
+        t.Fatalf("standard: %v %v %v", fc, node, err)
+    }
+    ext := canbus.MustExtendedFrame(COBIDExt(FC_NMT_ERRCTRL, 0x05, 0x1234), []byte{0x05})
+    fc, node, err = ParseFrameCOBID(ext)
+    if err != nil || fc != FC_NMT_ERRCTRL || node != 0x05 {
+        t.Fatalf("extended: %v %v %v", fc, node, err)
+    }
+    if _, _, err := ParseCOBID(ext.ID); err == nil {
+        t.Fatal("ParseCOBID should still reject 29-bit ids")
+    }
+    hb, err := ParseHeartbeat(ext)
+    if err != nil || hb.Node != 0x05 || hb.State != StateOperational {
+        t.Fatalf("ParseHeartbeat extended: %+v %v", hb, err)
+    }
+}
+
+func TestErrorFramesAreNotCANopen(t *testing.T) {
+    // Controller error reports whose class bits look like CANopen COB-IDs.
+    for _, id := range []uint32{0x000, 0x080, 0x204, 0x705} {
+        f := canbus.Frame{ID: id, Error: true, Len: 8}
+        if _, _, err := ParseFrameCOBID(f); !errors.Is(err, ErrNotCANopenFrame) {
+            t.Fatalf("ParseFrameCOBID(%v) = %v", f, err)
+        }
+        if m, err := Classify(f); !errors.Is(err, ErrNotCANopenFrame) {
+            t.Fatalf("Classify(%v) = %T, %v", f, m, err)
+        }
+    }
+    for name, flt := range map[string]canbus.FrameFilter{
+        "NMT":       CANopenNMT(),
+        "SYNC":      CANopenSYNC(),
+        "EMCY":      CANopenEMCYAny(),
+        "TPDO1":     CANopenTPDO1Any(),
+        "RPDO1":     CANopenRPDO1(4),
+        "heartbeat": CANopenHeartbeat(5),
+    } {
+        for _, id := range []uint32{0x000, 0x080, 0x084, 0x184, 0x204, 0x705} {
+            if flt(canbus.Frame{ID: id, Error: true, Len: 8}) {
+                t.Fatalf("%s matched error frame 0x%03X", name, id)
+            }
+        }
+    }
+}
+
+func TestParserSentinelErrors(t *testing.T) {
+    emcy := canbus.MustStandardFrame(0x085, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+    _, err := ParseHeartbeat(emcy)
+    if !errors.Is(err, ErrNotHeartbeat) || !errors.Is(err, ErrWrongFunctionCode) {
+        t.Fatalf("heartbeat from emcy frame: %v", err)
+    }
+    if err.Error() != "canopen: not a heartbeat frame (id=0x85)" {
+        t.Fatalf("message changed: %q", err)
+    }
+    if _, err := ParseEMCY(canbus.MustStandardFrame(0x085, []byte{1})); !errors.Is(err, ErrFrameTooShort) {
+        t.Fatalf("short emcy: %v", err)
+    }
+    if _, err := ParseNMT(emcy); !errors.Is(err, ErrNotNMT) {
+        t.Fatalf("nmt: %v", err)
+    }
+    if _, err := ParseSYNC(canbus.MustStandardFrame(0x080, []byte{1, 2})); !errors.Is(err, ErrInvalidLength) {
+        t.Fatalf("sync: %v", err)
+    }
+    if _, _, _, _, err := parseSDOExpeditedUploadResponse(emcy); !errors.Is(err, ErrNotSDOFrame) {
+        t.Fatalf("sdo: %v", err)
+    }
+    seg := canbus.MustStandardFrame(0x585, []byte{0x60, 0, 0, 0, 0, 0, 0, 0})
+    if _, _, _, _, err := parseSDOExpeditedUploadResponse(seg); !errors.Is(err, ErrUnexpectedSDOCommand) {
+        t.Fatalf("sdo command: %v", err)
+    }
+}
+
+func TestCANopenGuardBus(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    raw := lb.Open()
+    defer raw.Close()
+    srdo := canbus.ByID(0x101)
+    g := NewCANopenGuardBus(lb.Open(), GuardDrop, srdo)
+    defer g.Close()
+
+    for _, f := range []canbus.Frame{
+        canbus.MustExtendedFrame(0x185, nil),
+        canbus.MustStandardFrame(0x7E4, nil),
+    } {
+        if err := g.Send(f); !errors.Is(err, ErrNotCANopenFrame) {
+            t.Fatalf("Send(%v) = %v, want ErrNotCANopenFrame", f, err)
+        }
+    }
+    if err := g.Send(canbus.MustStandardFrame(0x185, []byte{1})); err != nil {
+        t.Fatal(err)
+    }
+    if err := g.Send(canbus.MustStandardFrame(0x101, []byte{1})); err != nil {
+        t.Fatalf("allowed frame rejected: %v", err)
+    }
+
+    if err := g.Send(canbus.Frame{ID: 0x080, Error: true, Len: 8}); !errors.Is(err, ErrNotCANopenFrame) {
+        t.Fatalf("Send(error frame) = %v, want ErrNotCANopenFrame", err)
+    }
+
+    _ = raw.Send(canbus.MustExtendedFrame(0x1ABCDEF, nil))
+    _ = raw.Send(canbus.MustStandardFrame(0x7E5, nil))
+    _ = raw.Send(canbus.Frame{ID: 0x705, Error: true, Len: 8})
+    _ = raw.Send(canbus.MustStandardFrame(0x705, []byte{0x05}))
+    f, err := g.Receive()
+    if err != nil || f.ID != 0x705 || f.Error {
+        t.Fatalf("Receive = %v, %v; want heartbeat after dropped frames", f, err)
+    }
+
+    pass := NewCANopenGuardBus(lb.Open(), GuardPass, nil)
+    defer pass.Close()
+    _ = raw.Send(canbus.MustExtendedFrame(0x1ABCDEF, nil))
+    if f, err := pass.Receive(); err != nil || !f.Extended {
+        t.Fatalf("GuardPass Receive = %v, %v", f, err)
+    }
+}
+
+func TestSYNCWriterSetInterval(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    tx := lb.Open()
+    rx := lb.Open()
+    defer func() { _ = tx.Close(); _ = rx.Close() }()
+
+    w := NewSYNCWriter(tx, 200*time.Millisecond, false)
+    if err := w.SetInterval(0); err == nil {
+        t.Fatal("expected error for zero interval")
+    }
+    if err := w.SetInterval(-time.Second); err == nil {
+        t.Fatal("expected error for negative interval")
+    }
+    w.Start()
+    defer w.Stop()
+
+    if _, err := rx.Receive(); err != nil { t.Fatal(err) }
+    if err := w.SetInterval(10 * time.Millisecond); err != nil { t.Fatal(err) }
+    if w.Period() != 10*time.Millisecond {
+        t.Fatalf("Period = %v", w.Period())
+    }
+    start := time.Now()
+    for i := 0; i < 5; i++ {
+        if _, err := rx.Receive(); err != nil { t.Fatal(err) }
+    }
+    // Five frames at the old 200ms cadence would take a second.
+    if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+        t.Fatalf("5 SYNCs took %v after switching to 10ms", elapsed)
+    }
+}
+
+func TestTimeOfDayEncoding(t *testing.T) {
+    epoch := time.Date(1984, time.January, 1, 0, 0, 0, 0, time.UTC)
+    if b := EncodeTimeOfDay(epoch); b != ([6]byte{}) {
+        t.Fatalf("epoch encodes as % X", b)
+    }
+    if b := EncodeTimeOfDay(epoch.Add(-time.Hour)); b != ([6]byte{}) {
+        t.Fatalf("pre-epoch should clamp to zero, got % X", b)
+    }
+
+    // Midnight rollover: last millisecond of day 1 and the next one.
+    last := epoch.Add(48*time.Hour - time.Millisecond)
+    b := EncodeTimeOfDay(last)
+    if want := [6]byte{0xFF, 0x5B, 0x26, 0x05, 0x01, 0x00}; b != want {
+        t.Fatalf("23:59:59.999 day 1: % X, want % X", b, want)
+    }
+    b = EncodeTimeOfDay(last.Add(time.Millisecond))
+    if want := [6]byte{0, 0, 0, 0, 0x02, 0x00}; b != want {
+        t.Fatalf("midnight day 2: % X, want % X", b, want)
+    }
+
+    now := time.Date(2024, time.March, 5, 13, 14, 15, 678_000_000, time.FixedZone("X", 3600))
+    if got := DecodeTimeOfDay(EncodeTimeOfDay(now)); !got.Equal(now) || got.Location() != time.UTC {
+        t.Fatalf("round trip: %v, want %v in UTC", got, now)
+    }
+
+    // Reserved upper bits are ignored; out-of-day milliseconds fail Validate.
+    if got := DecodeTimeOfDay([6]byte{0, 0, 0, 0xF0, 0, 0}); !got.Equal(epoch) {
+        t.Fatalf("reserved bits not ignored: %v", got)
+    }
+    if err := (TIME{Milliseconds: 86_400_000}).Validate(); err == nil {
+        t.Fatal("expected Validate to reject a full day of milliseconds")
+    }
+
+    d := 3*24*time.Hour + 90*time.Minute + 5*time.Millisecond
+    if got := DecodeTimeDifference(EncodeTimeDifference(d)); got != d {
+        t.Fatalf("time difference round trip: %v, want %v", got, d)
+    }
+    if b := EncodeTimeDifference(-time.Second); b != ([6]byte{}) {
+        t.Fatalf("negative difference: % X", b)
+    }
+}
+
+func TestExportedBuildParse(t *testing.T) {
+    nf := BuildNMT(NMTStop, 0x05)
+    if m, _ := (NMT{Command: NMTStop, Node: 0x05}).MarshalCANFrame(); !nf.Equal(m) {
+        t.Fatalf("BuildNMT %v, marshal %v", nf, m)
+    }
+    n, err := ParseNMT(nf)
+    if err != nil || n.Command != NMTStop || n.Node != 0x05 {
+        t.Fatalf("ParseNMT: %+v %v", n, err)
+    }
+
+    hf, err := BuildHeartbeat(0x07, StateOperational)
+    if err != nil { t.Fatal(err) }
+    h, err := ParseHeartbeat(hf)
+    if err != nil || h.Node != 0x07 || h.State != StateOperational {
+        t.Fatalf("ParseHeartbeat: %+v %v", h, err)
+    }
+    if _, err := BuildHeartbeat(0, StateOperational); err == nil {
+        t.Fatal("expected error for node 0")
+    }
+
+    em := Emergency{ErrorCode: 0x8130, ErrorRegister: 0x11, Manufacturer: [5]byte{1, 2, 3, 4, 5}}
+    ef, err := BuildEMCY(0x09, em)
+    if err != nil { t.Fatal(err) }
+    e, err := ParseEMCY(ef)
+    em.Node = 0x09
+    if err != nil || e != em {
+        t.Fatalf("ParseEMCY: %+v %v, want %+v", e, err, em)
+    }
+}
+
+func TestParseNMTStrict(t *testing.T) {
+    for _, cmd := range []NMTCommand{NMTStart, NMTStop, NMTEnterPreOperational, NMTResetNode, NMTResetCommunication} {
+        if !cmd.Valid() {
+            t.Fatalf("%#x should be valid", byte(cmd))
+        }
+        n, err := ParseNMTStrict(BuildNMT(cmd, 0x10))
+        if err != nil || n.Command != cmd || n.Node != 0x10 {
+            t.Fatalf("strict %#x: %+v %v", byte(cmd), n, err)
+        }
+    }
+    for _, b := range []byte{0x00, 0x03, 0x7F, 0x83, 0xFF} {
+        cmd := NMTCommand(b)
+        if cmd.Valid() {
+            t.Fatalf("%#x should be invalid", b)
+        }
+        f := BuildNMT(cmd, 0x10)
+        if _, err := ParseNMTStrict(f); !errors.Is(err, ErrUnknownNMTCommand) {
+            t.Fatalf("strict %#x: expected ErrUnknownNMTCommand, got %v", b, err)
+        }
+        // Lenient parsing still reports what was on the wire.
+        if n, err := ParseNMT(f); err != nil || n.Command != cmd {
+            t.Fatalf("lenient %#x: %+v %v", b, n, err)
+        }
+    }
+    if _, err := ParseNMTStrict(canbus.MustStandardFrame(0x000, []byte{0x01})); !errors.Is(err, ErrFrameTooShort) {
+        t.Fatalf("short frame: %v", err)
+    }
+}
+
+func TestNewEmergency(t *testing.T) {
+    e := NewEmergency(0x3210, ErrRegManufacturer, 0xAA, 0xBB)
+    if e.ErrorCode != 0x3210 || e.Manufacturer != [5]byte{0xAA, 0xBB} {
+        t.Fatalf("unexpected emergency %+v", e)
+    }
+    if r := e.Register(); r != ErrRegGeneric|ErrRegVoltage|ErrRegManufacturer || !r.Has(ErrRegVoltage) || r.Has(ErrRegCurrent) {
+        t.Fatalf("register = %#02x", e.ErrorRegister)
+    }
+    if e := NewEmergency(0x8110, 0); e.Register() != ErrRegGeneric|ErrRegCommunication {
+        t.Fatalf("CAN overrun register = %#02x", e.ErrorRegister)
+    }
+    if e := NewEmergency(0x0000, 0); e.ErrorRegister != 0 {
+        t.Fatalf("error reset register = %#02x, want 0", e.ErrorRegister)
+    }
+    e.Node = 0x05
+    f, err := e.MarshalCANFrame()
+    if err != nil { t.Fatal(err) }
+    if got, err := ParseEMCY(f); err != nil || got != e {
+        t.Fatalf("round trip %+v %v, want %+v", got, err, e)
+    }
+    defer func() {
+        if recover() == nil {
+            t.Fatal("expected panic for 6 manufacturer bytes")
+        }
+    }()
+    NewEmergency(0x1000, 0, 1, 2, 3, 4, 5, 6)
+}
+
+func TestLiveView(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    tx := lb.Open()
+    mux := canbus.NewMux(lb.Open())
+    defer func() { _ = tx.Close(); _ = mux.Close() }()
+
+    view := Monitor(mux)
+    defer view.Close()
+    view.SetHeartbeatPeriod(0x21, 20*time.Millisecond)
+
+    send := func(m FrameMarshaler) {
+        f, err := m.MarshalCANFrame()
+        if err != nil { t.Fatal(err) }
+        if err := tx.Send(f); err != nil { t.Fatal(err) }
+    }
+    send(Heartbeat{Node: 0x20, State: StateBootup})
+    send(Heartbeat{Node: 0x21, State: StateOperational})
+    send(Emergency{Node: 0x20, ErrorCode: 0x8110, ErrorRegister: 0x11})
+    send(Heartbeat{Node: 0x20, State: StatePreOperational})
+
+    var nodes []NodeStatus
+    deadline := time.Now().Add(time.Second)
+    for {
+        nodes = view.Nodes()
+        if len(nodes) == 2 && nodes[0].State == StatePreOperational && nodes[0].LastEmergency != nil {
+            break
+        }
+        if time.Now().After(deadline) { t.Fatalf("live view not updated: %+v", nodes) }
+        time.Sleep(5 * time.Millisecond)
+    }
+    if nodes[0].Node != 0x20 || nodes[0].LastEmergency.ErrorCode != 0x8110 {
+        t.Fatalf("node 0x20 status %+v", nodes[0])
+    }
+    if nodes[1].Node != 0x21 || nodes[1].State != StateOperational || nodes[1].Stale {
+        t.Fatalf("node 0x21 status %+v", nodes[1])
+    }
+
+    // Node 0x21 misses its 20ms heartbeat and becomes stale.
+    time.Sleep(40 * time.Millisecond)
+    if st := view.Nodes()[1]; !st.Stale {
+        t.Fatalf("node 0x21 not stale: %+v", st)
+    }
+}
+
+func TestSDOAsyncClient(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    // Server answers uploads of 0x2000 and aborts anything else.
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            if f.ID != COBID(FC_SDO_RX, 0x13) || f.Data[0]>>5 != sdoCCSUploadInitiate { continue }
+            rsp := canbus.Frame{ID: COBID(FC_SDO_TX, 0x13), Len: 8}
+            rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            if binary.LittleEndian.Uint16(f.Data[1:3]) == 0x2000 {
+                rsp.Data[0] = byte(sdoSCSUploadInitiate<<5) | 0x0D
+                rsp.Data[4], rsp.Data[5], rsp.Data[6] = 0xDE, 0xAD, 0xBE
+            } else {
+                rsp.Data[0] = byte(sdoSCSAbort << 5)
+                binary.LittleEndian.PutUint32(rsp.Data[4:8], 0x06020000)
+            }
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    if _, err := NewSDOAsyncClient(client, mux, 0); err == nil {
+        t.Fatal("expected node id validation error")
+    }
+    c, err := NewSDOAsyncClient(client, mux, 0x13, WithAsyncTimeout(time.Second))
+    if err != nil { t.Fatal(err) }
+
+    if res := <-c.UploadAsync(0x2000, 0x01); res.Err != nil || !bytes.Equal(res.Data, []byte{0xDE, 0xAD, 0xBE}) {
+        t.Fatalf("upload % X err=%v", res.Data, res.Err)
+    }
+    var ab SDOAbort
+    if res := <-c.UploadAsync(0x6000, 0x00); !errors.As(res.Err, &ab) || ab.Code != 0x06020000 || ab.Index != 0x6000 {
+        t.Fatalf("expected abort, got %v", res.Err)
+    }
+}
+
+func TestSDOAsyncClientAbortsArePrompt(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    // Server aborts every request, echoing its index/subindex.
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            if f.ID != COBID(FC_SDO_RX, 0x14) { continue }
+            rsp := canbus.Frame{ID: COBID(FC_SDO_TX, 0x14), Len: 8}
+            rsp.Data[0] = byte(sdoSCSAbort << 5)
+            rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            binary.LittleEndian.PutUint32(rsp.Data[4:8], 0x06010002)
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    const timeout = 2 * time.Second
+    c, err := NewSDOAsyncClient(client, mux, 0x14, WithAsyncTimeout(timeout))
+    if err != nil { t.Fatal(err) }
+
+    for name, ch := range map[string]<-chan SDOResult{
+        "download": c.DownloadAsync(0x1000, 0x00, []byte{1, 2}),
+        "upload":   c.UploadAsync(0x1001, 0x00),
+    } {
+        start := time.Now()
+        res := <-ch
+        var ab SDOAbort
+        if !errors.As(res.Err, &ab) || ab.Code != 0x06010002 {
+            t.Fatalf("%s: expected write-only/read-only abort, got %v", name, res.Err)
+        }
+        if elapsed := time.Since(start); elapsed > timeout/2 {
+            t.Fatalf("%s: abort delivered after %v", name, elapsed)
+        }
+    }
+}
+
+func TestSDOExpeditedExportedBuilders(t *testing.T) {
+    for _, mode := range []ExpeditedMode{ExpeditedModeSpec, ExpeditedModeClassic} {
+        for n := 1; n <= 4; n++ {
+            data := []byte{0x11, 0x22, 0x33, 0x44}[:n]
+            f, err := SDOExpeditedDownload(0x21, 0x2000, 0x03, data, mode)
+            if err != nil { t.Fatalf("mode %d n=%d: %v", mode, n, err) }
+            if f.ID != 0x621 || f.Len != 8 || f.Data[0]>>5 != sdoCCSDownloadInitiate ||
+                binary.LittleEndian.Uint16(f.Data[1:3]) != 0x2000 || f.Data[3] != 0x03 ||
+                !bytes.Equal(f.Data[4:4+n], data) {
+                t.Fatalf("mode %d n=%d: frame %v", mode, n, f)
+            }
+        }
+    }
+    if f, _ := SDOExpeditedDownload(0x21, 0x2000, 0x03, []byte{1, 2}, ExpeditedModeClassic); f.Data[0] != 0x2B {
+        t.Fatalf("classic 2-byte cmd 0x%02X, want 0x2B", f.Data[0])
+    }
+    if _, err := SDOExpeditedDownload(0x21, 0x2000, 0, nil, ExpeditedModeSpec); err == nil {
+        t.Fatal("expected error for empty data")
+    }
+    if _, err := SDOExpeditedDownload(0, 0x2000, 0, []byte{1}, ExpeditedModeSpec); err == nil {
+        t.Fatal("expected node id error")
+    }
+
+    f, err := SDOExpeditedUploadRequest(0x21, 0x1018, 0x01)
+    if err != nil { t.Fatal(err) }
+    if f.ID != 0x621 || f.Data[0] != byte(sdoCCSUploadInitiate<<5) || binary.LittleEndian.Uint16(f.Data[1:3]) != 0x1018 || f.Data[3] != 0x01 {
+        t.Fatalf("upload request %v", f)
+    }
+}
+
+func TestSDOAsyncDownloadValues(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    server := lb.Open()
+    defer func() { _ = client.Close(); _ = server.Close() }()
+
+    // Server records each download command byte and payload.
+    type write struct { cmd byte; data []byte }
+    writes := make(chan write, 8)
+    go func() {
+        for {
+            f, err := server.Receive()
+            if err != nil { return }
+            if f.ID != COBID(FC_SDO_RX, 0x15) || f.Data[0]>>5 != sdoCCSDownloadInitiate { continue }
+            _, _, _, data, err := parseSDOExpeditedDownload(f)
+            if err != nil { // classic encoding: n in bits 3..2
+                data = append([]byte(nil), f.Data[4:8-int(f.Data[0]>>2&3)]...)
+            }
+            writes <- write{f.Data[0], data}
+            rsp := canbus.Frame{ID: COBID(FC_SDO_TX, 0x15), Len: 8}
+            rsp.Data[0] = byte(sdoSCSDownloadInitiate << 5)
+            rsp.Data[1], rsp.Data[2], rsp.Data[3] = f.Data[1], f.Data[2], f.Data[3]
+            _ = server.Send(rsp)
+        }
+    }()
+
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    classicCmd := map[int]byte{1: 0x2F, 2: 0x2B, 3: 0x27, 4: 0x23}
+    for _, mode := range []ExpeditedMode{ExpeditedModeSpec, ExpeditedModeClassic} {
+        c, err := NewSDOAsyncClient(client, mux, 0x15, WithAsyncTimeout(time.Second), WithAsyncExpeditedMode(mode))
+        if err != nil { t.Fatal(err) }
+        for n := 1; n <= 4; n++ {
+            value := []byte{0xA1, 0xB2, 0xC3, 0xD4}[:n]
+            if res := <-c.DownloadAsync(0x2000, uint8(n), value); res.Err != nil {
+                t.Fatalf("mode %d n=%d: %v", mode, n, res.Err)
+            }
+            w := <-writes
+            if !bytes.Equal(w.data, value) {
+                t.Fatalf("mode %d n=%d: server got % X", mode, n, w.data)
+            }
+            if mode == ExpeditedModeClassic && w.cmd != classicCmd[n] {
+                t.Fatalf("classic n=%d: cmd 0x%02X, want 0x%02X", n, w.cmd, classicCmd[n])
+            }
+        }
+    }
+}
+
+func TestSDOZeroLengthDownloadRejected(t *testing.T) {
+    if _, err := sdoExpeditedDownload(0x10, 0x2000, 0, nil); err == nil {
+        t.Fatal("spec builder accepted empty payload")
+    }
+    if _, err := sdoExpeditedDownloadClassic(0x10, 0x2000, 0, nil); err == nil {
+        t.Fatal("classic builder accepted empty payload")
+    }
+
+    lb := canbus.NewLoopbackBus()
+    client := lb.Open()
+    defer client.Close()
+    mux := canbus.NewMux(client)
+    defer mux.Close()
+    // No server: an empty write must fail fast rather than reach the bus.
+    c := NewSDOClient(client, 0x10, mux, WithTimeout(time.Second))
+    if err := c.Download(0x2000, 0, nil); err == nil {
+        t.Fatal("Download accepted empty payload")
+    }
+    ac, _ := NewSDOAsyncClient(client, mux, 0x10)
+    if res := <-ac.DownloadAsync(0x2000, 0, []byte{}); res.Err == nil {
+        t.Fatal("DownloadAsync accepted empty payload")
+    }
+}
+
+func TestSendAndSendAll(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    tx := lb.Open()
+    rx := lb.Open()
+    defer func() { _ = tx.Close(); _ = rx.Close() }()
+
+    if err := Send(tx, Heartbeat{Node: 0x05, State: StateOperational}); err != nil { t.Fatal(err) }
+    if f, err := rx.Receive(); err != nil || f.ID != 0x705 || f.Data[0] != byte(StateOperational) {
+        t.Fatalf("heartbeat %v err=%v", f, err)
+    }
+
+    // SendAll stops at the first marshal error: the invalid EMCY node.
+    err := SendAll(tx,
+        NMT{Command: NMTStart},
+        Emergency{Node: 0, ErrorCode: 0x1000},
+        SYNC{},
+    )
+    if err == nil { t.Fatal("expected marshal error") }
+    if f, err := rx.Receive(); err != nil || f.ID != 0x000 {
+        t.Fatalf("nmt %v err=%v", f, err)
+    }
+    if err := SendAll(tx, SYNC{}); err != nil { t.Fatal(err) }
+    if f, err := rx.Receive(); err != nil || f.ID != 0x080 {
+        t.Fatalf("expected SYNC after failed SendAll, got %v err=%v", f, err)
+    }
+
+    _ = tx.Close()
+    if err := Send(tx, SYNC{}); !errors.Is(err, canbus.ErrClosed) {
+        t.Fatalf("send on closed bus: %v", err)
+    }
+}
+
+func TestReceiveInto(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    tx := lb.Open()
+    mux := canbus.NewMux(lb.Open())
+    defer func() { _ = tx.Close(); _ = mux.Close() }()
+
+    go func() {
+        time.Sleep(10 * time.Millisecond)
+        _ = Send(tx, Heartbeat{Node: 0x06, State: StateStopped})
+        _ = Send(tx, Heartbeat{Node: 0x05, State: StateOperational})
+    }()
+    var hb Heartbeat
+    if err := ReceiveInto(mux, CANopenHeartbeat(0x05), &hb, time.Second); err != nil { t.Fatal(err) }
+    if hb.Node != 0x05 || hb.State != StateOperational {
+        t.Fatalf("heartbeat %+v", hb)
+    }
+
+    if err := ReceiveInto(mux, CANopenHeartbeat(0x07), &hb, 20*time.Millisecond); !errors.Is(err, ErrReceiveTimeout) {
+        t.Fatalf("expected timeout, got %v", err)
+    }
+}
+
+func FuzzParseFrames(f *testing.F) {
+    f.Add(uint32(0x000), false, false, uint8(2), []byte{0x01, 0x05})
+    f.Add(uint32(0x085), false, false, uint8(8), []byte{0x10, 0x81, 0x11, 1, 2, 3, 4, 5})
+    f.Add(uint32(0x705), false, false, uint8(1), []byte{0x05})
+    f.Add(uint32(0x585), false, false, uint8(8), []byte{0x41, 0x00, 0x20, 0x01, 14, 0, 0, 0})
+    f.Add(uint32(0x101), false, false, uint8(12), []byte{1, 2, 3})
+    f.Fuzz(func(t *testing.T, id uint32, ext, rtr bool, n uint8, data []byte) {
+        fr := canbus.Frame{ID: id, Extended: ext, RTR: rtr, Len: n}
+        copy(fr.Data[:], data)
+        // Only errors are acceptable; any panic fails the fuzz run.
+        _, _ = ParseEMCY(fr)
+        _, _ = ParseHeartbeat(fr)
+        _, _ = ParseNMT(fr)
+        _, _ = ParseSYNC(fr)
+        _, _ = ParseTIME(fr)
+        _, _ = Classify(fr)
+        _ = SDOString(fr)
+        _, _, _, _, _ = parseSDOExpeditedDownload(fr)
+        _, _, _, _, _ = parseSDOExpeditedUploadResponse(fr)
+        _, _, _ = parseSDOUploadSegmentData(fr)
+        _, _, _ = parseSDOAbort(fr)
+        var s SRDO
+        inv := fr
+        inv.ID++
+        _ = s.UnmarshalCANFrames(fr, inv)
+    })
+}
@@ -0,0 +1,413 @@
+// Package canopentest provides utilities for testing code built on the
+// canopen package, most notably SimNetwork, an in-memory network of virtual
+// CANopen nodes attached to a canbus.LoopbackBus.
+package canopentest
+
+import (
+    "encoding/binary"
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/notnil/canbus"
+    "github.com/notnil/canbus/canopen"
+)
+
+// ObjectKey addresses an object dictionary entry.
+type ObjectKey struct {
+    Index    uint16
+    Subindex uint8
+}
+
+// NodeConfig describes a virtual node added to a SimNetwork.
+type NodeConfig struct {
+    // ID is the node id (1..127).
+    ID canopen.NodeID
+    // Objects is the initial object dictionary; values are raw
+    // little-endian bytes. The map is copied by AddNode.
+    Objects map[ObjectKey][]byte
+    // HeartbeatPeriod is the heartbeat producer period; zero disables
+    // periodic heartbeats (the boot-up message is still sent).
+    HeartbeatPeriod time.Duration
+}
+
+// SimNetwork simulates multiple CANopen nodes on a shared loopback bus.
+//
+// All nodes are serviced by a single goroutine (plus one reader), regardless
+// of the number of nodes. Each node answers NMT commands, produces
+// heartbeats and serves SDO expedited and segmented transfers from its
+// object dictionary.
+type SimNetwork struct {
+    ep     canbus.Bus
+    frames chan canbus.Frame
+    add    chan *simNode
+    stop   chan struct{}
+    done   chan struct{}
+
+    mu    sync.Mutex
+    nodes map[canopen.NodeID]*simNode
+}
+
+type simNode struct {
+    id     canopen.NodeID
+    period time.Duration
+    next   time.Time
+
+    // guarded by SimNetwork.mu
+    state   canopen.NMTState
+    objects map[ObjectKey][]byte
+
+    // SDO transfer state, owned by the service goroutine.
+    xfer *sdoTransfer
+}
+
+type sdoTransfer struct {
+    download bool
+    key      ObjectKey
+    buf      []byte
+    pos      int
+    toggle   byte
+}
+
+// NewSimNetwork attaches a simulated network to bus and starts servicing it.
+func NewSimNetwork(bus *canbus.LoopbackBus) *SimNetwork {
+    n := &SimNetwork{
+        ep:     bus.Open(),
+        frames: make(chan canbus.Frame, 64),
+        add:    make(chan *simNode),
+        stop:   make(chan struct{}),
+        done:   make(chan struct{}),
+        nodes:  make(map[canopen.NodeID]*simNode),
+    }
+    go n.read()
+    go n.run()
+    return n
+}
+
+// AddNode registers a virtual node. The node boots into pre-operational
+// state after sending its boot-up message.
+func (n *SimNetwork) AddNode(cfg NodeConfig) error {
+    if err := cfg.ID.Validate(); err != nil {
+        return err
+    }
+    node := &simNode{
+        id:      cfg.ID,
+        period:  cfg.HeartbeatPeriod,
+        state:   canopen.StatePreOperational,
+        objects: make(map[ObjectKey][]byte, len(cfg.Objects)),
+    }
+    for k, v := range cfg.Objects {
+        node.objects[k] = append([]byte(nil), v...)
+    }
+    n.mu.Lock()
+    if _, ok := n.nodes[cfg.ID]; ok {
+        n.mu.Unlock()
+        return fmt.Errorf("canopentest: node %d already registered", cfg.ID)
+    }
+    n.nodes[cfg.ID] = node
+    n.mu.Unlock()
+    select {
+    case n.add <- node:
+        return nil
+    case <-n.done:
+        return canbus.ErrClosed
+    }
+}
+
+// State returns the current NMT state of a node.
+func (n *SimNetwork) State(id canopen.NodeID) (canopen.NMTState, bool) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    node, ok := n.nodes[id]
+    if !ok {
+        return 0, false
+    }
+    return node.state, true
+}
+
+// Object returns a copy of an object dictionary value of a node.
+func (n *SimNetwork) Object(id canopen.NodeID, index uint16, subindex uint8) ([]byte, bool) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    node, ok := n.nodes[id]
+    if !ok {
+        return nil, false
+    }
+    v, ok := node.objects[ObjectKey{index, subindex}]
+    return append([]byte(nil), v...), ok
+}
+
+// Close stops servicing and detaches the network from the bus.
+func (n *SimNetwork) Close() error {
+    select {
+    case <-n.stop:
+        return nil
+    default:
+    }
+    close(n.stop)
+    err := n.ep.Close()
+    <-n.done
+    return err
+}
+
+func (n *SimNetwork) read() {
+    defer close(n.frames)
+    for {
+        f, err := n.ep.Receive()
+        if err != nil {
+            return
+        }
+        select {
+        case n.frames <- f:
+        case <-n.stop:
+            return
+        }
+    }
+}
+
+func (n *SimNetwork) run() {
+    defer close(n.done)
+    var nodes []*simNode
+    timer := time.NewTimer(time.Hour)
+    defer timer.Stop()
+    for {
+        // Arm the timer for the earliest heartbeat due.
+        var next time.Time
+        for _, node := range nodes {
+            if node.period > 0 && (next.IsZero() || node.next.Before(next)) {
+                next = node.next
+            }
+        }
+        if !timer.Stop() {
+            select {
+            case <-timer.C:
+            default:
+            }
+        }
+        if !next.IsZero() {
+            timer.Reset(time.Until(next))
+        }
+
+        select {
+        case <-n.stop:
+            return
+        case node := <-n.add:
+            nodes = append(nodes, node)
+            n.sendHeartbeat(node, canopen.StateBootup)
+            node.next = time.Now().Add(node.period)
+        case f, ok := <-n.frames:
+            if !ok {
+                return
+            }
+            n.handle(nodes, f)
+        case now := <-timer.C:
+            for _, node := range nodes {
+                if node.period > 0 && !now.Before(node.next) {
+                    n.sendHeartbeat(node, n.stateOf(node))
+                    node.next = now.Add(node.period)
+                }
+            }
+        }
+    }
+}
+
+func (n *SimNetwork) stateOf(node *simNode) canopen.NMTState {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    return node.state
+}
+
+func (n *SimNetwork) sendHeartbeat(node *simNode, state canopen.NMTState) {
+    f, err := canopen.Heartbeat{Node: node.id, State: state}.MarshalCANFrame()
+    if err == nil {
+        _ = n.ep.Send(f)
+    }
+}
+
+func (n *SimNetwork) handle(nodes []*simNode, f canbus.Frame) {
+    if f.Extended || f.RTR {
+        return
+    }
+    var cmd canopen.NMT
+    if err := cmd.UnmarshalCANFrame(f); err == nil {
+        for _, node := range nodes {
+            if cmd.Node == 0 || cmd.Node == uint8(node.id) {
+                n.applyNMT(node, cmd.Command)
+            }
+        }
+        return
+    }
+    fc, id, err := canopen.ParseCOBID(f.ID)
+    if err != nil || fc != canopen.FC_SDO_RX || f.Len != 8 {
+        return
+    }
+    for _, node := range nodes {
+        if node.id == id {
+            n.serveSDO(node, f)
+            return
+        }
+    }
+}
+
+func (n *SimNetwork) applyNMT(node *simNode, cmd canopen.NMTCommand) {
+    n.mu.Lock()
+    switch cmd {
+    case canopen.NMTStart:
+        node.state = canopen.StateOperational
+    case canopen.NMTStop:
+        node.state = canopen.StateStopped
+    case canopen.NMTEnterPreOperational:
+        node.state = canopen.StatePreOperational
+    case canopen.NMTResetNode, canopen.NMTResetCommunication:
+        node.state = canopen.StatePreOperational
+        n.mu.Unlock()
+        node.xfer = nil
+        n.sendHeartbeat(node, canopen.StateBootup)
+        return
+    }
+    n.mu.Unlock()
+}
+
+// SDO command specifiers and abort codes used by the simulated server.
+const (
+    ccsDownloadSegment  = 0
+    ccsDownloadInitiate = 1
+    ccsUploadInitiate   = 2
+    ccsUploadSegment    = 3
+    ccsAbort            = 4
+
+    scsUploadSegment    = 0
+    scsDownloadSegment  = 1
+    scsUploadInitiate   = 2
+    scsDownloadInitiate = 3
+    scsAbort            = 4
+
+    abortToggle         uint32 = 0x05030000
+    abortCommandUnknown uint32 = 0x05040001
+    abortNoObject       uint32 = 0x06020000
+)
+
+func (n *SimNetwork) serveSDO(node *simNode, req canbus.Frame) {
+    cmd := req.Data[0]
+    key := ObjectKey{binary.LittleEndian.Uint16(req.Data[1:3]), req.Data[3]}
+    var rsp canbus.Frame
+    rsp.ID = canopen.COBID(canopen.FC_SDO_TX, node.id)
+    rsp.Len = 8
+
+    switch cmd >> 5 {
+    case ccsDownloadInitiate:
+        copy(rsp.Data[1:4], req.Data[1:4])
+        rsp.Data[0] = scsDownloadInitiate << 5
+        switch {
+        case cmd&0x0C == 0x0C:
+            // Expedited as encoded by canopen.ExpeditedModeSpec: e=bit3,
+            // s=bit2, n=bits1..0.
+            n.store(node, key, req.Data[4:8-int(cmd&0x3)])
+            node.xfer = nil
+        case cmd&0x02 != 0:
+            // Expedited with e=bit1, s=bit0, n=bits3..2 (classic encoding).
+            size := 4
+            if cmd&0x01 != 0 {
+                size = 4 - int((cmd>>2)&0x3)
+            }
+            n.store(node, key, req.Data[4:4+size])
+            node.xfer = nil
+        default:
+            node.xfer = &sdoTransfer{download: true, key: key}
+        }
+    case ccsDownloadSegment:
+        x := node.xfer
+        if x == nil || !x.download {
+            n.abort(node, ObjectKey{}, abortCommandUnknown)
+            return
+        }
+        t := (cmd >> 4) & 0x1
+        if t != x.toggle {
+            n.abort(node, x.key, abortToggle)
+            return
+        }
+        end := 8
+        last := cmd&0x1 != 0
+        if last {
+            end = 8 - int((cmd>>1)&0x7)
+        }
+        x.buf = append(x.buf, req.Data[1:end]...)
+        x.toggle ^= 1
+        rsp.Data[0] = scsDownloadSegment<<5 | t<<4
+        if last {
+            n.store(node, x.key, x.buf)
+            node.xfer = nil
+        }
+    case ccsUploadInitiate:
+        v, ok := n.load(node, key)
+        if !ok {
+            n.abort(node, key, abortNoObject)
+            return
+        }
+        copy(rsp.Data[1:4], req.Data[1:4])
+        if len(v) <= 4 {
+            rsp.Data[0] = scsUploadInitiate<<5 | 1<<3 | 1<<2 | byte(4-len(v))
+            copy(rsp.Data[4:8], v)
+            node.xfer = nil
+        } else {
+            rsp.Data[0] = scsUploadInitiate<<5 | 1<<2
+            binary.LittleEndian.PutUint32(rsp.Data[4:8], uint32(len(v)))
+            node.xfer = &sdoTransfer{key: key, buf: v}
+        }
+    case ccsUploadSegment:
+        x := node.xfer
+        if x == nil || x.download {
+            n.abort(node, ObjectKey{}, abortCommandUnknown)
+            return
+        }
+        t := (cmd >> 4) & 0x1
+        if t != x.toggle {
+            n.abort(node, x.key, abortToggle)
+            return
+        }
+        segLen := len(x.buf) - x.pos
+        if segLen > 7 {
+            segLen = 7
+        }
+        rsp.Data[0] = scsUploadSegment<<5 | t<<4
+        copy(rsp.Data[1:1+segLen], x.buf[x.pos:x.pos+segLen])
+        x.pos += segLen
+        x.toggle ^= 1
+        if x.pos == len(x.buf) {
+            rsp.Data[0] |= byte(7-segLen)<<1 | 1
+            node.xfer = nil
+        }
+    case ccsAbort:
+        node.xfer = nil
+        return
+    default:
+        n.abort(node, key, abortCommandUnknown)
+        return
+    }
+    _ = n.ep.Send(rsp)
+}
+
+func (n *SimNetwork) abort(node *simNode, key ObjectKey, code uint32) {
+    node.xfer = nil
+    var f canbus.Frame
+    f.ID = canopen.COBID(canopen.FC_SDO_TX, node.id)
+    f.Len = 8
+    f.Data[0] = scsAbort << 5
+    binary.LittleEndian.PutUint16(f.Data[1:3], key.Index)
+    f.Data[3] = key.Subindex
+    binary.LittleEndian.PutUint32(f.Data[4:8], code)
+    _ = n.ep.Send(f)
+}
+
+func (n *SimNetwork) store(node *simNode, key ObjectKey, v []byte) {
+    n.mu.Lock()
+    node.objects[key] = append([]byte(nil), v...)
+    n.mu.Unlock()
+}
+
+func (n *SimNetwork) load(node *simNode, key ObjectKey) ([]byte, bool) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    v, ok := node.objects[key]
+    return append([]byte(nil), v...), ok
+}
@@ -0,0 +1,102 @@
+package canopentest
+
+import (
+    "bytes"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/notnil/canbus"
+    "github.com/notnil/canbus/canopen"
+)
+
+func TestSimNetwork_NMTHeartbeatAndSDO(t *testing.T) {
+    lb := canbus.NewLoopbackBus()
+    defer lb.Close()
+
+    tx := lb.Open()
+    mux := canbus.NewMux(lb.Open())
+    defer mux.Close()
+    hbs, cancel := canopen.SubscribeHeartbeats(mux, nil, 32)
+    defer cancel()
+
+    sim := NewSimNetwork(lb)
+    defer sim.Close()
+
+    firmware := []byte("firmware image v1.2.3")
+    if err := sim.AddNode(NodeConfig{
+        ID:              0x10,
+        Objects:         map[ObjectKey][]byte{{0x1018, 0x01}: {0x78, 0x56, 0x34, 0x12}},
+        HeartbeatPeriod: 10 * time.Millisecond,
+    }); err != nil {
+        t.Fatal(err)
+    }
+    if err := sim.AddNode(NodeConfig{
+        ID:      0x11,
+        Objects: map[ObjectKey][]byte{{0x1F50, 0x01}: firmware},
+    }); err != nil {
+        t.Fatal(err)
+    }
+    if err := sim.AddNode(NodeConfig{ID: 0x10}); err == nil {
+        t.Fatal("expected duplicate node error")
+    }
+
+    // Boot-up from both nodes.
+    booted := map[canopen.NodeID]bool{}
+    for len(booted) < 2 {
+        select {
+        case hb := <-hbs:
+            if hb.State == canopen.StateBootup {
+                booted[hb.Node] = true
+            }
+        case <-time.After(time.Second):
+            t.Fatalf("boot-up not observed: %v", booted)
+        }
+    }
+
+    // NMT start node 0x10 only; its periodic heartbeat reports operational.
+    start, err := canopen.BuildNMTNode(canopen.NMTStart, 0x10)
+    if err != nil { t.Fatal(err) }
+    if err := tx.Send(start); err != nil { t.Fatal(err) }
+    deadline := time.After(time.Second)
+    for operational := false; !operational; {
+        select {
+        case hb := <-hbs:
+            operational = hb.Node == 0x10 && hb.State == canopen.StateOperational
+        case <-deadline:
+            t.Fatal("operational heartbeat not observed")
+        }
+    }
+    if st, _ := sim.State(0x11); st != canopen.StatePreOperational {
+        t.Fatalf("node 0x11 state 0x%02X", st)
+    }
+
+    // Expedited SDO against node 0x10.
+    c10 := canopen.NewSDOClient(tx, 0x10, mux, canopen.WithTimeout(time.Second))
+    v, err := c10.ReadU32(0x1018, 0x01)
+    if err != nil || v != 0x12345678 {
+        t.Fatalf("read u32: 0x%08X err=%v", v, err)
+    }
+    if err := c10.WriteU16(0x2000, 0x00, 0xBEEF); err != nil { t.Fatal(err) }
+    if got, _ := sim.Object(0x10, 0x2000, 0x00); !bytes.Equal(got, []byte{0xEF, 0xBE}) {
+        t.Fatalf("stored value % X", got)
+    }
+
+    // Segmented SDO against node 0x11.
+    c11 := canopen.NewSDOClient(tx, 0x11, mux, canopen.WithTimeout(time.Second))
+    got, err := c11.Upload(0x1F50, 0x01)
+    if err != nil || !bytes.Equal(got, firmware) {
+        t.Fatalf("segmented upload %q err=%v", got, err)
+    }
+    long := []byte("a longer value written in segments")
+    if err := c11.Download(0x2001, 0x00, long); err != nil { t.Fatal(err) }
+    if got, _ := sim.Object(0x11, 0x2001, 0x00); !bytes.Equal(got, long) {
+        t.Fatalf("segmented stored %q", got)
+    }
+
+    // Missing objects abort.
+    var ab canopen.SDOAbort
+    if _, err := c11.Upload(0x6000, 0x00); !errors.As(err, &ab) || ab.Code != 0x06020000 {
+        t.Fatalf("expected object does not exist abort, got %v", err)
+    }
+}
@@ -0,0 +1,111 @@
+package canopen
+
+import (
+    "fmt"
+
+    "github.com/notnil/canbus"
+)
+
+// CANopenMessage is a decoded CANopen frame returned by Classify. Use a type
+// switch to handle the concrete types: NMT, SYNC, TIME, Emergency, Heartbeat,
+// PDOFrame and SDOFrame.
+type CANopenMessage interface {
+    FrameMarshaler
+}
+
+// PDOFrame is a raw process data object. Its payload layout is defined by
+// the PDO mapping and is not interpreted here.
+type PDOFrame struct {
+    Function FunctionCode // FC_TPDO1..FC_RPDO4
+    Node     NodeID
+    Data     []byte
+}
+
+// MarshalCANFrame encodes the PDO to a CAN frame.
+func (p PDOFrame) MarshalCANFrame() (canbus.Frame, error) {
+    if len(p.Data) > 8 {
+        return canbus.Frame{}, fmt.Errorf("canopen: PDO data too long: %d", len(p.Data))
+    }
+    var f canbus.Frame
+    f.ID = COBID(p.Function, p.Node)
+    f.Len = uint8(len(p.Data))
+    copy(f.Data[:], p.Data)
+    return f, nil
+}
+
+// SDOFrame is a raw SDO request (FC_SDO_RX) or response (FC_SDO_TX).
+// SDO transfers are stateful, so single frames are not decoded further.
+type SDOFrame struct {
+    Function FunctionCode // FC_SDO_RX or FC_SDO_TX
+    Node     NodeID
+    Data     [8]byte
+}
+
+// MarshalCANFrame encodes the SDO frame to a CAN frame.
+func (s SDOFrame) MarshalCANFrame() (canbus.Frame, error) {
+    var f canbus.Frame
+    f.ID = COBID(s.Function, s.Node)
+    f.Len = 8
+    f.Data = s.Data
+    return f, nil
+}
+
+// Classify decodes any standard CANopen frame into its typed message. Error
+// frames fail with ErrNotCANopenFrame.
+func Classify(f canbus.Frame) (CANopenMessage, error) {
+    if f.Error {
+        return nil, fmt.Errorf("%w: error frame", ErrNotCANopenFrame)
+    }
+    if f.Extended {
+        return nil, fmt.Errorf("canopen: extended frame 0x%X is not CANopen", f.ID)
+    }
+    if int(f.Len) > len(f.Data) {
+        return nil, fmt.Errorf("canopen: invalid frame length %d", f.Len)
+    }
+    fc, node, err := ParseCOBID(f.ID)
+    if err != nil {
+        return nil, err
+    }
+    switch fc {
+    case FC_NMT:
+        var m NMT
+        if err := m.UnmarshalCANFrame(f); err != nil {
+            return nil, err
+        }
+        return m, nil
+    case FC_SYNC: // shares its base with FC_EMCY; node 0 is SYNC
+        if node == 0 {
+            var m SYNC
+            if err := m.UnmarshalCANFrame(f); err != nil {
+                return nil, err
+            }
+            return m, nil
+        }
+        var m Emergency
+        if err := m.UnmarshalCANFrame(f); err != nil {
+            return nil, err
+        }
+        return m, nil
+    case FC_TIME:
+        var m TIME
+        if err := m.UnmarshalCANFrame(f); err != nil {
+            return nil, err
+        }
+        return m, nil
+    case FC_NMT_ERRCTRL:
+        var m Heartbeat
+        if err := m.UnmarshalCANFrame(f); err != nil {
+            return nil, err
+        }
+        return m, nil
+    case FC_TPDO1, FC_RPDO1, FC_TPDO2, FC_RPDO2, FC_TPDO3, FC_RPDO3, FC_TPDO4, FC_RPDO4:
+        return PDOFrame{Function: fc, Node: node, Data: append([]byte(nil), f.Data[:f.Len]...)}, nil
+    case FC_SDO_TX, FC_SDO_RX:
+        if f.Len != 8 {
+            return nil, fmt.Errorf("canopen: SDO frame len %d, want 8", f.Len)
+        }
+        return SDOFrame{Function: fc, Node: node, Data: f.Data}, nil
+    default:
+        return nil, fmt.Errorf("canopen: unclassified id 0x%X", f.ID)
+    }
+}
@@ -0,0 +1,120 @@
+package canopen
+
+import (
+    "context"
+    "log/slog"
+    "reflect"
+    "sync"
+
+    "github.com/notnil/canbus"
+)
+
+// UnknownPolicy selects what a Dispatcher does with frames no specific
+// handler accepts.
+type UnknownPolicy int
+
+const (
+    // UnknownDrop silently discards unhandled frames. This is the default.
+    UnknownDrop UnknownPolicy = iota
+    // UnknownCatchAll passes unhandled frames to the OnUnknown handler.
+    UnknownCatchAll
+    // UnknownLog logs unhandled frames using slog.Default at info level.
+    UnknownLog
+)
+
+// Dispatcher classifies every frame received through a Mux and routes the
+// decoded message to the handler registered for its type (see On).
+//
+// Precedence: a specific handler always wins. A frame reaches the unknown
+// policy only when Classify rejects it (e.g. extended or non-CANopen traffic
+// such as J1939 sharing the wire) or no handler is registered for its type.
+// Handlers run sequentially on the dispatcher goroutine.
+type Dispatcher struct {
+    mu       sync.RWMutex
+    handlers map[reflect.Type]func(CANopenMessage)
+    unknown  func(canbus.Frame)
+    policy   UnknownPolicy
+
+    cancel func()
+    done   chan struct{}
+}
+
+// NewDispatcher subscribes to all frames on mux and starts dispatching.
+// buffer is the mux subscription buffer size.
+func NewDispatcher(mux *canbus.Mux, buffer int) *Dispatcher {
+    frames, cancel := mux.Subscribe(nil, buffer)
+    d := &Dispatcher{
+        handlers: make(map[reflect.Type]func(CANopenMessage)),
+        cancel:   cancel,
+        done:     make(chan struct{}),
+    }
+    go d.run(frames)
+    return d
+}
+
+// On registers h for messages of type T (e.g. Heartbeat, Emergency, NMT,
+// SYNC, TIME, PDOFrame or SDOFrame), replacing any previous handler for T.
+func On[T CANopenMessage](d *Dispatcher, h func(T)) {
+    var zero T
+    d.mu.Lock()
+    d.handlers[reflect.TypeOf(zero)] = func(m CANopenMessage) { h(m.(T)) }
+    d.mu.Unlock()
+}
+
+// OnUnknown sets the catch-all handler and switches the policy to
+// UnknownCatchAll.
+func (d *Dispatcher) OnUnknown(h func(canbus.Frame)) {
+    d.mu.Lock()
+    d.unknown = h
+    d.policy = UnknownCatchAll
+    d.mu.Unlock()
+}
+
+// SetUnknownPolicy selects how unhandled frames are treated.
+func (d *Dispatcher) SetUnknownPolicy(p UnknownPolicy) {
+    d.mu.Lock()
+    d.policy = p
+    d.mu.Unlock()
+}
+
+// Close stops dispatching and waits for the dispatcher goroutine to exit.
+func (d *Dispatcher) Close() error {
+    d.cancel()
+    <-d.done
+    return nil
+}
+
+func (d *Dispatcher) run(frames <-chan canbus.Frame) {
+    defer close(d.done)
+    for f := range frames {
+        d.dispatch(f)
+    }
+}
+
+func (d *Dispatcher) dispatch(f canbus.Frame) {
+    msg, err := Classify(f)
+    d.mu.RLock()
+    var h func(CANopenMessage)
+    if err == nil {
+        h = d.handlers[reflect.TypeOf(msg)]
+    }
+    policy, unknown := d.policy, d.unknown
+    d.mu.RUnlock()
+
+    if h != nil {
+        h(msg)
+        return
+    }
+    switch policy {
+    case UnknownCatchAll:
+        if unknown != nil {
+            unknown(f)
+        }
+    case UnknownLog:
+        attrs := []any{"frame", f.String()}
+        if err != nil {
+            attrs = append(attrs, "error", err)
+        }
+        slog.Default().Log(context.Background(), slog.LevelInfo, "canopen unhandled frame", attrs...)
+    }
+}
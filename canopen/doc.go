@@ -3,11 +3,20 @@
 //
 // This package focuses on small, well-factored building blocks that cover
 // the most commonly used parts of CANopen:
-//   - COB-ID helpers and function code mapping
+//   - COB-ID helpers and function code mapping, including a 29-bit layout
 //   - NMT commands and node state encoding/decoding
 //   - Heartbeat (NMT error control) producer/consumer byte
 //   - Emergency (EMCY) frame encode/decode
 //   - SDO expedited transfers (encode/decode) and a minimal synchronous client
+//   - PDO payload packing with per-field byte order
+//   - Frame classification and a type-based Dispatcher
+//   - Flying master negotiation building blocks (subset of CiA 302-2)
+//
+// Message types implement FrameMarshaler and FrameUnmarshaler; those methods
+// are the canonical encoding. The Build* and Parse* functions (BuildNMT,
+// ParseNMT, BuildHeartbeat, ParseHeartbeat, BuildEMCY, ParseEMCY, ...) are
+// thin convenience wrappers around them and produce identical frames. The
+// decoders never panic; a malformed frame yields an error.
 //
 // The APIs here do not attempt to implement the full CANopen stack or
 // object dictionary. Instead, they provide composable types and helpers that
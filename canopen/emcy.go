@@ -3,6 +3,7 @@ package canopen
 import (
     "encoding/binary"
     "fmt"
+    "time"
 
     "github.com/notnil/canbus"
 )
@@ -19,6 +20,61 @@ type Emergency struct {
     Manufacturer   [5]byte
 }
 
+// ErrorRegister is the CiA 301 error register (object 0x1001) carried in
+// byte 2 of an EMCY frame.
+type ErrorRegister uint8
+
+const (
+    ErrRegGeneric       ErrorRegister = 0x01 // set whenever any error is present
+    ErrRegCurrent       ErrorRegister = 0x02
+    ErrRegVoltage       ErrorRegister = 0x04
+    ErrRegTemperature   ErrorRegister = 0x08
+    ErrRegCommunication ErrorRegister = 0x10 // overrun, error state
+    ErrRegDeviceProfile ErrorRegister = 0x20 // device profile specific
+    ErrRegManufacturer  ErrorRegister = 0x80 // manufacturer specific
+)
+
+// Has reports whether all bits of b are set in r.
+func (r ErrorRegister) Has(b ErrorRegister) bool { return r&b == b }
+
+// emcyClassBits returns the register bits implied by the class (high digits)
+// of an emergency error code.
+func emcyClassBits(code uint16) ErrorRegister {
+    switch {
+    case code == 0:
+        return 0
+    case code>>12 == 0x2:
+        return ErrRegGeneric | ErrRegCurrent
+    case code>>12 == 0x3:
+        return ErrRegGeneric | ErrRegVoltage
+    case code>>12 == 0x4:
+        return ErrRegGeneric | ErrRegTemperature
+    case code>>12 == 0x8:
+        return ErrRegGeneric | ErrRegCommunication
+    default:
+        return ErrRegGeneric
+    }
+}
+
+// NewEmergency builds an Emergency for error code with register reg and up
+// to five manufacturer-specific bytes, zero-padded. The register is
+// completed from the code: any non-zero code sets ErrRegGeneric, and current
+// (0x2xxx), voltage (0x3xxx), temperature (0x4xxx) and communication
+// (0x8xxx) codes set their class bit. Code 0x0000 (error reset) adds no
+// bits. The Node field is left zero for the caller to fill in. NewEmergency
+// panics if more than five manufacturer bytes are given.
+func NewEmergency(code uint16, reg ErrorRegister, mfr ...byte) Emergency {
+    if len(mfr) > 5 {
+        panic(fmt.Sprintf("canopen: EMCY manufacturer data too long: %d bytes, max 5", len(mfr)))
+    }
+    e := Emergency{ErrorCode: code, ErrorRegister: uint8(reg | emcyClassBits(code))}
+    copy(e.Manufacturer[:], mfr)
+    return e
+}
+
+// Register returns the error register as its typed bitmask.
+func (e Emergency) Register() ErrorRegister { return ErrorRegister(e.ErrorRegister) }
+
 // MarshalCANFrame encodes the EMCY event to a CAN frame.
 func (e Emergency) MarshalCANFrame() (canbus.Frame, error) {
     // reuse buildEMCY with same payload fields
@@ -39,6 +95,19 @@ func (e *Emergency) UnmarshalCANFrame(f canbus.Frame) error {
     return nil
 }
 
+// ParseEMCY decodes an EMCY frame.
+func ParseEMCY(f canbus.Frame) (Emergency, error) {
+    var e Emergency
+    err := e.UnmarshalCANFrame(f)
+    return e, err
+}
+
+// BuildEMCY builds an EMCY frame for node carrying e's payload; e.Node is
+// ignored. It is equivalent to marshaling e with Node set to node.
+func BuildEMCY(node NodeID, e Emergency) (canbus.Frame, error) {
+    return buildEMCY(node, e)
+}
+
 // buildEMCY builds an EMCY frame for the given node.
 func buildEMCY(node NodeID, e Emergency) (canbus.Frame, error) {
     if err := node.Validate(); err != nil {
@@ -56,14 +125,14 @@ func buildEMCY(node NodeID, e Emergency) (canbus.Frame, error) {
 // parseEMCY decodes an EMCY payload from a CAN frame.
 func parseEMCY(f canbus.Frame) (NodeID, Emergency, error) {
     if f.Len < 8 {
-        return 0, Emergency{}, fmt.Errorf("canopen: emcy too short: %d", f.Len)
+        return 0, Emergency{}, frameErrorf(ErrFrameTooShort, "canopen: emcy too short: %d", f.Len)
     }
-    fc, node, err := ParseCOBID(f.ID)
+    fc, node, err := ParseFrameCOBID(f)
     if err != nil {
         return 0, Emergency{}, err
     }
     if fc != FC_EMCY {
-        return 0, Emergency{}, fmt.Errorf("canopen: not an emcy frame (id=0x%X)", f.ID)
+        return 0, Emergency{}, wrongFrameErrorf(ErrNotEMCY, "canopen: not an emcy frame (id=0x%X)", f.ID)
     }
     var e Emergency
     e.ErrorCode = binary.LittleEndian.Uint16(f.Data[0:2])
@@ -72,3 +141,70 @@ func parseEMCY(f canbus.Frame) (NodeID, Emergency, error) {
     return node, e, nil
 }
 
+
+// SubscribeEmergencies subscribes to EMCY frames via mux and delivers parsed
+// events. If nodeFilter is non-nil, only emergencies from the specified node
+// are delivered. The returned cancel must be called when done. The channel
+// will be closed on cancel or if the underlying mux is closed.
+func SubscribeEmergencies(mux *canbus.Mux, nodeFilter *NodeID, buffer int) (<-chan Emergency, func()) {
+    frames, cancel := mux.Subscribe(func(f canbus.Frame) bool {
+        fc, node, err := ParseCOBID(f.ID)
+        if err != nil || fc != FC_EMCY || node == 0 || f.Len < 8 {
+            return false
+        }
+        if nodeFilter != nil && node != *nodeFilter {
+            return false
+        }
+        return true
+    }, buffer)
+
+    out := make(chan Emergency, buffer)
+    go func() {
+        defer close(out)
+        for f := range frames {
+            var e Emergency
+            if err := e.UnmarshalCANFrame(f); err != nil {
+                continue
+            }
+            out <- e
+        }
+    }()
+    return out, cancel
+}
+
+// WaitForEmergency waits for an EMCY from node carrying the given error
+// code and returns it. timeout of zero waits indefinitely; on timeout the
+// error wraps ErrReceiveTimeout.
+func WaitForEmergency(mux *canbus.Mux, node NodeID, code uint16, timeout time.Duration) (Emergency, error) {
+    return WaitForEmergencyMatch(mux, node, timeout, func(e Emergency) bool {
+        return e.ErrorCode == code
+    })
+}
+
+// WaitForEmergencyMatch waits for an EMCY from node for which match returns
+// true, e.g. to check the error register in addition to the error code.
+// timeout of zero waits indefinitely; on timeout the error wraps
+// ErrReceiveTimeout.
+func WaitForEmergencyMatch(mux *canbus.Mux, node NodeID, timeout time.Duration, match func(Emergency) bool) (Emergency, error) {
+    events, cancel := SubscribeEmergencies(mux, &node, 8)
+    defer cancel()
+    var deadline <-chan time.Time
+    if timeout > 0 {
+        t := time.NewTimer(timeout)
+        defer t.Stop()
+        deadline = t.C
+    }
+    for {
+        select {
+        case e, ok := <-events:
+            if !ok {
+                return Emergency{}, canbus.ErrClosed
+            }
+            if match(e) {
+                return e, nil
+            }
+        case <-deadline:
+            return Emergency{}, fmt.Errorf("%w: no matching EMCY from node %d", ErrReceiveTimeout, node)
+        }
+    }
+}
@@ -0,0 +1,61 @@
+package canopen
+
+import (
+    "errors"
+    "fmt"
+)
+
+// Sentinel errors returned (wrapped) by the frame parsers. Match them with
+// errors.Is; the error text carries the frame-specific details.
+var (
+    // ErrFrameTooShort reports a frame with fewer data bytes than the
+    // service requires.
+    ErrFrameTooShort = errors.New("canopen: frame too short")
+    // ErrInvalidLength reports a frame whose length is not allowed for the
+    // service, e.g. an SDO frame that is not 8 bytes.
+    ErrInvalidLength = errors.New("canopen: invalid frame length")
+    // ErrWrongFunctionCode reports a frame whose COB-ID belongs to a
+    // different service. The service-specific ErrNot* errors also match it.
+    ErrWrongFunctionCode = errors.New("canopen: wrong function code")
+
+    ErrNotNMT       = errors.New("canopen: not an NMT frame")
+    ErrNotHeartbeat = errors.New("canopen: not a heartbeat frame")
+    ErrNotEMCY      = errors.New("canopen: not an emcy frame")
+    ErrNotSYNC      = errors.New("canopen: not a SYNC frame")
+    ErrNotTIME      = errors.New("canopen: not a TIME frame")
+    ErrNotSDOFrame  = errors.New("canopen: not an SDO frame")
+
+    // ErrNotCANopenFrame is returned by CANopenGuardBus.Send for frames
+    // that do not belong on an 11-bit CANopen segment, and by
+    // ParseFrameCOBID and Classify for error frames.
+    ErrNotCANopenFrame = errors.New("canopen: not a CANopen frame")
+
+    // ErrUnknownNMTCommand is returned by ParseNMTStrict for a command byte
+    // that is not a defined NMTCommand.
+    ErrUnknownNMTCommand = errors.New("canopen: unknown NMT command")
+
+    // ErrUnexpectedSDOCommand reports an SDO frame whose command specifier
+    // or flags do not fit the transfer being decoded.
+    ErrUnexpectedSDOCommand = errors.New("canopen: unexpected SDO command")
+)
+
+// frameError is a parser error with a detailed message that matches one or
+// more sentinels via errors.Is.
+type frameError struct {
+    msg   string
+    kinds []error
+}
+
+func (e *frameError) Error() string   { return e.msg }
+func (e *frameError) Unwrap() []error { return e.kinds }
+
+// frameErrorf formats a parser error matching kind.
+func frameErrorf(kind error, format string, args ...any) error {
+    return &frameError{msg: fmt.Sprintf(format, args...), kinds: []error{kind}}
+}
+
+// wrongFrameErrorf formats a parser error matching kind and
+// ErrWrongFunctionCode.
+func wrongFrameErrorf(kind error, format string, args ...any) error {
+    return &frameError{msg: fmt.Sprintf(format, args...), kinds: []error{kind, ErrWrongFunctionCode}}
+}
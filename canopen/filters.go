@@ -80,3 +80,45 @@ func CANopenRPDO3(node NodeID) canbus.FrameFilter { return canbus.And(canbus.Sta
 func CANopenRPDO4(node NodeID) canbus.FrameFilter { return canbus.And(canbus.StandardOnly(), canbus.ByID(COBID(FC_RPDO4, node))) }
 
 
+
+// Extended (29-bit) variants using the COBIDExt layout.
+
+// CANopenExt matches extended frames carrying the COB-ID for fc and node
+// under the given prefix.
+func CANopenExt(fc FunctionCode, node NodeID, prefix uint32) canbus.FrameFilter {
+    return canbus.And(canbus.ExtendedOnly(), canbus.ByID(COBIDExt(fc, node, prefix)))
+}
+
+// CANopenExtAny matches extended frames for any node of a per-node function
+// code (e.g. FC_SDO_TX) under the given prefix.
+func CANopenExtAny(fc FunctionCode, prefix uint32) canbus.FrameFilter {
+    return canbus.And(canbus.ExtendedOnly(), canbus.ByMask(COBIDExt(fc, 0, prefix), 0x1FFFFF80))
+}
+
+func CANopenSDORequestExt(node NodeID, prefix uint32) canbus.FrameFilter {
+    return CANopenExt(FC_SDO_RX, node, prefix)
+}
+
+func CANopenSDOResponseExt(node NodeID, prefix uint32) canbus.FrameFilter {
+    return CANopenExt(FC_SDO_TX, node, prefix)
+}
+
+func CANopenHeartbeatExt(node NodeID, prefix uint32) canbus.FrameFilter {
+    return CANopenExt(FC_NMT_ERRCTRL, node, prefix)
+}
+
+func CANopenEMCYExt(node NodeID, prefix uint32) canbus.FrameFilter {
+    return CANopenExt(FC_EMCY, node, prefix)
+}
+
+// KernelFilters returns exact-match kernel filters for standard-frame
+// COB-IDs, e.g. for canbus.SocketCANOptions.Filters:
+//
+//	opts.Filters = canopen.KernelFilters(canopen.COBID(canopen.FC_TPDO1, 5), canopen.COBID(canopen.FC_EMCY, 5))
+func KernelFilters(cobids ...uint32) []canbus.CANFilter {
+    out := make([]canbus.CANFilter, 0, len(cobids))
+    for _, id := range cobids {
+        out = append(out, canbus.CANFilter{ID: id, Mask: 0x7FF})
+    }
+    return out
+}
@@ -0,0 +1,221 @@
+package canopen
+
+import (
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/notnil/canbus"
+)
+
+// Flying master building blocks (subset of CiA 302-2).
+//
+// In networks with redundant NMT masters, capable devices negotiate which one
+// is the active master. This file implements the negotiation core only:
+//   - the fixed request/response COB-IDs used for master detection
+//   - the ranking rule (lower priority value wins, ties broken by lower node id)
+//   - a minimal FlyingMasterNegotiator that claims mastership, answers
+//     detection requests while active and yields to a higher-ranked master
+//
+// Not implemented: the NMT startup object (0x1F80) integration, the
+// "force negotiation" and "indicate active master" services and the
+// time-slot based negotiation delays. Hooks on the negotiator allow an
+// application to layer those on top.
+
+// Fixed COB-IDs for flying master detection (no node id addition).
+const (
+    FC_FLYMASTER_REQUEST  FunctionCode = 0x075 // candidate -> network
+    FC_FLYMASTER_RESPONSE FunctionCode = 0x076 // active master -> candidate
+)
+
+// FlyingMaster identifies a master candidate by priority and node id.
+// Lower Priority values rank higher.
+type FlyingMaster struct {
+    Priority uint8
+    Node     NodeID
+}
+
+// Outranks reports whether m wins a negotiation against other.
+func (m FlyingMaster) Outranks(other FlyingMaster) bool {
+    if m.Priority != other.Priority {
+        return m.Priority < other.Priority
+    }
+    return m.Node < other.Node
+}
+
+// buildFlyingMaster encodes a request or response frame carrying m.
+func buildFlyingMaster(fc FunctionCode, m FlyingMaster) (canbus.Frame, error) {
+    if err := m.Node.Validate(); err != nil {
+        return canbus.Frame{}, err
+    }
+    var f canbus.Frame
+    f.ID = uint32(fc)
+    f.Len = 2
+    f.Data[0] = m.Priority
+    f.Data[1] = byte(m.Node)
+    return f, nil
+}
+
+// parseFlyingMaster decodes a request or response frame with the given id.
+func parseFlyingMaster(fc FunctionCode, f canbus.Frame) (FlyingMaster, error) {
+    if f.Extended || f.ID != uint32(fc) {
+        return FlyingMaster{}, fmt.Errorf("canopen: not a flying master frame (id=0x%X)", f.ID)
+    }
+    if f.Len < 2 {
+        return FlyingMaster{}, fmt.Errorf("canopen: flying master frame too short: %d", f.Len)
+    }
+    m := FlyingMaster{Priority: f.Data[0], Node: NodeID(f.Data[1])}
+    if err := m.Node.Validate(); err != nil {
+        return FlyingMaster{}, err
+    }
+    return m, nil
+}
+
+// FlyingMasterNegotiator claims and holds active mastership for a local
+// master candidate.
+//
+// Claim broadcasts a detection request and collects responses for the given
+// window. While active, the negotiator answers requests from other
+// candidates and yields if a higher-ranked candidate appears.
+type FlyingMasterNegotiator struct {
+    bus  canbus.Bus
+    mux  *canbus.Mux
+    self FlyingMaster
+
+    // OnActive, if set, is called when this candidate becomes active master.
+    OnActive func()
+    // OnYield, if set, is called with the winning master when this
+    // candidate stops being active master.
+    OnYield func(winner FlyingMaster)
+
+    mu     sync.Mutex
+    active bool
+    stop   chan struct{}
+}
+
+// NewFlyingMasterNegotiator creates a negotiator for the local candidate.
+// The mux is used to wait for requests and responses; bus is used to send.
+func NewFlyingMasterNegotiator(bus canbus.Bus, mux *canbus.Mux, self FlyingMaster) *FlyingMasterNegotiator {
+    if mux == nil {
+        panic("canopen: FlyingMasterNegotiator requires a non-nil Mux")
+    }
+    return &FlyingMasterNegotiator{bus: bus, mux: mux, self: self}
+}
+
+// Active reports whether this candidate currently holds mastership.
+func (n *FlyingMasterNegotiator) Active() bool {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    return n.active
+}
+
+// Claim detects other masters for the duration of window and returns the
+// winning candidate. If the local candidate wins it becomes active and
+// starts answering detection requests until Stop is called.
+func (n *FlyingMasterNegotiator) Claim(window time.Duration) (FlyingMaster, error) {
+    req, err := buildFlyingMaster(FC_FLYMASTER_REQUEST, n.self)
+    if err != nil {
+        return FlyingMaster{}, err
+    }
+    ch, cancel := n.mux.Subscribe(canbus.And(canbus.StandardOnly(), canbus.ByID(uint32(FC_FLYMASTER_RESPONSE))), 16)
+    defer cancel()
+    if err := n.bus.Send(req); err != nil {
+        return FlyingMaster{}, err
+    }
+
+    winner := n.self
+    deadline := time.After(window)
+collect:
+    for {
+        select {
+        case f, ok := <-ch:
+            if !ok {
+                return FlyingMaster{}, canbus.ErrClosed
+            }
+            m, err := parseFlyingMaster(FC_FLYMASTER_RESPONSE, f)
+            if err != nil {
+                continue
+            }
+            if m.Outranks(winner) {
+                winner = m
+            }
+        case <-deadline:
+            break collect
+        }
+    }
+    if winner != n.self {
+        return winner, nil
+    }
+    n.activate()
+    return winner, nil
+}
+
+// Stop relinquishes mastership without notifying OnYield and stops
+// answering detection requests.
+func (n *FlyingMasterNegotiator) Stop() {
+    n.mu.Lock()
+    n.active = false
+    if n.stop != nil {
+        close(n.stop)
+        n.stop = nil
+    }
+    n.mu.Unlock()
+}
+
+func (n *FlyingMasterNegotiator) activate() {
+    n.mu.Lock()
+    if n.active {
+        n.mu.Unlock()
+        return
+    }
+    n.active = true
+    stop := make(chan struct{})
+    n.stop = stop
+    frames, cancel := n.mux.Subscribe(canbus.And(canbus.StandardOnly(), canbus.ByID(uint32(FC_FLYMASTER_REQUEST))), 16)
+    n.mu.Unlock()
+
+    if n.OnActive != nil {
+        n.OnActive()
+    }
+    go n.serve(frames, cancel, stop)
+}
+
+// serve answers detection requests while active and yields to higher-ranked
+// candidates.
+func (n *FlyingMasterNegotiator) serve(frames <-chan canbus.Frame, cancel func(), stop chan struct{}) {
+    defer cancel()
+    for {
+        select {
+        case <-stop:
+            return
+        case f, ok := <-frames:
+            if !ok {
+                return
+            }
+            m, err := parseFlyingMaster(FC_FLYMASTER_REQUEST, f)
+            if err != nil || m == n.self {
+                continue
+            }
+            if m.Outranks(n.self) {
+                n.mu.Lock()
+                if n.stop != stop {
+                    n.mu.Unlock()
+                    return
+                }
+                n.active = false
+                close(n.stop)
+                n.stop = nil
+                n.mu.Unlock()
+                if n.OnYield != nil {
+                    n.OnYield(m)
+                }
+                return
+            }
+            rsp, err := buildFlyingMaster(FC_FLYMASTER_RESPONSE, n.self)
+            if err != nil {
+                continue
+            }
+            _ = n.bus.Send(rsp)
+        }
+    }
+}
@@ -0,0 +1,74 @@
+package canopen
+
+import (
+    "fmt"
+
+    "github.com/notnil/canbus"
+)
+
+// GuardReceivePolicy selects what a CANopenGuardBus does with received
+// frames that are not valid CANopen traffic.
+type GuardReceivePolicy uint8
+
+const (
+    // GuardPass delivers every received frame unchanged.
+    GuardPass GuardReceivePolicy = iota
+    // GuardDrop silently discards received frames that fail the check.
+    GuardDrop
+)
+
+// CANopenGuardBus is a Bus decorator for pure 11-bit CANopen segments. Send
+// rejects extended frames and identifiers outside the predefined connection
+// set (as recognized by ParseCOBID) with an error wrapping
+// ErrNotCANopenFrame; Receive passes or drops such frames per its policy.
+type CANopenGuardBus struct {
+    inner  canbus.Bus
+    policy GuardReceivePolicy
+    allow  canbus.FrameFilter
+}
+
+// NewCANopenGuardBus wraps inner. allow, if non-nil, admits additional
+// standard frames that ParseCOBID does not know, e.g. SRDO or flying master
+// identifiers.
+func NewCANopenGuardBus(inner canbus.Bus, policy GuardReceivePolicy, allow canbus.FrameFilter) *CANopenGuardBus {
+    return &CANopenGuardBus{inner: inner, policy: policy, allow: allow}
+}
+
+// check returns nil if f may appear on a CANopen segment.
+func (g *CANopenGuardBus) check(f canbus.Frame) error {
+    if f.Error {
+        return fmt.Errorf("%w: error frame", ErrNotCANopenFrame)
+    }
+    if f.Extended {
+        return fmt.Errorf("%w: extended frame 0x%08X", ErrNotCANopenFrame, f.ID)
+    }
+    if g.allow != nil && g.allow(f) {
+        return nil
+    }
+    if _, _, err := ParseCOBID(f.ID); err != nil {
+        return fmt.Errorf("%w: id 0x%03X outside CANopen COB-ID ranges", ErrNotCANopenFrame, f.ID)
+    }
+    return nil
+}
+
+// Send validates f and forwards it to the inner bus.
+func (g *CANopenGuardBus) Send(f canbus.Frame) error {
+    if err := g.check(f); err != nil {
+        return err
+    }
+    return g.inner.Send(f)
+}
+
+// Receive returns the next frame from the inner bus, skipping frames that
+// fail the check when the policy is GuardDrop.
+func (g *CANopenGuardBus) Receive() (canbus.Frame, error) {
+    for {
+        f, err := g.inner.Receive()
+        if err != nil || g.policy != GuardDrop || g.check(f) == nil {
+            return f, err
+        }
+    }
+}
+
+// Close closes the inner bus.
+func (g *CANopenGuardBus) Close() error { return g.inner.Close() }
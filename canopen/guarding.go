@@ -0,0 +1,27 @@
+package canopen
+
+import (
+    "fmt"
+    "time"
+)
+
+// Node guarding communication objects.
+const (
+    ObjGuardTime      uint16 = 0x100C // UNSIGNED16, milliseconds
+    ObjLifeTimeFactor uint16 = 0x100D // UNSIGNED8
+)
+
+// ConfigureNodeGuarding writes the guard time (0x100C) and life time factor
+// (0x100D) of the node served by client. The node's life time is
+// guardTime * lifeFactor; setting either to 0 disables node guarding on the
+// device. guardTime is truncated to whole milliseconds and must not exceed
+// 65535 ms.
+func ConfigureNodeGuarding(client *SDOClient, guardTime time.Duration, lifeFactor uint8) error {
+    if guardTime < 0 || guardTime > 65535*time.Millisecond {
+        return fmt.Errorf("canopen: guard time %v out of range (0..65535ms)", guardTime)
+    }
+    if err := client.WriteU16(ObjGuardTime, 0x00, uint16(guardTime/time.Millisecond)); err != nil {
+        return err
+    }
+    return client.WriteU8(ObjLifeTimeFactor, 0x00, lifeFactor)
+}
@@ -2,20 +2,34 @@ package canopen
 
 import (
     "fmt"
+    "sync"
+    "time"
 
     "github.com/notnil/canbus"
 )
 
-// Heartbeat represents an NMT error control heartbeat from a node and
+// Heartbeat represents an NMT error control message from a node and
 // implements CAN frame marshal/unmarshal.
+//
+// The same frame format carries heartbeats and node guarding responses:
+// bit 7 of the data byte is the guarding toggle bit and bits 6..0 the state.
+// Pure heartbeats always have Toggle false.
 type Heartbeat struct {
-    Node  NodeID
-    State NMTState
+    Node   NodeID
+    State  NMTState
+    Toggle bool
 }
 
 // MarshalCANFrame encodes the heartbeat to a CAN frame.
 func (h Heartbeat) MarshalCANFrame() (canbus.Frame, error) {
-    return buildHeartbeat(h.Node, h.State)
+    f, err := buildHeartbeat(h.Node, h.State)
+    if err != nil {
+        return canbus.Frame{}, err
+    }
+    if h.Toggle {
+        f.Data[0] |= 0x80
+    }
+    return f, nil
 }
 
 // UnmarshalCANFrame decodes the heartbeat from a CAN frame.
@@ -26,11 +40,25 @@ func (h *Heartbeat) UnmarshalCANFrame(f canbus.Frame) error {
     }
     h.Node = node
     h.State = state
+    h.Toggle = f.Data[0]&0x80 != 0
     return nil
 }
 
+// ParseHeartbeat decodes a heartbeat frame.
+func ParseHeartbeat(f canbus.Frame) (Heartbeat, error) {
+    var h Heartbeat
+    err := h.UnmarshalCANFrame(f)
+    return h, err
+}
+
+// BuildHeartbeat builds a heartbeat frame for node in state. It is
+// equivalent to Heartbeat{Node: node, State: state}.MarshalCANFrame.
+func BuildHeartbeat(node NodeID, state NMTState) (canbus.Frame, error) {
+    return buildHeartbeat(node, state)
+}
+
 // buildHeartbeat produces an NMT error control heartbeat frame for node/state.
-// A heartbeat contains a single byte with the current NMTState.
+// A heartbeat contains a single byte with the current NMTState (bits 6..0).
 func buildHeartbeat(node NodeID, state NMTState) (canbus.Frame, error) {
     if err := node.Validate(); err != nil {
         return canbus.Frame{}, err
@@ -38,23 +66,24 @@ func buildHeartbeat(node NodeID, state NMTState) (canbus.Frame, error) {
     var f canbus.Frame
     f.ID = COBID(FC_NMT_ERRCTRL, node)
     f.Len = 1
-    f.Data[0] = byte(state)
+    f.Data[0] = byte(state) & 0x7F
     return f, nil
 }
 
 // parseHeartbeat parses a heartbeat frame and returns node id and state.
+// The guarding toggle bit (bit 7) is masked off the state.
 func parseHeartbeat(f canbus.Frame) (NodeID, NMTState, error) {
     if f.Len < 1 {
-        return 0, 0, fmt.Errorf("canopen: heartbeat too short: %d", f.Len)
+        return 0, 0, frameErrorf(ErrFrameTooShort, "canopen: heartbeat too short: %d", f.Len)
     }
-    fc, node, err := ParseCOBID(f.ID)
+    fc, node, err := ParseFrameCOBID(f)
     if err != nil {
         return 0, 0, err
     }
     if fc != FC_NMT_ERRCTRL {
-        return 0, 0, fmt.Errorf("canopen: not a heartbeat frame (id=0x%X)", f.ID)
+        return 0, 0, wrongFrameErrorf(ErrNotHeartbeat, "canopen: not a heartbeat frame (id=0x%X)", f.ID)
     }
-    return node, NMTState(f.Data[0]), nil
+    return node, NMTState(f.Data[0] & 0x7F), nil
 }
 
 // SubscribeHeartbeats subscribes to heartbeat (NMT error control) frames via mux
@@ -77,13 +106,103 @@ func SubscribeHeartbeats(mux *canbus.Mux, nodeFilter *NodeID, buffer int) (<-cha
     go func() {
         defer close(out)
         for f := range frames {
-            node, state, err := parseHeartbeat(f)
-            if err != nil {
+            var hb Heartbeat
+            if err := hb.UnmarshalCANFrame(f); err != nil {
                 continue
             }
-            out <- Heartbeat{Node: node, State: state}
+            out <- hb
         }
     }()
     return out, cancel
 }
 
+
+// HeartbeatProducer periodically transmits the heartbeat of a local node.
+// It implements PeriodicProducer so it can be driven by a Scheduler instead
+// of Start/Stop; do not use both at once.
+type HeartbeatProducer struct {
+    bus    canbus.Bus
+    node   NodeID
+    period time.Duration
+
+    mu    sync.Mutex
+    state NMTState
+
+    stop chan struct{}
+}
+
+// NewHeartbeatProducer creates a heartbeat producer for node with the given
+// producer heartbeat time (object 0x1017). The initial state is
+// pre-operational.
+func NewHeartbeatProducer(bus canbus.Bus, node NodeID, period time.Duration) *HeartbeatProducer {
+    return &HeartbeatProducer{bus: bus, node: node, period: period, state: StatePreOperational}
+}
+
+// SetState changes the NMT state reported by subsequent heartbeats.
+func (p *HeartbeatProducer) SetState(state NMTState) {
+    p.mu.Lock()
+    p.state = state
+    p.mu.Unlock()
+}
+
+// Period returns the producer heartbeat time.
+func (p *HeartbeatProducer) Period() time.Duration {
+    return p.period
+}
+
+// Frame returns a heartbeat frame carrying the current state.
+func (p *HeartbeatProducer) Frame() (canbus.Frame, bool) {
+    p.mu.Lock()
+    state := p.state
+    p.mu.Unlock()
+    f, err := buildHeartbeat(p.node, state)
+    if err != nil {
+        return canbus.Frame{}, false
+    }
+    return f, true
+}
+
+// Start launches the background goroutine.
+func (p *HeartbeatProducer) Start() {
+    if p.stop == nil {
+        p.stop = make(chan struct{})
+    }
+    go runPeriodic(p.bus, p, p.stop, nil)
+}
+
+// Stop signals the producer to stop.
+func (p *HeartbeatProducer) Stop() {
+    if p.stop == nil {
+        return
+    }
+    select {
+    case <-p.stop:
+        return
+    default:
+    }
+    close(p.stop)
+}
+
+// ObjConsumerHeartbeatTime is the consumer heartbeat time array (0x1016).
+// Each sub-entry 1..n is an UNSIGNED32 packing the monitored node id in
+// bits 23..16 and the heartbeat time in ms in bits 15..0.
+const ObjConsumerHeartbeatTime uint16 = 0x1016
+
+// ParseHeartbeatConsumerEntry decodes a 0x1016 sub-entry. A zero timeout
+// means monitoring of node is disabled. Reserved bits 31..24 are ignored.
+func ParseHeartbeatConsumerEntry(v uint32) (node NodeID, timeout time.Duration) {
+    return NodeID(v >> 16), time.Duration(v&0xFFFF) * time.Millisecond
+}
+
+// HeartbeatConsumerEntry encodes a 0x1016 sub-entry; it is the inverse of
+// ParseHeartbeatConsumerEntry. timeout is truncated to whole milliseconds
+// and must not exceed 65535 ms; zero disables monitoring.
+func HeartbeatConsumerEntry(node NodeID, timeout time.Duration) (uint32, error) {
+    if err := node.Validate(); err != nil {
+        return 0, err
+    }
+    if timeout < 0 || timeout > 65535*time.Millisecond {
+        return 0, fmt.Errorf("canopen: heartbeat consumer time %v out of range (0..65535ms)", timeout)
+    }
+    return uint32(node)<<16 | uint32(timeout/time.Millisecond), nil
+}
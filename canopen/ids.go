@@ -1,6 +1,10 @@
 package canopen
 
-import "fmt"
+import (
+    "fmt"
+
+    "github.com/notnil/canbus"
+)
 
 // NodeID represents a CANopen node identifier (1..127).
 // Value 0 is used for broadcast in some services (e.g., NMT) and is allowed
@@ -108,3 +112,56 @@ func ParseCOBID(id uint32) (FunctionCode, NodeID, error) {
     }
 }
 
+
+// Extended (29-bit) COB-IDs
+//
+// For networks using CAN 2.0B extended frames, a 29-bit COB-ID is composed
+// of an 18-bit network prefix in bits 28..11 and the standard 11-bit COB-ID
+// of the predefined connection set in bits 10..0:
+//
+//	 28            11 10        7 6          0
+//	+----------------+-----------+------------+
+//	|     prefix     | func code |  node id   |
+//	+----------------+-----------+------------+
+//
+// With prefix 0 the numeric identifier equals the 11-bit COB-ID, but it is
+// carried in an extended frame. Frames using this layout must have
+// Extended set.
+
+// maxCOBIDExtPrefix is the largest prefix that fits in bits 28..11.
+const maxCOBIDExtPrefix = 0x3FFFF
+
+// COBIDExt composes the 29-bit CAN identifier for a function code, node id
+// and network prefix. prefix must fit in 18 bits; extra bits are discarded.
+func COBIDExt(fc FunctionCode, node NodeID, prefix uint32) uint32 {
+    return (prefix&maxCOBIDExtPrefix)<<11 | COBID(fc, node)
+}
+
+// ParseCOBIDExt is the 29-bit counterpart of ParseCOBID. It returns the
+// function code and node id from bits 10..0 and the prefix from bits 28..11.
+func ParseCOBIDExt(id uint32) (FunctionCode, NodeID, uint32, error) {
+    if id > 0x1FFFFFFF {
+        return 0, 0, 0, fmt.Errorf("canopen: invalid 29-bit id 0x%X", id)
+    }
+    fc, node, err := ParseCOBID(id & 0x7FF)
+    if err != nil {
+        return 0, 0, 0, err
+    }
+    return fc, node, id >> 11, nil
+}
+
+// ParseFrameCOBID returns the function code and node id of a frame,
+// dispatching on f.Extended: standard frames are parsed with ParseCOBID and
+// extended frames with ParseCOBIDExt (the prefix is discarded). Use it when a
+// bus carries both forms. Error frames carry no COB-ID and fail with
+// ErrNotCANopenFrame.
+func ParseFrameCOBID(f canbus.Frame) (FunctionCode, NodeID, error) {
+    if f.Error {
+        return 0, 0, fmt.Errorf("%w: error frame", ErrNotCANopenFrame)
+    }
+    if f.Extended {
+        fc, node, _, err := ParseCOBIDExt(f.ID)
+        return fc, node, err
+    }
+    return ParseCOBID(f.ID)
+}
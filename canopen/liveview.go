@@ -0,0 +1,135 @@
+package canopen
+
+import (
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/notnil/canbus"
+)
+
+// NodeStatus is the observed status of one node in a LiveView.
+type NodeStatus struct {
+    Node     NodeID
+    State    NMTState  // state from the most recent heartbeat or boot-up
+    LastSeen time.Time // time of the most recent heartbeat or boot-up
+    // Period is the heartbeat period: the value set via SetHeartbeatPeriod,
+    // otherwise the gap between the last two heartbeats (zero until known).
+    Period time.Duration
+    // Stale is set when the node has not been seen for 1.5 periods.
+    Stale bool
+    // LastEmergency is the most recent EMCY from the node, or nil.
+    LastEmergency     *Emergency
+    LastEmergencyTime time.Time
+}
+
+// LiveView passively aggregates heartbeats, boot-ups and EMCYs into a
+// per-node status snapshot, e.g. for a "who's alive" dashboard. It is safe
+// for concurrent use.
+type LiveView struct {
+    cancelHB   func()
+    cancelEMCY func()
+    done       chan struct{}
+
+    mu      sync.Mutex
+    nodes   map[NodeID]*NodeStatus
+    periods map[NodeID]time.Duration
+}
+
+// Monitor starts a LiveView fed from mux. Close must be called when done.
+func Monitor(mux *canbus.Mux) *LiveView {
+    hbs, cancelHB := SubscribeHeartbeats(mux, nil, 64)
+    emcys, cancelEMCY := SubscribeEmergencies(mux, nil, 64)
+    v := &LiveView{
+        cancelHB:   cancelHB,
+        cancelEMCY: cancelEMCY,
+        done:       make(chan struct{}),
+        nodes:      make(map[NodeID]*NodeStatus),
+        periods:    make(map[NodeID]time.Duration),
+    }
+    go v.run(hbs, emcys)
+    return v
+}
+
+func (v *LiveView) run(hbs <-chan Heartbeat, emcys <-chan Emergency) {
+    defer close(v.done)
+    for hbs != nil || emcys != nil {
+        select {
+        case hb, ok := <-hbs:
+            if !ok {
+                hbs = nil
+                continue
+            }
+            now := time.Now()
+            v.mu.Lock()
+            st := v.status(hb.Node)
+            if !st.LastSeen.IsZero() && hb.State != StateBootup {
+                st.Period = now.Sub(st.LastSeen)
+            }
+            st.State = hb.State
+            st.LastSeen = now
+            v.mu.Unlock()
+        case e, ok := <-emcys:
+            if !ok {
+                emcys = nil
+                continue
+            }
+            v.mu.Lock()
+            st := v.status(e.Node)
+            st.LastEmergency = &e
+            st.LastEmergencyTime = time.Now()
+            v.mu.Unlock()
+        }
+    }
+}
+
+// status returns the entry for node, creating it. v.mu must be held.
+func (v *LiveView) status(node NodeID) *NodeStatus {
+    st, ok := v.nodes[node]
+    if !ok {
+        st = &NodeStatus{Node: node}
+        v.nodes[node] = st
+    }
+    return st
+}
+
+// SetHeartbeatPeriod sets the expected heartbeat period of node, used for
+// the Stale flag instead of the observed period. Zero reverts to observing.
+func (v *LiveView) SetHeartbeatPeriod(node NodeID, period time.Duration) {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+    if period <= 0 {
+        delete(v.periods, node)
+        return
+    }
+    v.periods[node] = period
+}
+
+// Nodes returns a snapshot of every node seen so far, ordered by node id.
+func (v *LiveView) Nodes() []NodeStatus {
+    now := time.Now()
+    v.mu.Lock()
+    defer v.mu.Unlock()
+    out := make([]NodeStatus, 0, len(v.nodes))
+    for _, st := range v.nodes {
+        s := *st
+        if p, ok := v.periods[s.Node]; ok {
+            s.Period = p
+        }
+        if s.LastEmergency != nil {
+            e := *s.LastEmergency
+            s.LastEmergency = &e
+        }
+        s.Stale = s.Period > 0 && !s.LastSeen.IsZero() && now.Sub(s.LastSeen) > s.Period+s.Period/2
+        out = append(out, s)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Node < out[j].Node })
+    return out
+}
+
+// Close stops monitoring. Snapshots remain available.
+func (v *LiveView) Close() {
+    v.cancelHB()
+    v.cancelEMCY()
+    <-v.done
+}
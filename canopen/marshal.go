@@ -1,6 +1,10 @@
 package canopen
 
 import (
+    "context"
+    "errors"
+    "time"
+
     "github.com/notnil/canbus"
 )
 
@@ -19,3 +23,49 @@ type FrameCodec interface {
     FrameMarshaler
     FrameUnmarshaler
 }
+
+// Send marshals m and sends the resulting frame on bus. Marshal errors are
+// returned without sending.
+func Send(bus canbus.Bus, m FrameMarshaler) error {
+    f, err := m.MarshalCANFrame()
+    if err != nil {
+        return err
+    }
+    return bus.Send(f)
+}
+
+// SendAll sends each message in order, stopping at the first marshal or
+// send error.
+func SendAll(bus canbus.Bus, ms ...FrameMarshaler) error {
+    for _, m := range ms {
+        if err := Send(bus, m); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// ErrReceiveTimeout is returned by ReceiveInto, and wrapped by
+// WaitForEmergency, when no matching frame arrives in time.
+var ErrReceiveTimeout = errors.New("canopen: timed out waiting for frame")
+
+// ReceiveInto waits for the next frame matching filter on mux and decodes it
+// into u, e.g. a *Heartbeat:
+//
+//	var hb canopen.Heartbeat
+//	err := canopen.ReceiveInto(mux, canopen.CANopenHeartbeat(5), &hb, time.Second)
+//
+// A timeout of zero waits indefinitely. It returns ErrReceiveTimeout on
+// timeout, canbus.ErrClosed if the mux closes, or the unmarshal error.
+func ReceiveInto(mux *canbus.Mux, filter canbus.FrameFilter, u FrameUnmarshaler, timeout time.Duration) error {
+    ch, cancel := mux.Subscribe(filter, 1)
+    defer cancel()
+    f, err := waitWithTimeout(context.Background(), ch, timeout)
+    if err == ErrSDOTimeout {
+        return ErrReceiveTimeout
+    }
+    if err != nil {
+        return err
+    }
+    return u.UnmarshalCANFrame(f)
+}
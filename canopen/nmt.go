@@ -1,10 +1,6 @@
 package canopen
 
-import (
-    "fmt"
-
-    "github.com/notnil/canbus"
-)
+import "github.com/notnil/canbus"
 
 // NMTCommand is the command specifier for NMT service.
 type NMTCommand uint8
@@ -17,6 +13,15 @@ const (
     NMTResetCommunication NMTCommand = 0x82
 )
 
+// Valid reports whether c is one of the NMT commands defined by CiA 301.
+func (c NMTCommand) Valid() bool {
+    switch c {
+    case NMTStart, NMTStop, NMTEnterPreOperational, NMTResetNode, NMTResetCommunication:
+        return true
+    }
+    return false
+}
+
 // NMTState encodes the node state as used in heartbeat.
 type NMTState uint8
 
@@ -37,13 +42,35 @@ func buildNMT(cmd NMTCommand, node uint8) canbus.Frame {
     return f
 }
 
+// BuildNMT builds an NMT command frame for node; node 0 addresses all
+// nodes. It is equivalent to NMT{Command: cmd, Node: node}.MarshalCANFrame
+// and performs no node validation; see BuildNMTNode for a checked form.
+func BuildNMT(cmd NMTCommand, node uint8) canbus.Frame {
+    return buildNMT(cmd, node)
+}
+
+// BuildNMTBroadcast builds an NMT command frame addressed to all nodes.
+func BuildNMTBroadcast(cmd NMTCommand) canbus.Frame {
+    return buildNMT(cmd, 0)
+}
+
+// BuildNMTNode builds an NMT command frame addressed to a single node.
+// The node id must be in the range 1..127; use BuildNMTBroadcast to address
+// all nodes.
+func BuildNMTNode(cmd NMTCommand, node NodeID) (canbus.Frame, error) {
+    if err := node.Validate(); err != nil {
+        return canbus.Frame{}, err
+    }
+    return buildNMT(cmd, uint8(node)), nil
+}
+
 // parseNMT decodes an NMT frame payload returning command and target node.
 func parseNMT(f canbus.Frame) (NMTCommand, uint8, error) {
     if f.ID != COBID(FC_NMT, 0) {
-        return 0, 0, fmt.Errorf("canopen: not an NMT frame (id=0x%X)", f.ID)
+        return 0, 0, wrongFrameErrorf(ErrNotNMT, "canopen: not an NMT frame (id=0x%X)", f.ID)
     }
     if f.Len < 2 {
-        return 0, 0, fmt.Errorf("canopen: NMT frame too short: %d", f.Len)
+        return 0, 0, frameErrorf(ErrFrameTooShort, "canopen: NMT frame too short: %d", f.Len)
     }
     return NMTCommand(f.Data[0]), f.Data[1], nil
 }
@@ -73,3 +100,24 @@ func (n *NMT) UnmarshalCANFrame(f canbus.Frame) error {
     return nil
 }
 
+// ParseNMT decodes an NMT command frame. The command byte is not checked,
+// so sniffers see undefined commands as sent; use ParseNMTStrict to reject
+// them.
+func ParseNMT(f canbus.Frame) (NMT, error) {
+    var n NMT
+    err := n.UnmarshalCANFrame(f)
+    return n, err
+}
+
+// ParseNMTStrict is like ParseNMT but also rejects command bytes that are
+// not a defined NMTCommand with ErrUnknownNMTCommand.
+func ParseNMTStrict(f canbus.Frame) (NMT, error) {
+    n, err := ParseNMT(f)
+    if err != nil {
+        return NMT{}, err
+    }
+    if !n.Command.Valid() {
+        return NMT{}, frameErrorf(ErrUnknownNMTCommand, "canopen: unknown NMT command 0x%02X", byte(n.Command))
+    }
+    return n, nil
+}
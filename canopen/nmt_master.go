@@ -0,0 +1,191 @@
+package canopen
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math/bits"
+    "time"
+
+    "github.com/notnil/canbus"
+)
+
+// NodeSet is a set of node ids 1..127.
+type NodeSet [2]uint64
+
+// NewNodeSet returns a set containing ids. Invalid ids are ignored.
+func NewNodeSet(ids ...NodeID) NodeSet {
+    var s NodeSet
+    for _, id := range ids {
+        s.Add(id)
+    }
+    return s
+}
+
+// Add inserts id; invalid ids are ignored.
+func (s *NodeSet) Add(id NodeID) {
+    if id.Validate() == nil {
+        s[id/64] |= 1 << (id % 64)
+    }
+}
+
+// Remove deletes id.
+func (s *NodeSet) Remove(id NodeID) {
+    if id < 128 {
+        s[id/64] &^= 1 << (id % 64)
+    }
+}
+
+// Contains reports whether id is in the set.
+func (s NodeSet) Contains(id NodeID) bool {
+    return id < 128 && s[id/64]&(1<<(id%64)) != 0
+}
+
+// Nodes returns the members in ascending order.
+func (s NodeSet) Nodes() []NodeID {
+    var out []NodeID
+    for id := NodeID(1); id < 128; id++ {
+        if s.Contains(id) {
+            out = append(out, id)
+        }
+    }
+    return out
+}
+
+// Len returns the number of members.
+func (s NodeSet) Len() int {
+    return bits.OnesCount64(s[0]) + bits.OnesCount64(s[1])
+}
+
+// NodeError associates an error with the node it occurred on.
+type NodeError struct {
+    Node NodeID
+    Err  error
+}
+
+func (e NodeError) Error() string {
+    return fmt.Sprintf("canopen: node 0x%02X: %v", uint8(e.Node), e.Err)
+}
+
+func (e NodeError) Unwrap() error { return e.Err }
+
+// ErrStateTimeout is returned when a node does not report the expected NMT
+// state in time.
+var ErrStateTimeout = errors.New("canopen: timed out waiting for NMT state")
+
+// NMTMaster issues NMT commands and observes node states via heartbeats.
+// Waiting for states requires the nodes to produce heartbeats.
+type NMTMaster struct {
+    bus     canbus.Bus
+    mux     *canbus.Mux
+    timeout time.Duration
+}
+
+// NewNMTMaster creates an NMT master sending on bus and observing heartbeats
+// via mux. timeout bounds each wait for a node state; zero means wait until
+// the context is done.
+func NewNMTMaster(bus canbus.Bus, mux *canbus.Mux, timeout time.Duration) *NMTMaster {
+    return &NMTMaster{bus: bus, mux: mux, timeout: timeout}
+}
+
+// Command sends cmd to node, or to all nodes when node is 0.
+func (m *NMTMaster) Command(cmd NMTCommand, node NodeID) error {
+    if node == 0 {
+        return m.bus.Send(BuildNMTBroadcast(cmd))
+    }
+    f, err := BuildNMTNode(cmd, node)
+    if err != nil {
+        return err
+    }
+    return m.bus.Send(f)
+}
+
+// Reset sends reset-communication to node, or to all nodes when node is 0.
+// Use Command with NMTResetNode for a full application reset.
+func (m *NMTMaster) Reset(node NodeID) error {
+    return m.Command(NMTResetCommunication, node)
+}
+
+// WaitForState waits until node reports state in a heartbeat. It fails
+// straight away if node is not a valid node id.
+func (m *NMTMaster) WaitForState(ctx context.Context, node NodeID, state NMTState) error {
+    if err := node.Validate(); err != nil {
+        return err
+    }
+    hbs, cancel := SubscribeHeartbeats(m.mux, &node, 8)
+    defer cancel()
+    errs := m.awaitState(ctx, hbs, NewNodeSet(node), state)
+    return errs[node]
+}
+
+// Bootstrap brings up nodes: it broadcasts reset-communication, waits for a
+// boot-up message from every node, broadcasts start and waits for every
+// booted node to report Operational. Nodes that fail either step are
+// reported as NodeError values combined with errors.Join; nil means all
+// nodes are operational.
+func (m *NMTMaster) Bootstrap(ctx context.Context, nodes NodeSet) error {
+    // Subscribe before resetting so no boot-up message is missed.
+    hbs, cancel := SubscribeHeartbeats(m.mux, nil, 2*nodes.Len()+8)
+    defer cancel()
+
+    if err := m.Reset(0); err != nil {
+        return err
+    }
+    failed := m.awaitState(ctx, hbs, nodes, StateBootup)
+
+    booted := nodes
+    for node := range failed {
+        booted.Remove(node)
+    }
+    if booted.Len() > 0 {
+        if err := m.Command(NMTStart, 0); err != nil {
+            return err
+        }
+        for node, err := range m.awaitState(ctx, hbs, booted, StateOperational) {
+            failed[node] = err
+        }
+    }
+
+    var errs []error
+    for _, node := range nodes.Nodes() {
+        if err, ok := failed[node]; ok {
+            errs = append(errs, NodeError{Node: node, Err: err})
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// awaitState consumes heartbeats until every node in pending reports state,
+// the timeout expires or ctx is done. It returns the error for each node
+// that did not report.
+func (m *NMTMaster) awaitState(ctx context.Context, hbs <-chan Heartbeat, pending NodeSet, state NMTState) map[NodeID]error {
+    var expired <-chan time.Time
+    if m.timeout > 0 {
+        t := time.NewTimer(m.timeout)
+        defer t.Stop()
+        expired = t.C
+    }
+    fail := func(err error) map[NodeID]error {
+        out := make(map[NodeID]error)
+        for _, node := range pending.Nodes() {
+            out[node] = err
+        }
+        return out
+    }
+    for pending.Len() > 0 {
+        select {
+        case hb, ok := <-hbs:
+            if !ok {
+                return fail(canbus.ErrClosed)
+            }
+            if hb.State == state {
+                pending.Remove(hb.Node)
+            }
+        case <-expired:
+            return fail(ErrStateTimeout)
+        case <-ctx.Done():
+            return fail(ctx.Err())
+        }
+    }
+    return map[NodeID]error{}
+}
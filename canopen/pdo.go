@@ -0,0 +1,152 @@
+package canopen
+
+import (
+    "fmt"
+)
+
+// ByteOrder selects how a PDO field is laid out in the payload.
+type ByteOrder uint8
+
+const (
+    // LittleEndian (Intel) is the CANopen default: Start is the position of
+    // the least significant bit and the field extends towards higher bits.
+    LittleEndian ByteOrder = iota
+    // BigEndian (Motorola) follows the DBC convention: Start is the position
+    // of the most significant bit; bits continue towards bit 0 of that byte
+    // and then wrap to bit 7 of the next byte.
+    BigEndian
+)
+
+// Bit positions are numbered byte*8 + bit, where bit 0 is the least
+// significant bit of a byte, for both byte orders.
+
+// SetBits stores the low length bits of value into buf starting at start
+// using the given byte order. It panics if the field does not fit in buf.
+func SetBits(buf []byte, start, length uint, value uint64, order ByteOrder) {
+    forEachBit(start, length, order, func(k, pos uint) {
+        if value&(1<<k) != 0 {
+            buf[pos/8] |= 1 << (pos % 8)
+        } else {
+            buf[pos/8] &^= 1 << (pos % 8)
+        }
+    })
+}
+
+// GetBits extracts a length-bit field from buf starting at start using the
+// given byte order. It panics if the field does not fit in buf.
+func GetBits(buf []byte, start, length uint, order ByteOrder) uint64 {
+    var v uint64
+    forEachBit(start, length, order, func(k, pos uint) {
+        if buf[pos/8]&(1<<(pos%8)) != 0 {
+            v |= 1 << k
+        }
+    })
+    return v
+}
+
+// forEachBit calls fn for every bit of a field with k the bit index within
+// the value and pos the bit position in the payload.
+func forEachBit(start, length uint, order ByteOrder, fn func(k, pos uint)) {
+    if order == BigEndian {
+        pos := start
+        for i := uint(0); i < length; i++ {
+            fn(length-1-i, pos)
+            if pos%8 == 0 {
+                pos += 15
+            } else {
+                pos--
+            }
+        }
+        return
+    }
+    for k := uint(0); k < length; k++ {
+        fn(k, start+k)
+    }
+}
+
+// PDOField describes one signal within a PDO payload.
+type PDOField struct {
+    Name   string
+    Start  uint      // LSB position (LittleEndian) or MSB position (BigEndian)
+    Length uint      // 1..64 bits
+    Order  ByteOrder
+}
+
+// PDOLayout is the set of fields packed into one PDO. Fields may mix byte
+// orders but must not overlap.
+type PDOLayout []PDOField
+
+// Validate checks that every field fits in 8 bytes and no two fields share
+// a bit.
+func (l PDOLayout) Validate() error {
+    var used uint64
+    for _, fld := range l {
+        if fld.Length < 1 || fld.Length > 64 {
+            return fmt.Errorf("canopen: PDO field %q length %d invalid (1..64)", fld.Name, fld.Length)
+        }
+        var mask uint64
+        var err error
+        forEachBit(fld.Start, fld.Length, fld.Order, func(_, pos uint) {
+            if pos >= 64 {
+                err = fmt.Errorf("canopen: PDO field %q exceeds 8 bytes", fld.Name)
+                return
+            }
+            mask |= 1 << pos
+        })
+        if err != nil {
+            return err
+        }
+        if used&mask != 0 {
+            return fmt.Errorf("canopen: PDO field %q overlaps another field", fld.Name)
+        }
+        used |= mask
+    }
+    return nil
+}
+
+// size returns the number of payload bytes needed to hold all fields.
+func (l PDOLayout) size() int {
+    n := 0
+    for _, fld := range l {
+        forEachBit(fld.Start, fld.Length, fld.Order, func(_, pos uint) {
+            if int(pos/8)+1 > n {
+                n = int(pos/8) + 1
+            }
+        })
+    }
+    return n
+}
+
+// Pack encodes values, one per field in layout order, into a PDO payload
+// just long enough to hold all fields.
+func (l PDOLayout) Pack(values []uint64) ([]byte, error) {
+    if err := l.Validate(); err != nil {
+        return nil, err
+    }
+    if len(values) != len(l) {
+        return nil, fmt.Errorf("canopen: PDO pack got %d values for %d fields", len(values), len(l))
+    }
+    buf := make([]byte, l.size())
+    for i, fld := range l {
+        if fld.Length < 64 && values[i]>>fld.Length != 0 {
+            return nil, fmt.Errorf("canopen: PDO field %q value 0x%X exceeds %d bits", fld.Name, values[i], fld.Length)
+        }
+        SetBits(buf, fld.Start, fld.Length, values[i], fld.Order)
+    }
+    return buf, nil
+}
+
+// Unpack decodes one value per field in layout order from a PDO payload.
+func (l PDOLayout) Unpack(data []byte) ([]uint64, error) {
+    if err := l.Validate(); err != nil {
+        return nil, err
+    }
+    if n := l.size(); len(data) < n {
+        return nil, fmt.Errorf("canopen: PDO payload too short: %d, want %d", len(data), n)
+    }
+    out := make([]uint64, len(l))
+    for i, fld := range l {
+        out[i] = GetBits(data, fld.Start, fld.Length, fld.Order)
+    }
+    return out, nil
+}
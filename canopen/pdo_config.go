@@ -0,0 +1,163 @@
+package canopen
+
+import (
+    "errors"
+    "fmt"
+    "time"
+)
+
+// PDO communication and mapping parameter objects (CiA 301). The object for
+// PDO n (1-based) is the base plus n-1.
+const (
+    ObjRPDOCommBase    uint16 = 0x1400
+    ObjRPDOMappingBase uint16 = 0x1600
+    ObjTPDOCommBase    uint16 = 0x1800
+    ObjTPDOMappingBase uint16 = 0x1A00
+)
+
+// PDOKind selects receive or transmit PDOs.
+type PDOKind uint8
+
+const (
+    RPDO PDOKind = iota
+    TPDO
+)
+
+func (k PDOKind) String() string {
+    if k == TPDO {
+        return "TPDO"
+    }
+    return "RPDO"
+}
+
+// PDO COB-ID entry flags (sub-index 1 of the communication parameter).
+const (
+    pdoCOBIDInvalid  uint32 = 1 << 31 // PDO disabled
+    pdoCOBIDExtended uint32 = 1 << 29 // 29-bit identifier
+)
+
+// PDOMapping is one entry of a PDO mapping parameter: the mapped object and
+// its length in bits.
+type PDOMapping struct {
+    Index    uint16
+    Subindex uint8
+    Bits     uint8
+}
+
+// PDOConfig is the communication and mapping configuration of one PDO.
+//
+// Present is false when the device does not implement the PDO; all other
+// fields are then zero. Enabled reflects the inverted "valid" bit 31 of the
+// COB-ID entry: a disabled PDO still reports its configured COB-ID and
+// mapping. InhibitTime and EventTimer are zero when the device does not
+// implement the corresponding sub-index.
+type PDOConfig struct {
+    Present          bool
+    COBID            uint32 // identifier bits 28..0 of the COB-ID entry
+    Enabled          bool
+    Extended         bool   // bit 29 of the COB-ID entry
+    TransmissionType uint8
+    InhibitTime      time.Duration // sub-index 3, 100 µs units
+    EventTimer       time.Duration // sub-index 5, ms units
+    Mappings         []PDOMapping
+}
+
+// NodePDOConfig holds the configuration of PDOs 1-4 in each direction;
+// TPDO[0] is TPDO1.
+type NodePDOConfig struct {
+    TPDO [4]PDOConfig
+    RPDO [4]PDOConfig
+}
+
+// ReadPDOConfig reads the communication and mapping parameters of PDO n
+// (1..512) of the given kind. A PDO whose communication object does not
+// exist on the device is reported with Present false and a nil error.
+func ReadPDOConfig(client *SDOClient, kind PDOKind, n int) (PDOConfig, error) {
+    if n < 1 || n > 512 {
+        return PDOConfig{}, fmt.Errorf("canopen: %s number %d out of range (1..512)", kind, n)
+    }
+    comm, mapping := ObjRPDOCommBase, ObjRPDOMappingBase
+    if kind == TPDO {
+        comm, mapping = ObjTPDOCommBase, ObjTPDOMappingBase
+    }
+    comm += uint16(n - 1)
+    mapping += uint16(n - 1)
+
+    highest, err := client.ReadU8(comm, 0x00)
+    if isSDOAbortCode(err, 0x06020000) {
+        return PDOConfig{}, nil
+    }
+    if err != nil {
+        return PDOConfig{}, err
+    }
+
+    cfg := PDOConfig{Present: true}
+    raw, err := client.ReadU32(comm, 0x01)
+    if err != nil {
+        return cfg, err
+    }
+    cfg.COBID = raw & 0x1FFFFFFF
+    cfg.Enabled = raw&pdoCOBIDInvalid == 0
+    cfg.Extended = raw&pdoCOBIDExtended != 0
+    if highest >= 2 {
+        if cfg.TransmissionType, err = client.ReadU8(comm, 0x02); err != nil {
+            return cfg, err
+        }
+    }
+    if highest >= 3 {
+        v, err := client.ReadU16(comm, 0x03)
+        if err != nil && !isSDOAbortCode(err, 0x06090011) {
+            return cfg, err
+        }
+        cfg.InhibitTime = time.Duration(v) * 100 * time.Microsecond
+    }
+    if kind == TPDO && highest >= 5 {
+        v, err := client.ReadU16(comm, 0x05)
+        if err != nil && !isSDOAbortCode(err, 0x06090011) {
+            return cfg, err
+        }
+        cfg.EventTimer = time.Duration(v) * time.Millisecond
+    }
+
+    count, err := client.ReadU8(mapping, 0x00)
+    if err != nil {
+        return cfg, err
+    }
+    for sub := uint8(1); sub <= count && sub <= 64; sub++ {
+        e, err := client.ReadU32(mapping, sub)
+        if err != nil {
+            return cfg, err
+        }
+        cfg.Mappings = append(cfg.Mappings, PDOMapping{Index: uint16(e >> 16), Subindex: uint8(e >> 8), Bits: uint8(e)})
+    }
+    return cfg, nil
+}
+
+// ReadAllPDOConfig reads TPDO1-4 and RPDO1-4 of the node served by client.
+// PDOs the device does not implement are skipped and left with Present
+// false. The first other error aborts the read and is returned together
+// with the configuration read so far.
+func ReadAllPDOConfig(client *SDOClient) (NodePDOConfig, error) {
+    var out NodePDOConfig
+    for i := range out.TPDO {
+        cfg, err := ReadPDOConfig(client, TPDO, i+1)
+        out.TPDO[i] = cfg
+        if err != nil {
+            return out, err
+        }
+    }
+    for i := range out.RPDO {
+        cfg, err := ReadPDOConfig(client, RPDO, i+1)
+        out.RPDO[i] = cfg
+        if err != nil {
+            return out, err
+        }
+    }
+    return out, nil
+}
+
+// isSDOAbortCode reports whether err is an SDOAbort with the given code.
+func isSDOAbortCode(err error, code uint32) bool {
+    var ab SDOAbort
+    return errors.As(err, &ab) && ab.Code == code
+}
@@ -0,0 +1,74 @@
+package canopen
+
+import (
+    "time"
+
+    "github.com/notnil/canbus"
+)
+
+// PDOInhibitViolation reports a PDO transmitted sooner after the previous
+// frame with the same COB-ID than its configured inhibit time allows.
+type PDOInhibitViolation struct {
+    COBID   uint32
+    Gap     time.Duration // measured time since the previous frame
+    Inhibit time.Duration // configured minimum gap
+    At      time.Time     // timestamp of the offending frame
+}
+
+// PDOInhibitMonitor watches PDOs on a Mux and reports inhibit-time
+// violations. Gaps are measured between frame timestamps (Frame.Timestamp,
+// e.g. from SocketCANOptions.Timestamping). Frames without one are
+// timestamped on arrival at the monitor, so gaps include delivery jitter; a
+// consumer that falls behind the bus can then see frames compressed
+// together and report spurious violations, so size the buffer generously.
+type PDOInhibitMonitor struct {
+    out    chan PDOInhibitViolation
+    cancel func()
+}
+
+// NewPDOInhibitMonitor subscribes to the standard frames whose COB-IDs are
+// keys of inhibit and measures inter-frame gaps against the mapped inhibit
+// times. A zero inhibit time disables checking for that COB-ID. The map is
+// copied. Close must be called when done.
+func NewPDOInhibitMonitor(mux *canbus.Mux, inhibit map[uint32]time.Duration, buffer int) *PDOInhibitMonitor {
+    expected := make(map[uint32]time.Duration, len(inhibit))
+    ids := make([]uint32, 0, len(inhibit))
+    for id, d := range inhibit {
+        expected[id] = d
+        ids = append(ids, id)
+    }
+    frames, cancel := mux.Subscribe(canbus.And(canbus.StandardOnly(), canbus.ByIDs(ids...)), buffer)
+
+    m := &PDOInhibitMonitor{out: make(chan PDOInhibitViolation, buffer), cancel: cancel}
+    go func() {
+        defer close(m.out)
+        last := make(map[uint32]time.Time, len(expected))
+        for f := range frames {
+            now := f.Timestamp
+            if now.IsZero() {
+                now = time.Now()
+            }
+            prev, seen := last[f.ID]
+            last[f.ID] = now
+            want := expected[f.ID]
+            if !seen || want <= 0 {
+                continue
+            }
+            if gap := now.Sub(prev); gap < want {
+                m.out <- PDOInhibitViolation{COBID: f.ID, Gap: gap, Inhibit: want, At: now}
+            }
+        }
+    }()
+    return m
+}
+
+// Violations returns the channel of detected violations. It is closed after
+// Close or when the underlying mux is closed.
+func (m *PDOInhibitMonitor) Violations() <-chan PDOInhibitViolation {
+    return m.out
+}
+
+// Close stops monitoring.
+func (m *PDOInhibitMonitor) Close() {
+    m.cancel()
+}
@@ -0,0 +1,156 @@
+package canopen
+
+import (
+    "sync"
+    "time"
+
+    "github.com/notnil/canbus"
+)
+
+// PeriodicProducer is a periodic CANopen service such as SYNC, TIME or
+// heartbeat production. Frame returns the next frame to send and false to
+// skip the current period. A non-positive Period disables the producer.
+type PeriodicProducer interface {
+    Frame() (canbus.Frame, bool)
+    Period() time.Duration
+}
+
+// runPeriodic sends p's frames on bus every period until stop is closed.
+// A value received on reset (which may be nil) becomes the new period.
+// It backs the standalone Start methods of the individual producers.
+func runPeriodic(bus canbus.Bus, p PeriodicProducer, stop <-chan struct{}, reset <-chan time.Duration) {
+    if p.Period() <= 0 {
+        return
+    }
+    ticker := time.NewTicker(p.Period())
+    defer ticker.Stop()
+    for {
+        select {
+        case <-stop:
+            return
+        case d := <-reset:
+            ticker.Reset(d)
+        case <-ticker.C:
+            if f, ok := p.Frame(); ok {
+                _ = bus.Send(f)
+            }
+        }
+    }
+}
+
+// Scheduler drives any number of PeriodicProducers from a single goroutine
+// and timer, sending their frames on one bus.
+type Scheduler struct {
+    bus     canbus.Bus
+    onError func(error)
+
+    mu        sync.Mutex
+    producers []*scheduled
+    wake      chan struct{}
+    stop      chan struct{}
+    done      chan struct{}
+}
+
+type scheduled struct {
+    p    PeriodicProducer
+    next time.Time
+}
+
+// NewScheduler creates a scheduler sending on bus. If onError is non-nil it
+// is called with every Send error; otherwise errors are ignored.
+func NewScheduler(bus canbus.Bus, onError func(error)) *Scheduler {
+    return &Scheduler{bus: bus, onError: onError, wake: make(chan struct{}, 1)}
+}
+
+// Add registers a producer. Its first frame is sent one period from now.
+// Producers may be added before or after Start.
+func (s *Scheduler) Add(p PeriodicProducer) {
+    s.mu.Lock()
+    s.producers = append(s.producers, &scheduled{p: p, next: time.Now().Add(p.Period())})
+    s.mu.Unlock()
+    select {
+    case s.wake <- struct{}{}:
+    default:
+    }
+}
+
+// Start launches the scheduling goroutine. Calling Start on a running
+// scheduler has no effect.
+func (s *Scheduler) Start() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.stop != nil {
+        return
+    }
+    s.stop = make(chan struct{})
+    s.done = make(chan struct{})
+    go s.run(s.stop, s.done)
+}
+
+// Stop halts the scheduling goroutine and waits for it to exit.
+func (s *Scheduler) Stop() {
+    s.mu.Lock()
+    stop, done := s.stop, s.done
+    s.stop, s.done = nil, nil
+    s.mu.Unlock()
+    if stop == nil {
+        return
+    }
+    close(stop)
+    <-done
+}
+
+func (s *Scheduler) run(stop, done chan struct{}) {
+    defer close(done)
+    timer := time.NewTimer(time.Hour)
+    defer timer.Stop()
+    for {
+        now := time.Now()
+        var due []PeriodicProducer
+        var next time.Time
+        s.mu.Lock()
+        for _, sp := range s.producers {
+            period := sp.p.Period()
+            if period <= 0 {
+                continue
+            }
+            if !now.Before(sp.next) {
+                due = append(due, sp.p)
+                sp.next = sp.next.Add(period)
+                if sp.next.Before(now) {
+                    sp.next = now.Add(period)
+                }
+            }
+            if next.IsZero() || sp.next.Before(next) {
+                next = sp.next
+            }
+        }
+        s.mu.Unlock()
+
+        for _, p := range due {
+            f, ok := p.Frame()
+            if !ok {
+                continue
+            }
+            if err := s.bus.Send(f); err != nil && s.onError != nil {
+                s.onError(err)
+            }
+        }
+
+        if !timer.Stop() {
+            select {
+            case <-timer.C:
+            default:
+            }
+        }
+        if !next.IsZero() {
+            timer.Reset(time.Until(next))
+        }
+        select {
+        case <-stop:
+            return
+        case <-s.wake:
+        case <-timer.C:
+        }
+    }
+}
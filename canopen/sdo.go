@@ -1,6 +1,7 @@
 package canopen
 
 import (
+    "context"
     "encoding/binary"
     "fmt"
     "time"
@@ -27,6 +28,23 @@ type SDOClient struct {
     // is not set. This skips segmented upload and returns up to 4 bytes.
     // Intended for devices that put data in 4..7 but leave e=0.
     lenientUploadExpeditedOnly bool
+    // extended selects 29-bit COB-IDs with idPrefix in bits 28..11.
+    extended bool
+    idPrefix uint32
+    // onRTT, when set, receives the round-trip time of every request that
+    // got a response.
+    onRTT func(SDORoundTrip)
+}
+
+// SDORoundTrip is the time from sending an SDO request to receiving the
+// matching response (including an abort). Segmented transfers report the
+// initiate exchange and each segment separately.
+type SDORoundTrip struct {
+    Node     NodeID
+    Index    uint16
+    Subindex uint8
+    Segment  bool // true for a segment exchange, false for initiate
+    RTT      time.Duration
 }
 
 // ExpeditedMode selects the encoding for expedited SDO download command byte.
@@ -47,7 +65,8 @@ const (
 // SDOClientOption configures an SDOClient during construction.
 type SDOClientOption func(*SDOClient)
 
-// WithTimeout sets the mux wait timeout; zero means wait indefinitely.
+// WithTimeout sets the mux wait timeout; zero means wait indefinitely. A
+// transfer whose response does not arrive in time fails with ErrSDOTimeout.
 func WithTimeout(d time.Duration) SDOClientOption {
     return func(c *SDOClient) { c.timeout = d }
 }
@@ -65,6 +84,25 @@ func WithLenientUpload() SDOClientOption {
     return func(c *SDOClient) { c.lenientUploadExpeditedOnly = true }
 }
 
+// WithExtendedCOBID configures the client for a 29-bit CANopen network:
+// requests are sent as extended frames with COB-ID COBIDExt(FC_SDO_RX, node,
+// prefix) and only extended responses with the matching prefix are accepted.
+// prefix must fit in 18 bits; extra bits are discarded.
+func WithExtendedCOBID(prefix uint32) SDOClientOption {
+    return func(c *SDOClient) {
+        c.extended = true
+        c.idPrefix = prefix & maxCOBIDExtPrefix
+    }
+}
+
+// WithRTTHook registers fn to observe the round-trip time of each SDO
+// request/response exchange, e.g. to feed a latency histogram. fn runs
+// synchronously on the transfer path and should return quickly. Without the
+// hook no timing is taken.
+func WithRTTHook(fn func(SDORoundTrip)) SDOClientOption {
+    return func(c *SDOClient) { c.onRTT = fn }
+}
+
 // NewSDOClient constructs an SDOClient with optional configuration.
 // Defaults: timeout=0 (wait indefinitely), expeditedMode=ExpeditedModeSpec.
 func NewSDOClient(bus canbus.Bus, node NodeID, mux *canbus.Mux, opts ...SDOClientOption) *SDOClient {
@@ -76,11 +114,66 @@ func NewSDOClient(bus canbus.Bus, node NodeID, mux *canbus.Mux, opts ...SDOClien
     return c
 }
 
+// sdoSent describes a request handed to the bus, so wait can report its
+// round-trip time. It is zero unless the client has an RTT hook.
+type sdoSent struct {
+    at  time.Time
+    cmd byte
+}
+
+// send transmits a request frame, rewriting its identifier to the extended
+// layout when the client is configured for a 29-bit network.
+func (c *SDOClient) send(f canbus.Frame) (sdoSent, error) {
+    if c.extended {
+        f.ID = COBIDExt(FC_SDO_RX, c.node, c.idPrefix)
+        f.Extended = true
+    }
+    var sent sdoSent
+    if c.onRTT != nil {
+        sent = sdoSent{at: time.Now(), cmd: f.Data[0] >> 5}
+    }
+    return sent, c.bus.Send(f)
+}
+
+// match returns m as a filter for the client's identifier layout.
+func (c *SDOClient) match(m SDOMatcher) canbus.FrameFilter {
+    m.Extended = c.extended
+    m.Prefix = c.idPrefix
+    return m.Match
+}
+
+// matchSegmentAbort matches aborts received during the segment phase of a
+// transfer of index/subindex: those naming the object and those with a zero
+// multiplexor. Aborts for other objects on a shared mux are ignored.
+func (c *SDOClient) matchSegmentAbort(index uint16, subindex uint8) canbus.FrameFilter {
+    return canbus.Or(
+        c.match(sdoMatchAbortFor(c.node, index, subindex)),
+        c.match(sdoMatchAbortNoMux(c.node)),
+    )
+}
+
 //
 
 // Download writes data to index/subindex. It uses expedited transfer for sizes
-// up to 4 bytes and segmented transfer for larger payloads.
+// up to 4 bytes and segmented transfer for larger payloads. Empty payloads
+// are rejected; CANopen has no expedited "write nothing".
+//
+// While segments are exchanged, an abort from the node ends the transfer
+// only if it names index/subindex or carries a zero multiplexor, which some
+// servers send in the segment phase; aborts for other objects (e.g. from a
+// concurrent transfer on a shared mux) are ignored.
 func (c *SDOClient) Download(index uint16, subindex uint8, data []byte) error {
+    return c.DownloadContext(context.Background(), index, subindex, data)
+}
+
+// DownloadContext is like Download but aborts the transfer when ctx is done:
+// the client sends an SDO abort to the server and returns context.Cause(ctx).
+// The abort code is 0x05040000 (SDO protocol timed out) unless the cause is
+// an SDOAbort, whose code is used instead (see context.WithCancelCause).
+func (c *SDOClient) DownloadContext(ctx context.Context, index uint16, subindex uint8, data []byte) error {
+    if len(data) == 0 {
+        return fmt.Errorf("canopen: sdo download of %04X:%02X requires at least 1 byte", index, subindex)
+    }
     if len(data) <= 4 {
         var req canbus.Frame
         var err error
@@ -94,42 +187,20 @@ func (c *SDOClient) Download(index uint16, subindex uint8, data []byte) error {
             return err
         }
 
-        ch, cancel := c.mux.Subscribe(func(f canbus.Frame) bool {
-            fc, node, err := ParseCOBID(f.ID)
-            if err != nil || fc != FC_SDO_TX || node != c.node || f.Len != 8 {
-                return false
-            }
-            cmd := (f.Data[0] >> 5) & 0x7
-            if cmd == sdoSCSAbort {
-                // Only deliver aborts for our index/subindex
-                idx := binary.LittleEndian.Uint16(f.Data[1:3])
-                sub := f.Data[3]
-                return idx == index && sub == subindex
-            }
-            if cmd != sdoSCSDownloadInitiate { return false }
-            idx := binary.LittleEndian.Uint16(f.Data[1:3])
-            sub := f.Data[3]
-            return idx == index && sub == subindex
-        }, 1)
+        ch, cancel := c.mux.Subscribe(canbus.Or(
+            c.match(sdoMatchAbortFor(c.node, index, subindex)),
+            c.match(sdoMatchDownloadInitiateOK(c.node, index, subindex)),
+        ), 1)
         defer cancel()
 
-        if err := c.bus.Send(req); err != nil {
+        sent, err := c.send(req)
+        if err != nil {
             return err
         }
 
-        var rsp canbus.Frame
-        if c.timeout > 0 {
-            select {
-            case f, ok := <-ch:
-                if !ok { return canbus.ErrClosed }
-                rsp = f
-            case <-time.After(c.timeout):
-                return canbus.ErrClosed
-            }
-        } else {
-            f, ok := <-ch
-            if !ok { return canbus.ErrClosed }
-            rsp = f
+        rsp, err := c.wait(ctx, ch, sent, index, subindex)
+        if err != nil {
+            return err
         }
         if _, ab, ok := parseSDOAbort(rsp); ok {
             return *ab
@@ -143,13 +214,14 @@ func (c *SDOClient) Download(index uint16, subindex uint8, data []byte) error {
     init := buildSDODownloadInitiateSegmented(c.node, index, subindex, total)
 
     // Wait for initiate response
-    chInit, cancelInit := c.mux.Subscribe(sdoServerFilterForNode(c.node, func(f canbus.Frame) bool {
-        if sdoCmd(f) == sdoSCSAbort { return sdoMatchAbortFor(index, subindex)(f) }
-        return sdoMatchDownloadInitiateOK(index, subindex)(f)
-    }), 1)
+    chInit, cancelInit := c.mux.Subscribe(canbus.Or(
+        c.match(sdoMatchAbortFor(c.node, index, subindex)),
+        c.match(sdoMatchDownloadInitiateOK(c.node, index, subindex)),
+    ), 1)
     defer cancelInit()
-    if err := c.bus.Send(init); err != nil { return err }
-    rspInit, err := waitWithTimeout(chInit, c.timeout)
+    sentInit, err := c.send(init)
+    if err != nil { return err }
+    rspInit, err := c.wait(ctx, chInit, sentInit, index, subindex)
     if err != nil { return err }
     if _, ab, ok := parseSDOAbort(rspInit); ok { return *ab }
 
@@ -164,14 +236,15 @@ func (c *SDOClient) Download(index uint16, subindex uint8, data []byte) error {
         seg := buildSDODownloadSegment(c.node, data[sent:sent+segLen], toggle, last)
 
         // Prepare waiter for ack
-        chSeg, cancelSeg := c.mux.Subscribe(sdoServerFilterForNode(c.node, func(f canbus.Frame) bool {
-            if sdoCmd(f) == sdoSCSAbort { return true }
-            return sdoMatchDownloadSegAck(toggle)(f)
-        }), 1)
+        chSeg, cancelSeg := c.mux.Subscribe(canbus.Or(
+            c.matchSegmentAbort(index, subindex),
+            c.match(sdoMatchDownloadSegAck(c.node, toggle)),
+        ), 1)
 
         // Send and wait
-        if err := c.bus.Send(seg); err != nil { cancelSeg(); return err }
-        rspSeg, err := waitWithTimeout(chSeg, c.timeout)
+        sentSeg, err := c.send(seg)
+        if err != nil { cancelSeg(); return err }
+        rspSeg, err := c.wait(ctx, chSeg, sentSeg, index, subindex)
         cancelSeg()
         if err != nil { return err }
         if _, ab, ok := parseSDOAbort(rspSeg); ok { return *ab }
@@ -184,25 +257,35 @@ func (c *SDOClient) Download(index uint16, subindex uint8, data []byte) error {
 
 // Upload reads an object. It supports both expedited and segmented transfers.
 func (c *SDOClient) Upload(index uint16, subindex uint8) ([]byte, error) {
+    return c.UploadContext(context.Background(), index, subindex)
+}
+
+// UploadContext is like Upload but aborts the transfer when ctx is done, as
+// described for DownloadContext.
+func (c *SDOClient) UploadContext(ctx context.Context, index uint16, subindex uint8) ([]byte, error) {
     req, err := sdoExpeditedUploadRequest(c.node, index, subindex)
     if err != nil {
         return nil, err
     }
 
-    ch, cancel := c.mux.Subscribe(sdoServerFilterForNode(c.node, func(f canbus.Frame) bool {
-        if sdoCmd(f) == sdoSCSAbort { return sdoMatchAbortFor(index, subindex)(f) }
-        return sdoMatchUploadInitiate()(f)
-    }), 2)
+    ch, cancel := c.mux.Subscribe(canbus.Or(
+        c.match(sdoMatchAbortFor(c.node, index, subindex)),
+        c.match(sdoMatchUploadInitiate(c.node)),
+    ), 2)
     defer cancel()
 
-    if err := c.bus.Send(req); err != nil {
+    sent, err := c.send(req)
+    if err != nil {
         return nil, err
     }
 
     // First response decides expedited vs segmented
-    first, err := waitWithTimeout(ch, c.timeout)
+    first, err := c.wait(ctx, ch, sent, index, subindex)
     if err != nil { return nil, err }
 
+    if first.Len != 8 {
+        return nil, frameErrorf(ErrInvalidLength, "canopen: SDO frame len %d, want 8", first.Len)
+    }
     if _, ab, ok := parseSDOAbort(first); ok {
         if ab.Index == index && ab.Subindex == subindex { return nil, *ab }
     }
@@ -224,15 +307,15 @@ func (c *SDOClient) Upload(index uint16, subindex uint8) ([]byte, error) {
 
     // Segmented upload initiate response expected
     if (first.Data[0]>>5)&0x7 != sdoSCSUploadInitiate {
-        return nil, fmt.Errorf("canopen: unexpected SDO response 0x%02X", first.Data[0])
+        return nil, frameErrorf(ErrUnexpectedSDOCommand, "canopen: unexpected SDO response 0x%02X", first.Data[0])
     }
     // e=0 for segmented
-    if (first.Data[0]&(1<<3)) != 0 {
-        return nil, fmt.Errorf("canopen: unexpected expedited flag in segmented upload response")
+    if _, expedited := sdoExpeditedUploadSize(first.Data[0]); expedited {
+        return nil, frameErrorf(ErrUnexpectedSDOCommand, "canopen: unexpected expedited flag in segmented upload response")
     }
-    // size indicated?
+    // size indicated? (bit2 in spec mode, bit0 in the CiA 301 layout)
     var total int = -1
-    if (first.Data[0]&(1<<2)) != 0 {
+    if (first.Data[0]&(1<<2)) != 0 || (first.Data[0]&1) != 0 {
         total = int(binary.LittleEndian.Uint32(first.Data[4:8]))
     }
     // Index/subindex must match
@@ -240,7 +323,8 @@ func (c *SDOClient) Upload(index uint16, subindex uint8) ([]byte, error) {
         return nil, fmt.Errorf("canopen: upload initiate index mismatch")
     }
 
-    // Now perform segmented upload loop
+    // Now perform segmented upload loop. The first segment uses toggle 0 and
+    // every response must echo the toggle of its request.
     out := make([]byte, 0, 256)
     toggle := byte(0)
     for {
@@ -253,18 +337,22 @@ func (c *SDOClient) Upload(index uint16, subindex uint8) ([]byte, error) {
         reqSeg.Data[0] = cmd
         // rest bytes zero
 
-        // Subscribe for matching segment response with toggle
-        chSeg, cancelSeg := c.mux.Subscribe(sdoServerFilterForNode(c.node, func(f canbus.Frame) bool {
-            if sdoCmd(f) == sdoSCSAbort { return true }
-            return sdoMatchUploadSeg(toggle)(f)
-        }), 1)
+        // Subscribe for any segment response; the toggle is validated below
+        chSeg, cancelSeg := c.mux.Subscribe(canbus.Or(
+            c.matchSegmentAbort(index, subindex),
+            c.match(sdoMatchUploadSeg(c.node)),
+        ), 1)
 
-        if err := c.bus.Send(reqSeg); err != nil { cancelSeg(); return nil, err }
-        var rsp canbus.Frame
-        rsp, err := waitWithTimeout(chSeg, c.timeout)
+        sentSeg, err := c.send(reqSeg)
+        if err != nil { cancelSeg(); return nil, err }
+        rsp, err := c.wait(ctx, chSeg, sentSeg, index, subindex)
         cancelSeg()
         if err != nil { return nil, err }
         if _, ab, ok := parseSDOAbort(rsp); ok { return nil, *ab }
+        if got := (rsp.Data[0] >> 4) & 0x1; got != toggle {
+            _, _ = c.send(buildSDOAbort(c.node, index, subindex, sdoAbortToggle))
+            return nil, fmt.Errorf("%w: upload segment %d has toggle %d, want %d", ErrSDOToggle, len(out)/7, got, toggle)
+        }
 
         // Extract data and flags
         segData, last, err := parseSDOUploadSegmentData(rsp)
@@ -282,6 +370,29 @@ func (c *SDOClient) Upload(index uint16, subindex uint8) ([]byte, error) {
     }
 }
 
+// sdoExpeditedUploadSize returns the number of significant data bytes of an
+// expedited upload initiate response, accepting both encodings in use:
+//   - this package's spec mode: e=bit3, s=bit2, n=bits1..0 (0x4C..0x4F)
+//   - the CiA 301 layout used by most devices: n=bits3..2, e=bit1, s=bit0
+//     (0x43/0x47/0x4B/0x4F, or 0x42 for 4 bytes without size)
+// Both agree on 0x4F (1 byte). n=0 means all 4 bytes are significant.
+// Older devices send e=1,s=0 (0x42, or 0x48 in spec mode) with 4 bytes of
+// data and no size; all 4 bytes are returned.
+func sdoExpeditedUploadSize(cmd byte) (int, bool) {
+    switch {
+    case cmd&0x0C == 0x0C:
+        return 4 - int(cmd&0x3), true
+    case cmd&0x0F == 0x08:
+        return 4, true
+    case cmd&0x02 != 0 && cmd&0x01 != 0:
+        return 4 - int(cmd>>2&0x3), true
+    case cmd&0x02 != 0:
+        return 4, true
+    default:
+        return 0, false
+    }
+}
+
 // Typed marshal/unmarshal helpers for common expedited cases (<=4 bytes)
 
 func (c *SDOClient) WriteU8(index uint16, subindex uint8, value uint8) error {
@@ -300,6 +411,42 @@ func (c *SDOClient) WriteU32(index uint16, subindex uint8, value uint32) error {
     return c.Download(index, subindex, b[:])
 }
 
+// WriteTimeOfDay writes t as a TIME_OF_DAY object (6 bytes, see
+// EncodeTimeOfDay).
+func (c *SDOClient) WriteTimeOfDay(index uint16, subindex uint8, t time.Time) error {
+    b := EncodeTimeOfDay(t)
+    return c.Download(index, subindex, b[:])
+}
+
+// ReadTimeOfDay reads a TIME_OF_DAY object. It fails if the object is not 6
+// bytes or its millisecond count exceeds one day.
+func (c *SDOClient) ReadTimeOfDay(index uint16, subindex uint8) (time.Time, error) {
+    b, err := c.Upload(index, subindex)
+    if err != nil { return time.Time{}, err }
+    if len(b) != 6 { return time.Time{}, fmt.Errorf("canopen: sdo read time of day: got %d bytes", len(b)) }
+    t := timeFromBytes([6]byte(b))
+    if err := t.Validate(); err != nil { return time.Time{}, err }
+    return t.Time(), nil
+}
+
+// WriteTimeDifference writes d as a TIME_DIFFERENCE object (6 bytes, see
+// EncodeTimeDifference).
+func (c *SDOClient) WriteTimeDifference(index uint16, subindex uint8, d time.Duration) error {
+    b := EncodeTimeDifference(d)
+    return c.Download(index, subindex, b[:])
+}
+
+// ReadTimeDifference reads a TIME_DIFFERENCE object. It fails if the object
+// is not 6 bytes or its millisecond count exceeds one day.
+func (c *SDOClient) ReadTimeDifference(index uint16, subindex uint8) (time.Duration, error) {
+    b, err := c.Upload(index, subindex)
+    if err != nil { return 0, err }
+    if len(b) != 6 { return 0, fmt.Errorf("canopen: sdo read time difference: got %d bytes", len(b)) }
+    t := timeFromBytes([6]byte(b))
+    if err := t.Validate(); err != nil { return 0, err }
+    return t.duration(), nil
+}
+
 func (c *SDOClient) ReadU8(index uint16, subindex uint8) (uint8, error) {
     b, err := c.Upload(index, subindex)
     if err != nil { return 0, err }
@@ -329,6 +476,53 @@ func (c *SDOClient) ReadU32(index uint16, subindex uint8) (uint32, error) {
     return binary.LittleEndian.Uint32(b), nil
 }
 
+// 24-bit helpers for UNSIGNED24 and INTEGER24 objects, transferred as 3
+// bytes (expedited, n=1).
+
+func (c *SDOClient) WriteU24(index uint16, subindex uint8, value uint32) error {
+    if value > 0xFFFFFF {
+        return fmt.Errorf("canopen: sdo write u24: value 0x%X exceeds 24 bits", value)
+    }
+    return c.Download(index, subindex, []byte{byte(value), byte(value >> 8), byte(value >> 16)})
+}
+
+func (c *SDOClient) WriteI24(index uint16, subindex uint8, value int32) error {
+    if value < -(1<<23) || value > 1<<23-1 {
+        return fmt.Errorf("canopen: sdo write i24: value %d out of range", value)
+    }
+    return c.WriteU24(index, subindex, uint32(value)&0xFFFFFF)
+}
+
+func (c *SDOClient) ReadU24(index uint16, subindex uint8) (uint32, error) {
+    b, err := c.Upload(index, subindex)
+    if err != nil { return 0, err }
+    if c.lenientUploadExpeditedOnly {
+        if len(b) < 3 { return 0, fmt.Errorf("canopen: sdo read u24: got %d bytes", len(b)) }
+    } else if len(b) != 3 {
+        return 0, fmt.Errorf("canopen: sdo read u24: got %d bytes", len(b))
+    }
+    return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16, nil
+}
+
+func (c *SDOClient) ReadI24(index uint16, subindex uint8) (int32, error) {
+    v, err := c.ReadU24(index, subindex)
+    if err != nil { return 0, err }
+    return int32(v<<8) >> 8, nil
+}
+
+// WriteDomain downloads a DOMAIN object (e.g. a firmware image or other
+// opaque binary). The bytes are transferred as-is with no endianness
+// interpretation; payloads above 4 bytes use segmented transfer.
+func (c *SDOClient) WriteDomain(index uint16, subindex uint8, data []byte) error {
+    return c.Download(index, subindex, data)
+}
+
+// ReadDomain uploads a DOMAIN object and returns its raw bytes without any
+// length or endianness interpretation.
+func (c *SDOClient) ReadDomain(index uint16, subindex uint8) ([]byte, error) {
+    return c.Upload(index, subindex)
+}
+
 
 // SDO command specifiers (CCS/SCS) per CiA 301
 const (
@@ -349,16 +543,33 @@ const (
     sdoSCSAbort            = 4
 )
 
-// (no generic command builder is exposed; helpers below encode per CiA 301)
+// SDOExpeditedDownload builds a client->server expedited download (write)
+// request carrying 1..4 data bytes, encoding the command byte per mode as
+// SDOClient does (see ExpeditedMode). It is the public way to build these
+// frames, e.g. for async clients, custom transports or tests.
+func SDOExpeditedDownload(target NodeID, index uint16, subindex uint8, data []byte, mode ExpeditedMode) (canbus.Frame, error) {
+    if mode == ExpeditedModeClassic {
+        return sdoExpeditedDownloadClassic(target, index, subindex, data)
+    }
+    return sdoExpeditedDownload(target, index, subindex, data)
+}
+
+// SDOExpeditedUploadRequest builds a client->server upload (read) initiate
+// request for index/subindex. The server answers expedited for objects of up
+// to 4 bytes and starts a segmented transfer otherwise.
+func SDOExpeditedUploadRequest(target NodeID, index uint16, subindex uint8) (canbus.Frame, error) {
+    return sdoExpeditedUploadRequest(target, index, subindex)
+}
 
 // sdoExpeditedDownload builds client->server expedited download frame (write).
-// It encodes index/subindex and up to 4 data bytes.
+// It encodes index/subindex and 1..4 data bytes. Zero bytes are rejected: n
+// has only two bits, so an empty payload would encode as a 4-byte write.
 func sdoExpeditedDownload(target NodeID, index uint16, subindex uint8, data []byte) (canbus.Frame, error) {
     if err := target.Validate(); err != nil {
         return canbus.Frame{}, err
     }
-    if len(data) > 4 {
-        return canbus.Frame{}, fmt.Errorf("canopen: expedited download max 4 bytes, got %d", len(data))
+    if len(data) < 1 || len(data) > 4 {
+        return canbus.Frame{}, fmt.Errorf("canopen: expedited download requires 1..4 bytes, got %d", len(data))
     }
     var f canbus.Frame
     f.ID = COBID(FC_SDO_RX, target)
@@ -412,24 +623,24 @@ func sdoExpeditedDownloadClassic(target NodeID, index uint16, subindex uint8, da
 
 // parseSDOExpeditedDownload decodes an expedited initiate download request.
 func parseSDOExpeditedDownload(f canbus.Frame) (NodeID, uint16, uint8, []byte, error) {
-    fc, node, err := ParseCOBID(f.ID)
+    fc, node, err := ParseFrameCOBID(f)
     if err != nil {
         return 0, 0, 0, nil, err
     }
     if fc != FC_SDO_RX {
-        return 0, 0, 0, nil, fmt.Errorf("canopen: not SDO rx frame (id=0x%X)", f.ID)
+        return 0, 0, 0, nil, wrongFrameErrorf(ErrNotSDOFrame, "canopen: not SDO rx frame (id=0x%X)", f.ID)
     }
     if f.Len != 8 {
-        return 0, 0, 0, nil, fmt.Errorf("canopen: SDO frame len %d, want 8", f.Len)
+        return 0, 0, 0, nil, frameErrorf(ErrInvalidLength, "canopen: SDO frame len %d, want 8", f.Len)
     }
     cmd := f.Data[0]
     if (cmd>>5)&0x7 != sdoCCSDownloadInitiate {
-        return 0, 0, 0, nil, fmt.Errorf("canopen: not initiate download (cmd=0x%02X)", cmd)
+        return 0, 0, 0, nil, frameErrorf(ErrUnexpectedSDOCommand, "canopen: not initiate download (cmd=0x%02X)", cmd)
     }
     expedited := (cmd & (1 << 3)) != 0
     sizeIndicated := (cmd & (1 << 2)) != 0
     if !expedited || !sizeIndicated {
-        return 0, 0, 0, nil, fmt.Errorf("canopen: only expedited+size indicated supported (cmd=0x%02X)", cmd)
+        return 0, 0, 0, nil, frameErrorf(ErrUnexpectedSDOCommand, "canopen: only expedited+size indicated supported (cmd=0x%02X)", cmd)
     }
     n := int(cmd & 0x3)
     if n < 0 || n > 3 {
@@ -461,28 +672,25 @@ func sdoExpeditedUploadRequest(target NodeID, index uint16, subindex uint8) (can
 
 // parseSDOExpeditedUploadResponse parses server->client expedited upload response.
 func parseSDOExpeditedUploadResponse(f canbus.Frame) (NodeID, uint16, uint8, []byte, error) {
-    fc, node, err := ParseCOBID(f.ID)
+    fc, node, err := ParseFrameCOBID(f)
     if err != nil {
         return 0, 0, 0, nil, err
     }
     if fc != FC_SDO_TX {
-        return 0, 0, 0, nil, fmt.Errorf("canopen: not SDO tx frame (id=0x%X)", f.ID)
+        return 0, 0, 0, nil, wrongFrameErrorf(ErrNotSDOFrame, "canopen: not SDO tx frame (id=0x%X)", f.ID)
     }
     if f.Len != 8 {
-        return 0, 0, 0, nil, fmt.Errorf("canopen: SDO frame len %d, want 8", f.Len)
+        return 0, 0, 0, nil, frameErrorf(ErrInvalidLength, "canopen: SDO frame len %d, want 8", f.Len)
     }
     cmd := f.Data[0]
     // For upload response, SCS=2 in bits 7..5, e and s set for expedited with size indicated
     if (cmd>>5)&0x7 != sdoSCSUploadInitiate {
-        return 0, 0, 0, nil, fmt.Errorf("canopen: not upload response (cmd=0x%02X)", cmd)
+        return 0, 0, 0, nil, frameErrorf(ErrUnexpectedSDOCommand, "canopen: not upload response (cmd=0x%02X)", cmd)
     }
-    expedited := (cmd & (1 << 3)) != 0
-    sizeIndicated := (cmd & (1 << 2)) != 0
-    if !expedited || !sizeIndicated {
-        return 0, 0, 0, nil, fmt.Errorf("canopen: only expedited+size indicated supported (cmd=0x%02X)", cmd)
+    size, ok := sdoExpeditedUploadSize(cmd)
+    if !ok {
+        return 0, 0, 0, nil, frameErrorf(ErrUnexpectedSDOCommand, "canopen: only expedited upload responses supported (cmd=0x%02X)", cmd)
     }
-    n := int(cmd & 0x3)
-    size := 4 - n
     idx := binary.LittleEndian.Uint16(f.Data[1:3])
     sub := f.Data[3]
     out := make([]byte, size)
@@ -23,7 +23,7 @@ func (e SDOAbort) Error() string {
 
 // parseSDOAbort returns node id, abort error (if this frame is an abort), and ok flag.
 func parseSDOAbort(f canbus.Frame) (NodeID, *SDOAbort, bool) {
-    fc, node, err := ParseCOBID(f.ID)
+    fc, node, err := ParseFrameCOBID(f)
     if err != nil || fc != FC_SDO_TX || f.Len != 8 {
         return 0, nil, false
     }
@@ -39,6 +39,35 @@ func parseSDOAbort(f canbus.Frame) (NodeID, *SDOAbort, bool) {
     return node, ab, true
 }
 
+// SDOAbortEvent is an SDO abort observed on the bus from a server node.
+type SDOAbortEvent struct {
+    Node  NodeID
+    Abort SDOAbort
+}
+
+// SubscribeSDOAborts subscribes to SDO abort responses from any node via mux
+// and delivers parsed events. The returned cancel must be called when done.
+// The channel will be closed on cancel or if the underlying mux is closed.
+func SubscribeSDOAborts(mux *canbus.Mux, buffer int) (<-chan SDOAbortEvent, func()) {
+    frames, cancel := mux.Subscribe(func(f canbus.Frame) bool {
+        _, _, ok := parseSDOAbort(f)
+        return ok
+    }, buffer)
+
+    out := make(chan SDOAbortEvent, buffer)
+    go func() {
+        defer close(out)
+        for f := range frames {
+            node, ab, ok := parseSDOAbort(f)
+            if !ok {
+                continue
+            }
+            out <- SDOAbortEvent{Node: node, Abort: *ab}
+        }
+    }()
+    return out, cancel
+}
+
 // Common SDO abort codes (subset of CiA 301)
 var sdoAbortText = map[uint32]string{
     0x05030000: "toggle bit not alternated",
@@ -0,0 +1,37 @@
+package canopen
+
+import "fmt"
+
+// WriteArray downloads values to consecutive subindices of index starting at
+// startSub. It stops at the first failing transfer and returns its error.
+func WriteArray(client *SDOClient, index uint16, startSub uint8, values [][]byte) error {
+    if int(startSub)+len(values) > 256 {
+        return fmt.Errorf("canopen: array of %d values from subindex %d exceeds subindex range", len(values), startSub)
+    }
+    for i, v := range values {
+        if err := client.Download(index, startSub+uint8(i), v); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// ReadArray uploads up to count consecutive subindices of index starting at
+// startSub. Reading stops at the first failing transfer and the values read
+// so far are returned together with its error. When the array is shorter
+// than count the device typically ends it with an SDOAbort of "object does
+// not exist" (0x06020000) or "sub-index does not exist" (0x06090011).
+func ReadArray(client *SDOClient, index uint16, startSub uint8, count int) ([][]byte, error) {
+    if count < 0 || int(startSub)+count > 256 {
+        return nil, fmt.Errorf("canopen: array of %d values from subindex %d exceeds subindex range", count, startSub)
+    }
+    out := make([][]byte, 0, count)
+    for i := 0; i < count; i++ {
+        b, err := client.Upload(index, startSub+uint8(i))
+        if err != nil {
+            return out, err
+        }
+        out = append(out, b)
+    }
+    return out, nil
+}
@@ -0,0 +1,121 @@
+package canopen
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/notnil/canbus"
+)
+
+// SDOAsyncClient issues expedited SDO transfers without blocking the caller.
+// Each call returns a channel that receives exactly one SDOResult once the
+// server answers, aborts or the timeout expires. Transfers larger than 4
+// bytes are not supported; use SDOClient for segmented transfers.
+//
+// Like SDOClient, it waits for responses via a Mux so other consumers of
+// Receive are not blocked. Concurrent transfers to the same object are not
+// distinguished and should be avoided.
+type SDOAsyncClient struct {
+    bus     canbus.Bus
+    mux     *canbus.Mux
+    node    NodeID
+    timeout time.Duration
+    // expeditedMode selects the download command byte encoding, as for
+    // SDOClient.
+    expeditedMode ExpeditedMode
+}
+
+// SDOAsyncOption configures an SDOAsyncClient during construction.
+type SDOAsyncOption func(*SDOAsyncClient)
+
+// WithAsyncTimeout bounds how long a transfer waits for its response; zero
+// (the default) means wait indefinitely. A timed out transfer fails with
+// ErrSDOTimeout, as for SDOClient.
+func WithAsyncTimeout(d time.Duration) SDOAsyncOption {
+    return func(c *SDOAsyncClient) { c.timeout = d }
+}
+
+// WithAsyncExpeditedMode selects the encoding used for expedited downloads.
+// The default is ExpeditedModeSpec, matching SDOClient.
+func WithAsyncExpeditedMode(m ExpeditedMode) SDOAsyncOption {
+    return func(c *SDOAsyncClient) { c.expeditedMode = m }
+}
+
+// NewSDOAsyncClient constructs an async client for node. It fails if node
+// is not in the range 1..127.
+func NewSDOAsyncClient(bus canbus.Bus, mux *canbus.Mux, node NodeID, opts ...SDOAsyncOption) (*SDOAsyncClient, error) {
+    if mux == nil {
+        return nil, fmt.Errorf("canopen: SDOAsyncClient requires a non-nil Mux")
+    }
+    if err := node.Validate(); err != nil {
+        return nil, err
+    }
+    c := &SDOAsyncClient{bus: bus, mux: mux, node: node}
+    for _, opt := range opts { opt(c) }
+    return c, nil
+}
+
+// UploadAsync reads an object of up to 4 bytes. An abort from the server for
+// index/subindex is delivered as an SDOAbort error.
+func (c *SDOAsyncClient) UploadAsync(index uint16, subindex uint8) <-chan SDOResult {
+    req, err := SDOExpeditedUploadRequest(c.node, index, subindex)
+    if err != nil {
+        return sdoResultNow(SDOResult{Err: err})
+    }
+    return c.start(req, index, subindex, sdoMatchUploadInitiate(c.node), func(rsp canbus.Frame) SDOResult {
+        _, idx, sub, data, err := parseSDOExpeditedUploadResponse(rsp)
+        if err != nil {
+            return SDOResult{Err: err}
+        }
+        if idx != index || sub != subindex {
+            return SDOResult{Err: fmt.Errorf("canopen: upload response for %04X:%02X, want %04X:%02X", idx, sub, index, subindex)}
+        }
+        return SDOResult{Data: data}
+    })
+}
+
+// DownloadAsync writes 1..4 bytes to an object using an expedited transfer
+// encoded per the client's ExpeditedMode.
+// An abort from the server for index/subindex is delivered as an SDOAbort
+// error.
+func (c *SDOAsyncClient) DownloadAsync(index uint16, subindex uint8, data []byte) <-chan SDOResult {
+    req, err := SDOExpeditedDownload(c.node, index, subindex, data, c.expeditedMode)
+    if err != nil {
+        return sdoResultNow(SDOResult{Err: err})
+    }
+    return c.start(req, index, subindex, sdoMatchDownloadInitiateOK(c.node, index, subindex), func(canbus.Frame) SDOResult {
+        return SDOResult{}
+    })
+}
+
+// start subscribes for the response or an abort, sends req and completes the
+// transfer in the background using decode for non-abort responses.
+func (c *SDOAsyncClient) start(req canbus.Frame, index uint16, subindex uint8, ok SDOMatcher, decode func(canbus.Frame) SDOResult) <-chan SDOResult {
+    ch, cancel := c.mux.Subscribe(canbus.Or(sdoMatchAbortFor(c.node, index, subindex).Match, ok.Match), 1)
+    if err := c.bus.Send(req); err != nil {
+        cancel()
+        return sdoResultNow(SDOResult{Err: err})
+    }
+    out := make(chan SDOResult, 1)
+    go func() {
+        defer cancel()
+        rsp, err := waitWithTimeout(context.Background(), ch, c.timeout)
+        if err != nil {
+            out <- SDOResult{Err: err}
+            return
+        }
+        if _, ab, isAbort := parseSDOAbort(rsp); isAbort {
+            out <- SDOResult{Err: *ab}
+            return
+        }
+        out <- decode(rsp)
+    }()
+    return out
+}
+
+func sdoResultNow(r SDOResult) <-chan SDOResult {
+    out := make(chan SDOResult, 1)
+    out <- r
+    return out
+}
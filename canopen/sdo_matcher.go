@@ -0,0 +1,83 @@
+package canopen
+
+import (
+    "encoding/binary"
+
+    "github.com/notnil/canbus"
+)
+
+// SDOMatcher matches server->client SDO frames (COB-ID 0x580 + node).
+//
+// Node and Command are always compared; Command is the server command
+// specifier found in bits 7..5 of the first data byte. Index, Subindex and
+// Toggle are optional and only compared when non-nil. When Extended is set
+// the matcher expects 29-bit frames using the COBIDExt layout with Prefix.
+// Matchers can be used directly as filters via the Match method value, e.g.
+// mux.Subscribe(m.Match, 1), and composed with canbus.And/Or/Not.
+type SDOMatcher struct {
+    Node     NodeID
+    Command  byte
+    Index    *uint16
+    Subindex *uint8
+    Toggle   *byte
+    Extended bool
+    Prefix   uint32
+}
+
+// Match reports whether the frame is an SDO response satisfying the matcher.
+func (m SDOMatcher) Match(f canbus.Frame) bool {
+    if f.Extended != m.Extended {
+        return false
+    }
+    if m.Extended && f.ID>>11 != m.Prefix {
+        return false
+    }
+    fc, n, err := ParseFrameCOBID(f)
+    if err != nil || fc != FC_SDO_TX || n != m.Node || f.Len != 8 {
+        return false
+    }
+    if sdoCmd(f) != m.Command&0x7 {
+        return false
+    }
+    if m.Index != nil && binary.LittleEndian.Uint16(f.Data[1:3]) != *m.Index {
+        return false
+    }
+    if m.Subindex != nil && f.Data[3] != *m.Subindex {
+        return false
+    }
+    if m.Toggle != nil && (f.Data[0]>>4)&0x1 != *m.Toggle&0x1 {
+        return false
+    }
+    return true
+}
+
+// sdoMatchAbortFor matches aborts from node for index/subindex.
+func sdoMatchAbortFor(node NodeID, index uint16, subindex uint8) SDOMatcher {
+    return SDOMatcher{Node: node, Command: sdoSCSAbort, Index: &index, Subindex: &subindex}
+}
+
+// sdoMatchAbortNoMux matches aborts from node whose multiplexor is 0000:00.
+// CiA 301 has aborts repeat the index and subindex, but some servers leave
+// them zero once a segmented transfer is under way.
+func sdoMatchAbortNoMux(node NodeID) SDOMatcher {
+    return sdoMatchAbortFor(node, 0, 0)
+}
+
+func sdoMatchDownloadInitiateOK(node NodeID, index uint16, subindex uint8) SDOMatcher {
+    return SDOMatcher{Node: node, Command: sdoSCSDownloadInitiate, Index: &index, Subindex: &subindex}
+}
+
+func sdoMatchDownloadSegAck(node NodeID, toggle byte) SDOMatcher {
+    return SDOMatcher{Node: node, Command: sdoSCSDownloadSegment, Toggle: &toggle}
+}
+
+func sdoMatchUploadInitiate(node NodeID) SDOMatcher {
+    return SDOMatcher{Node: node, Command: sdoSCSUploadInitiate}
+}
+
+// sdoMatchUploadSeg matches upload segments regardless of toggle so that the
+// client can detect a server that fails to alternate it.
+func sdoMatchUploadSeg(node NodeID) SDOMatcher {
+    return SDOMatcher{Node: node, Command: sdoSCSUploadSegment}
+}
+
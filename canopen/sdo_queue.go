@@ -0,0 +1,126 @@
+package canopen
+
+import (
+    "container/heap"
+    "sync"
+
+    "github.com/notnil/canbus"
+)
+
+// SDOOp is a transfer run by an SDOQueue against its client. Reads return
+// the uploaded bytes; writes typically return nil data.
+type SDOOp func(c *SDOClient) ([]byte, error)
+
+// SDOResult is the outcome of a queued SDOOp.
+type SDOResult struct {
+    Data []byte
+    Err  error
+}
+
+// SDOQueue serializes SDO transfers to one node and runs them in priority
+// order, so an urgent write submitted behind queued bulk reads runs as soon
+// as the current transfer completes. Higher priorities run first; transfers
+// with equal priority run in submission order. Priorities are strict: a
+// steady stream of high-priority work delays lower priorities indefinitely.
+//
+// All transfers to the node should go through the queue; using the client
+// directly at the same time interleaves frames on the bus.
+type SDOQueue struct {
+    client *SDOClient
+
+    mu      sync.Mutex
+    cond    *sync.Cond
+    pending sdoQueueHeap
+    seq     uint64
+    closed  bool
+    done    chan struct{}
+}
+
+type sdoQueueItem struct {
+    priority int
+    seq      uint64
+    op       SDOOp
+    result   chan SDOResult
+}
+
+// NewSDOQueue starts a queue that runs transfers through client.
+func NewSDOQueue(client *SDOClient) *SDOQueue {
+    q := &SDOQueue{client: client, done: make(chan struct{})}
+    q.cond = sync.NewCond(&q.mu)
+    go q.run()
+    return q
+}
+
+// Submit enqueues op with the given priority. The returned channel receives
+// exactly one result. After Close, ops are rejected with canbus.ErrClosed.
+func (q *SDOQueue) Submit(priority int, op SDOOp) <-chan SDOResult {
+    result := make(chan SDOResult, 1)
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    if q.closed {
+        result <- SDOResult{Err: canbus.ErrClosed}
+        return result
+    }
+    heap.Push(&q.pending, &sdoQueueItem{priority: priority, seq: q.seq, op: op, result: result})
+    q.seq++
+    q.cond.Signal()
+    return result
+}
+
+// Close stops the queue after the running transfer, if any, completes.
+// Transfers still pending fail with canbus.ErrClosed.
+func (q *SDOQueue) Close() {
+    q.mu.Lock()
+    if q.closed {
+        q.mu.Unlock()
+        <-q.done
+        return
+    }
+    q.closed = true
+    for q.pending.Len() > 0 {
+        it := heap.Pop(&q.pending).(*sdoQueueItem)
+        it.result <- SDOResult{Err: canbus.ErrClosed}
+    }
+    q.cond.Broadcast()
+    q.mu.Unlock()
+    <-q.done
+}
+
+func (q *SDOQueue) run() {
+    defer close(q.done)
+    for {
+        q.mu.Lock()
+        for q.pending.Len() == 0 && !q.closed {
+            q.cond.Wait()
+        }
+        if q.closed {
+            q.mu.Unlock()
+            return
+        }
+        it := heap.Pop(&q.pending).(*sdoQueueItem)
+        q.mu.Unlock()
+
+        data, err := it.op(q.client)
+        it.result <- SDOResult{Data: data, Err: err}
+    }
+}
+
+// sdoQueueHeap orders items by descending priority, then submission order.
+type sdoQueueHeap []*sdoQueueItem
+
+func (h sdoQueueHeap) Len() int { return len(h) }
+func (h sdoQueueHeap) Less(i, j int) bool {
+    if h[i].priority != h[j].priority {
+        return h[i].priority > h[j].priority
+    }
+    return h[i].seq < h[j].seq
+}
+func (h sdoQueueHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sdoQueueHeap) Push(x interface{}) { *h = append(*h, x.(*sdoQueueItem)) }
+func (h *sdoQueueHeap) Pop() interface{} {
+    old := *h
+    it := old[len(old)-1]
+    old[len(old)-1] = nil
+    *h = old[:len(old)-1]
+    return it
+}
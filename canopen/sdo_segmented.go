@@ -1,9 +1,10 @@
 package canopen
 
 import (
+    "context"
     "encoding/binary"
+    "errors"
     "time"
-	"fmt"
 
     "github.com/notnil/canbus"
 )
@@ -11,17 +12,6 @@ import (
 // Helper: extract SDO command specifier (bits 7..5)
 func sdoCmd(f canbus.Frame) byte { return (f.Data[0] >> 5) & 0x7 }
 
-// Helper: server->client filter for a specific node, then delegate to match.
-func sdoServerFilterForNode(node NodeID, match func(canbus.Frame) bool) canbus.FrameFilter {
-    return func(f canbus.Frame) bool {
-        fc, n, err := ParseCOBID(f.ID)
-        if err != nil || fc != FC_SDO_TX || n != node || f.Len != 8 {
-            return false
-        }
-        return match(f)
-    }
-}
-
 // Build initiate segmented download frame (size indicated, e=0, s=1).
 func buildSDODownloadInitiateSegmented(node NodeID, index uint16, subindex uint8, total uint32) canbus.Frame {
     var f canbus.Frame
@@ -52,65 +42,87 @@ func buildSDODownloadSegment(node NodeID, payload []byte, toggle byte, last bool
     return f
 }
 
-// Match helpers for filters
-func sdoMatchAbortFor(index uint16, subindex uint8) func(canbus.Frame) bool {
-    return func(f canbus.Frame) bool {
-        if sdoCmd(f) != sdoSCSAbort { return false }
-        idx := binary.LittleEndian.Uint16(f.Data[1:3])
-        sub := f.Data[3]
-        return idx == index && sub == subindex
+// Wait helper with timeout semantics used by SDOClient (timeout==0 => wait forever).
+// Returns ErrSDOTimeout when the timeout expires and canbus.ErrClosed when the
+// channel is closed (mux or subscription closed). If ctx is done first, it
+// returns errSDOCanceled.
+func waitWithTimeout(ctx context.Context, ch <-chan canbus.Frame, timeout time.Duration) (canbus.Frame, error) {
+    var expired <-chan time.Time
+    if timeout > 0 {
+        t := time.NewTimer(timeout)
+        defer t.Stop()
+        expired = t.C
     }
-}
-
-func sdoMatchDownloadInitiateOK(index uint16, subindex uint8) func(canbus.Frame) bool {
-    return func(f canbus.Frame) bool {
-        if sdoCmd(f) != sdoSCSDownloadInitiate { return false }
-        idx := binary.LittleEndian.Uint16(f.Data[1:3])
-        sub := f.Data[3]
-        return idx == index && sub == subindex
+    select {
+    case f, ok := <-ch:
+        if !ok { return canbus.Frame{}, canbus.ErrClosed }
+        return f, nil
+    case <-expired:
+        return canbus.Frame{}, ErrSDOTimeout
+    case <-ctx.Done():
+        return canbus.Frame{}, errSDOCanceled
     }
 }
 
-func sdoMatchDownloadSegAck(toggle byte) func(canbus.Frame) bool {
-    return func(f canbus.Frame) bool {
-        if sdoCmd(f) != sdoSCSDownloadSegment { return false }
-        t := (f.Data[0] >> 4) & 0x1
-        return t == (toggle & 0x1)
-    }
-}
+// ErrSDOTimeout is returned when a server does not respond within the
+// client's timeout. It is distinct from canbus.ErrClosed, which means the
+// mux or bus was closed and retrying is pointless.
+var ErrSDOTimeout = errors.New("canopen: sdo response timeout")
 
-func sdoMatchUploadInitiate() func(canbus.Frame) bool {
-    return func(f canbus.Frame) bool {
-        return sdoCmd(f) == sdoSCSUploadInitiate
-    }
-}
+// errSDOCanceled signals that the caller's context ended a transfer.
+var errSDOCanceled = errors.New("canopen: sdo transfer canceled")
+
+// ErrSDOToggle is returned when a server's segment response does not carry
+// the expected toggle bit. The client aborts the transfer with 0x05030000.
+var ErrSDOToggle = errors.New("canopen: sdo toggle bit not alternated")
+
+// sdoAbortToggle is the abort code sent when a server fails to alternate.
+const sdoAbortToggle uint32 = 0x05030000
 
-func sdoMatchUploadSeg(toggle byte) func(canbus.Frame) bool {
-    return func(f canbus.Frame) bool {
-        if sdoCmd(f) != sdoSCSUploadSegment { return false }
-        t := (f.Data[0] >> 4) & 0x1
-        return t == (toggle & 0x1)
+// sdoAbortCanceled is the abort code sent when a transfer is canceled.
+const sdoAbortCanceled uint32 = 0x05040000
+
+// wait waits for the response to the request described by sent and, if ctx
+// ends the transfer, sends an SDO abort for index/subindex to the server and
+// returns context.Cause(ctx).
+func (c *SDOClient) wait(ctx context.Context, ch <-chan canbus.Frame, sent sdoSent, index uint16, subindex uint8) (canbus.Frame, error) {
+    f, err := waitWithTimeout(ctx, ch, c.timeout)
+    if err == nil && c.onRTT != nil {
+        c.onRTT(SDORoundTrip{
+            Node:     c.node,
+            Index:    index,
+            Subindex: subindex,
+            Segment:  sent.cmd == sdoCCSDownloadSegment || sent.cmd == sdoCCSUploadSegment,
+            RTT:      time.Since(sent.at),
+        })
+    }
+    if err != errSDOCanceled {
+        return f, err
+    }
+    cause := context.Cause(ctx)
+    code := sdoAbortCanceled
+    var ab SDOAbort
+    if errors.As(cause, &ab) {
+        code = ab.Code
     }
+    _, _ = c.send(buildSDOAbort(c.node, index, subindex, code))
+    return canbus.Frame{}, cause
 }
 
-// Wait helper with timeout semantics used by SDOClient (timeout==0 => wait forever).
-// Returns canbus.ErrClosed on timeout or closed channel to match existing behavior.
-func waitWithTimeout(ch <-chan canbus.Frame, timeout time.Duration) (canbus.Frame, error) {
-    if timeout > 0 {
-        select {
-        case f, ok := <-ch:
-            if !ok { return canbus.Frame{}, canbus.ErrClosed }
-            return f, nil
-        case <-time.After(timeout):
-            return canbus.Frame{}, canbus.ErrClosed
-        }
-    }
-    f, ok := <-ch
-    if !ok { return canbus.Frame{}, canbus.ErrClosed }
-    return f, nil
+// Build a client->server abort frame.
+func buildSDOAbort(node NodeID, index uint16, subindex uint8, code uint32) canbus.Frame {
+    var f canbus.Frame
+    f.ID = COBID(FC_SDO_RX, node)
+    f.Len = 8
+    f.Data[0] = byte(sdoCCSAbort << 5)
+    binary.LittleEndian.PutUint16(f.Data[1:3], index)
+    f.Data[3] = subindex
+    binary.LittleEndian.PutUint32(f.Data[4:8], code)
+    return f
 }
 
-// Parse upload segment response into data bytes and last flag.
+// Parse upload segment response into data bytes and last flag. A final
+// segment with n=7 carries no data and yields an empty slice.
 func parseSDOUploadSegmentData(f canbus.Frame) (data []byte, last bool, err error) {
     // Extract data and flags per CiA 301
     cFlag := (f.Data[0] & 0x1) != 0
@@ -118,7 +130,7 @@ func parseSDOUploadSegmentData(f canbus.Frame) (data []byte, last bool, err erro
     end := 8
     if cFlag { end = 8 - n }
     if end < 1 || end > 8 {
-        return nil, false, fmt.Errorf("canopen: invalid segment length")
+        return nil, false, frameErrorf(ErrInvalidLength, "canopen: invalid segment length")
     }
     return f.Data[1:end], cFlag, nil
 }
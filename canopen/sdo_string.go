@@ -0,0 +1,126 @@
+package canopen
+
+import (
+    "encoding/binary"
+    "fmt"
+    "strings"
+
+    "github.com/notnil/canbus"
+)
+
+// SDOString renders an SDO frame as a human-readable description of its
+// command byte and payload, e.g.
+//
+//	download initiate expedited s=1 n=2 idx=2000:01 data=AABB
+//	upload segment toggle=1 last n=3 data=010203
+//
+// Command bytes are decoded per the CiA 301 bit layout (n in bits 3..2,
+// e in bit 1, s in bit 0 for initiate). Block transfer sub-commands are
+// named; block data segments cannot be told apart from other frames and are
+// rendered by their command byte. Non-SDO frames fall back to f.String().
+func SDOString(f canbus.Frame) string {
+    fc, node, err := ParseFrameCOBID(f)
+    if err != nil || (fc != FC_SDO_RX && fc != FC_SDO_TX) || f.Len != 8 || f.RTR {
+        return f.String()
+    }
+    dir := "rx"
+    if fc == FC_SDO_TX {
+        dir = "tx"
+    }
+    return fmt.Sprintf("sdo %s node=0x%02X %s", dir, uint8(node), sdoDescribe(fc == FC_SDO_RX, f.Data))
+}
+
+func sdoDescribe(client bool, d [8]byte) string {
+    cmd := d[0] >> 5
+    mux := fmt.Sprintf("idx=%04X:%02X", binary.LittleEndian.Uint16(d[1:3]), d[3])
+    switch {
+    case cmd == sdoCCSAbort: // same value in both directions
+        ab := SDOAbort{Index: binary.LittleEndian.Uint16(d[1:3]), Subindex: d[3], Code: binary.LittleEndian.Uint32(d[4:8])}
+        desc := fmt.Sprintf("abort %s code=0x%08X", mux, ab.Code)
+        if msg, ok := sdoAbortText[ab.Code]; ok {
+            desc += " (" + msg + ")"
+        }
+        return desc
+    case client && cmd == sdoCCSDownloadInitiate:
+        return "download initiate " + sdoDescribeInitiate(d, mux)
+    case !client && cmd == sdoSCSUploadInitiate:
+        return "upload initiate response " + sdoDescribeInitiate(d, mux)
+    case client && cmd == sdoCCSUploadInitiate:
+        return "upload initiate " + mux
+    case !client && cmd == sdoSCSDownloadInitiate:
+        return "download initiate response " + mux
+    case client && cmd == sdoCCSDownloadSegment:
+        return "download segment " + sdoDescribeSegment(d)
+    case !client && cmd == sdoSCSUploadSegment:
+        return "upload segment " + sdoDescribeSegment(d)
+    case client && cmd == sdoCCSUploadSegment:
+        return fmt.Sprintf("upload segment request toggle=%d", d[0]>>4&1)
+    case !client && cmd == sdoSCSDownloadSegment:
+        return fmt.Sprintf("download segment response toggle=%d", d[0]>>4&1)
+    case client && cmd == 6:
+        if d[0]&1 == 0 {
+            return fmt.Sprintf("block download initiate crc=%d s=%d %s size=%d", d[0]>>2&1, d[0]>>1&1, mux, binary.LittleEndian.Uint32(d[4:8]))
+        }
+        return fmt.Sprintf("block download end n=%d crc=%04X", d[0]>>2&7, binary.LittleEndian.Uint16(d[1:3]))
+    case !client && cmd == 5:
+        switch d[0] & 3 {
+        case 0:
+            return fmt.Sprintf("block download initiate response crc=%d %s blksize=%d", d[0]>>2&1, mux, d[4])
+        case 1:
+            return "block download end response"
+        default:
+            return fmt.Sprintf("block download ack seqno=%d blksize=%d", d[1], d[2])
+        }
+    case client && cmd == 5:
+        switch d[0] & 3 {
+        case 0:
+            return fmt.Sprintf("block upload initiate crc=%d %s blksize=%d pst=%d", d[0]>>2&1, mux, d[4], d[5])
+        case 1:
+            return "block upload end"
+        case 2:
+            return fmt.Sprintf("block upload ack seqno=%d blksize=%d", d[1], d[2])
+        default:
+            return "block upload start"
+        }
+    case !client && cmd == 6:
+        if d[0]&1 == 0 {
+            return fmt.Sprintf("block upload initiate response crc=%d s=%d %s size=%d", d[0]>>2&1, d[0]>>1&1, mux, binary.LittleEndian.Uint32(d[4:8]))
+        }
+        return fmt.Sprintf("block upload end n=%d crc=%04X", d[0]>>2&7, binary.LittleEndian.Uint16(d[1:3]))
+    default:
+        return fmt.Sprintf("unknown command 0x%02X", d[0])
+    }
+}
+
+// sdoDescribeInitiate renders the e/s/n flags of an initiate frame.
+func sdoDescribeInitiate(d [8]byte, mux string) string {
+    n := int(d[0] >> 2 & 3)
+    e := d[0] >> 1 & 1
+    s := d[0] & 1
+    switch {
+    case e == 1 && s == 1:
+        return fmt.Sprintf("expedited s=1 n=%d %s data=%s", n, mux, sdoHex(d[4:8-n]))
+    case e == 1:
+        return fmt.Sprintf("expedited s=0 %s data=%s", mux, sdoHex(d[4:8]))
+    case s == 1:
+        return fmt.Sprintf("segmented s=1 %s size=%d", mux, binary.LittleEndian.Uint32(d[4:8]))
+    default:
+        return fmt.Sprintf("segmented s=0 %s", mux)
+    }
+}
+
+// sdoDescribeSegment renders the toggle, n and c fields of a segment.
+func sdoDescribeSegment(d [8]byte) string {
+    n := int(d[0] >> 1 & 7)
+    var b strings.Builder
+    fmt.Fprintf(&b, "toggle=%d", d[0]>>4&1)
+    if d[0]&1 == 1 {
+        b.WriteString(" last")
+    }
+    fmt.Fprintf(&b, " n=%d data=%s", n, sdoHex(d[1:8-n]))
+    return b.String()
+}
+
+func sdoHex(b []byte) string {
+    return fmt.Sprintf("%X", b)
+}
@@ -0,0 +1,76 @@
+package canopen
+
+import (
+    "fmt"
+
+    "github.com/notnil/canbus"
+)
+
+// SRDO represents a safety-relevant data object (CiA 304).
+//
+// Each SRDO is transmitted as two frames: the normal data and a bit-wise
+// inverted copy. Default COB-IDs for SRDO n (1..64) are:
+//   normal:   0x0FF + 2n  (0x101, 0x103, ..., 0x17F)
+//   inverted: 0x100 + 2n  (0x102, 0x104, ..., 0x180)
+//
+// Only encode/decode and inversion checking are provided; the SRDO refresh
+// time (SCT) and the inter-frame validation time (SRVT) are out of scope and
+// must be enforced by the application.
+type SRDO struct {
+    Number uint8  // 1..64
+    Data   []byte // 0..8 bytes
+}
+
+// SRDOCOBIDs returns the default normal and inverted COB-IDs for SRDO n.
+func SRDOCOBIDs(n uint8) (normal, inverted uint32, err error) {
+    if n < 1 || n > 64 {
+        return 0, 0, fmt.Errorf("canopen: invalid SRDO number %d (valid 1..64)", n)
+    }
+    return 0x0FF + 2*uint32(n), 0x100 + 2*uint32(n), nil
+}
+
+// MarshalCANFrames encodes the SRDO to its normal and inverted frames.
+func (s SRDO) MarshalCANFrames() (normal, inverted canbus.Frame, err error) {
+    nID, iID, err := SRDOCOBIDs(s.Number)
+    if err != nil {
+        return canbus.Frame{}, canbus.Frame{}, err
+    }
+    if len(s.Data) > 8 {
+        return canbus.Frame{}, canbus.Frame{}, fmt.Errorf("canopen: SRDO data too long: %d", len(s.Data))
+    }
+    normal.ID = nID
+    normal.Len = uint8(len(s.Data))
+    inverted.ID = iID
+    inverted.Len = uint8(len(s.Data))
+    for i, b := range s.Data {
+        normal.Data[i] = b
+        inverted.Data[i] = ^b
+    }
+    return normal, inverted, nil
+}
+
+// UnmarshalCANFrames decodes the SRDO from a normal/inverted frame pair. It
+// rejects pairs whose COB-IDs do not belong to the same SRDO, whose lengths
+// differ, or whose data is not the exact bit-wise inverse.
+func (s *SRDO) UnmarshalCANFrames(normal, inverted canbus.Frame) error {
+    if normal.Extended || inverted.Extended || normal.ID < 0x101 || normal.ID > 0x17F || normal.ID%2 == 0 {
+        return fmt.Errorf("canopen: not an SRDO normal frame (id=0x%X)", normal.ID)
+    }
+    if inverted.ID != normal.ID+1 {
+        return fmt.Errorf("canopen: SRDO inverted id 0x%X does not pair with 0x%X", inverted.ID, normal.ID)
+    }
+    if normal.Len != inverted.Len {
+        return fmt.Errorf("canopen: SRDO length mismatch: %d vs %d", normal.Len, inverted.Len)
+    }
+    if int(normal.Len) > len(normal.Data) {
+        return fmt.Errorf("canopen: SRDO length %d invalid", normal.Len)
+    }
+    for i := 0; i < int(normal.Len); i++ {
+        if normal.Data[i] != ^inverted.Data[i] {
+            return fmt.Errorf("canopen: SRDO inverted data mismatch at byte %d", i)
+        }
+    }
+    s.Number = uint8((normal.ID - 0x0FF) / 2)
+    s.Data = append([]byte(nil), normal.Data[:normal.Len]...)
+    return nil
+}
@@ -2,6 +2,7 @@ package canopen
 
 import (
     "fmt"
+    "sync"
     "time"
 
     "github.com/notnil/canbus"
@@ -27,12 +28,12 @@ func (s SYNC) MarshalCANFrame() (canbus.Frame, error) {
 
 // UnmarshalCANFrame decodes the SYNC from a CAN frame.
 func (s *SYNC) UnmarshalCANFrame(f canbus.Frame) error {
-    fc, _, err := ParseCOBID(f.ID)
+    fc, _, err := ParseFrameCOBID(f)
     if err != nil {
         return err
     }
     if fc != FC_SYNC {
-        return fmt.Errorf("canopen: not a SYNC frame (id=0x%X)", f.ID)
+        return wrongFrameErrorf(ErrNotSYNC, "canopen: not a SYNC frame (id=0x%X)", f.ID)
     }
     switch f.Len {
     case 0:
@@ -41,25 +42,38 @@ func (s *SYNC) UnmarshalCANFrame(f canbus.Frame) error {
         v := f.Data[0]
         s.Counter = &v
     default:
-        return fmt.Errorf("canopen: SYNC length %d invalid", f.Len)
+        return frameErrorf(ErrInvalidLength, "canopen: SYNC length %d invalid", f.Len)
     }
     return nil
 }
 
+// ParseSYNC decodes a SYNC frame.
+func ParseSYNC(f canbus.Frame) (SYNC, error) {
+    var s SYNC
+    err := s.UnmarshalCANFrame(f)
+    return s, err
+}
+
 // SYNCWriter periodically transmits SYNC frames on the provided bus.
 // If WithCounter is true, a counter byte (0..127 then wrap) is included.
+// SYNCWriter implements PeriodicProducer so it can also be driven by a
+// Scheduler instead of Start/Stop; do not use both at once.
 type SYNCWriter struct {
     bus        canbus.Bus
     interval   time.Duration
     withCounter bool
 
-    stop chan struct{}
+    mu      sync.Mutex
+    counter uint8
+
+    stop  chan struct{}
+    reset chan time.Duration
 }
 
 // NewSYNCWriter creates a SYNC writer that sends at the given interval.
 // If withCounter is true, a modulo-128 counter byte is added per CiA 301.
 func NewSYNCWriter(bus canbus.Bus, interval time.Duration, withCounter bool) *SYNCWriter {
-    return &SYNCWriter{bus: bus, interval: interval, withCounter: withCounter, stop: make(chan struct{})}
+    return &SYNCWriter{bus: bus, interval: interval, withCounter: withCounter, stop: make(chan struct{}), reset: make(chan time.Duration, 1)}
 }
 
 // Start launches the background goroutine. Calling Start multiple times has no additional effect.
@@ -67,7 +81,7 @@ func (w *SYNCWriter) Start() {
     if w.stop == nil {
         w.stop = make(chan struct{})
     }
-    go w.run()
+    go runPeriodic(w.bus, w, w.stop, w.reset)
 }
 
 // Stop signals the writer to stop and waits for termination.
@@ -83,27 +97,45 @@ func (w *SYNCWriter) Stop() {
     close(w.stop)
 }
 
-func (w *SYNCWriter) run() {
-    ticker := time.NewTicker(w.interval)
-    defer ticker.Stop()
-    var counter uint8 = 0
-    for {
-        select {
-        case <-w.stop:
-            return
-        case <-ticker.C:
-            var frame canbus.Frame
-            frame.ID = COBID(FC_SYNC, 0)
-            if w.withCounter {
-                frame.Len = 1
-                frame.Data[0] = counter & 0x7F
-                counter = (counter + 1) & 0x7F
-            } else {
-                frame.Len = 0
-            }
-            _ = w.bus.Send(frame)
-        }
-    }
+// Period returns the SYNC interval.
+func (w *SYNCWriter) Period() time.Duration {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.interval
 }
 
+// SetInterval changes the SYNC interval. A running writer switches to the
+// new cadence without stopping: the next SYNC follows d after the change.
+// A Scheduler driving the writer picks it up after the next SYNC. d must be
+// positive.
+func (w *SYNCWriter) SetInterval(d time.Duration) error {
+    if d <= 0 {
+        return fmt.Errorf("canopen: SYNC interval %v must be positive", d)
+    }
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.interval = d
+    // Replace any pending, not yet applied interval with the latest one.
+    select {
+    case <-w.reset:
+    default:
+    }
+    w.reset <- d
+    return nil
+}
 
+// Frame returns the next SYNC frame, advancing the counter if enabled.
+func (w *SYNCWriter) Frame() (canbus.Frame, bool) {
+    var frame canbus.Frame
+    frame.ID = COBID(FC_SYNC, 0)
+    if w.withCounter {
+        w.mu.Lock()
+        frame.Len = 1
+        frame.Data[0] = w.counter & 0x7F
+        w.counter = (w.counter + 1) & 0x7F
+        w.mu.Unlock()
+    } else {
+        frame.Len = 0
+    }
+    return frame, true
+}
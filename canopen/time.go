@@ -0,0 +1,198 @@
+package canopen
+
+import (
+    "encoding/binary"
+    "fmt"
+    "time"
+
+    "github.com/notnil/canbus"
+)
+
+// TIME represents a CANopen TIME message carrying a TIME_OF_DAY value:
+// milliseconds after midnight and days since January 1, 1984.
+// Layout (6 bytes, little-endian):
+//   0..3: milliseconds (lower 28 bits, upper 4 bits reserved)
+//   4..5: days
+type TIME struct {
+    Milliseconds uint32
+    Days         uint16
+}
+
+// MarshalCANFrame encodes the TIME to a CAN frame.
+func (t TIME) MarshalCANFrame() (canbus.Frame, error) {
+    if t.Milliseconds > 0x0FFFFFFF {
+        return canbus.Frame{}, fmt.Errorf("canopen: TIME milliseconds out of range: %d", t.Milliseconds)
+    }
+    var f canbus.Frame
+    f.ID = COBID(FC_TIME, 0)
+    f.Len = 6
+    b := t.bytes()
+    copy(f.Data[:6], b[:])
+    return f, nil
+}
+
+// UnmarshalCANFrame decodes the TIME from a CAN frame.
+func (t *TIME) UnmarshalCANFrame(f canbus.Frame) error {
+    if f.ID != COBID(FC_TIME, 0) {
+        return wrongFrameErrorf(ErrNotTIME, "canopen: not a TIME frame (id=0x%X)", f.ID)
+    }
+    if f.Len < 6 {
+        return frameErrorf(ErrFrameTooShort, "canopen: TIME frame too short: %d", f.Len)
+    }
+    var b [6]byte
+    copy(b[:], f.Data[:6])
+    *t = timeFromBytes(b)
+    return nil
+}
+
+// ParseTIME decodes a TIME frame.
+func ParseTIME(f canbus.Frame) (TIME, error) {
+    var t TIME
+    err := t.UnmarshalCANFrame(f)
+    return t, err
+}
+
+// timeEpoch is the CANopen TIME_OF_DAY epoch.
+var timeEpoch = time.Date(1984, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// msPerDay is the number of milliseconds in a TIME_OF_DAY day.
+const msPerDay = 24 * 60 * 60 * 1000
+
+// TIMEFromTime converts t to a TIME_OF_DAY value (UTC). Times before the
+// 1984 epoch are not representable and yield day 0; times past day 65535
+// (in 2163) yield the last millisecond of day 65535.
+func TIMEFromTime(t time.Time) TIME {
+    t = t.UTC()
+    if t.Before(timeEpoch) {
+        t = timeEpoch
+    }
+    return timeFromDuration(t.Sub(timeEpoch))
+}
+
+// Time converts the TIME_OF_DAY value to a UTC time.Time.
+func (t TIME) Time() time.Time {
+    return timeEpoch.Add(t.duration())
+}
+
+// Validate reports whether Milliseconds lies within a day.
+func (t TIME) Validate() error {
+    if t.Milliseconds >= msPerDay {
+        return fmt.Errorf("canopen: TIME milliseconds %d exceed one day", t.Milliseconds)
+    }
+    return nil
+}
+
+// timeFromDuration splits a non-negative duration into days and
+// milliseconds, saturating at the largest representable value.
+func timeFromDuration(d time.Duration) TIME {
+    if d < 0 {
+        return TIME{}
+    }
+    ms := int64(d / time.Millisecond)
+    days := ms / msPerDay
+    if days > 0xFFFF {
+        return TIME{Milliseconds: msPerDay - 1, Days: 0xFFFF}
+    }
+    return TIME{Milliseconds: uint32(ms % msPerDay), Days: uint16(days)}
+}
+
+func (t TIME) duration() time.Duration {
+    return time.Duration(t.Days)*24*time.Hour + time.Duration(t.Milliseconds)*time.Millisecond
+}
+
+// bytes encodes the shared 6-byte TIME_OF_DAY/TIME_DIFFERENCE layout.
+func (t TIME) bytes() [6]byte {
+    var b [6]byte
+    binary.LittleEndian.PutUint32(b[0:4], t.Milliseconds&0x0FFFFFFF)
+    binary.LittleEndian.PutUint16(b[4:6], t.Days)
+    return b
+}
+
+// timeFromBytes decodes the 6-byte layout, ignoring the reserved bits.
+func timeFromBytes(b [6]byte) TIME {
+    return TIME{
+        Milliseconds: binary.LittleEndian.Uint32(b[0:4]) & 0x0FFFFFFF,
+        Days:         binary.LittleEndian.Uint16(b[4:6]),
+    }
+}
+
+// EncodeTimeOfDay encodes t (in UTC) as a CiA 301 TIME_OF_DAY value, the
+// layout used by the TIME message and by objects of that data type. Times
+// outside 1984-01-01 through day 65535 are clamped as in TIMEFromTime.
+func EncodeTimeOfDay(t time.Time) [6]byte {
+    return TIMEFromTime(t).bytes()
+}
+
+// DecodeTimeOfDay decodes a TIME_OF_DAY value to a UTC time. Millisecond
+// counts beyond one day roll over into the following days; use
+// TIME.Validate to reject them.
+func DecodeTimeOfDay(b [6]byte) time.Time {
+    return timeFromBytes(b).Time()
+}
+
+// EncodeTimeDifference encodes d as a CiA 301 TIME_DIFFERENCE value (days
+// and milliseconds). Negative durations encode as zero and durations beyond
+// 65535 days saturate.
+func EncodeTimeDifference(d time.Duration) [6]byte {
+    return timeFromDuration(d).bytes()
+}
+
+// DecodeTimeDifference decodes a TIME_DIFFERENCE value to a duration.
+func DecodeTimeDifference(b [6]byte) time.Duration {
+    return timeFromBytes(b).duration()
+}
+
+// TIMEWriter periodically transmits TIME frames carrying the current time.
+// It implements PeriodicProducer so it can be driven by a Scheduler instead
+// of Start/Stop; do not use both at once.
+type TIMEWriter struct {
+    bus      canbus.Bus
+    interval time.Duration
+    now      func() time.Time
+
+    stop chan struct{}
+}
+
+// NewTIMEWriter creates a TIME writer that sends at the given interval. If
+// now is nil, time.Now is used as the clock.
+func NewTIMEWriter(bus canbus.Bus, interval time.Duration, now func() time.Time) *TIMEWriter {
+    if now == nil {
+        now = time.Now
+    }
+    return &TIMEWriter{bus: bus, interval: interval, now: now}
+}
+
+// Period returns the TIME interval.
+func (w *TIMEWriter) Period() time.Duration {
+    return w.interval
+}
+
+// Frame returns a TIME frame for the current clock value.
+func (w *TIMEWriter) Frame() (canbus.Frame, bool) {
+    f, err := TIMEFromTime(w.now()).MarshalCANFrame()
+    if err != nil {
+        return canbus.Frame{}, false
+    }
+    return f, true
+}
+
+// Start launches the background goroutine.
+func (w *TIMEWriter) Start() {
+    if w.stop == nil {
+        w.stop = make(chan struct{})
+    }
+    go runPeriodic(w.bus, w, w.stop, nil)
+}
+
+// Stop signals the writer to stop.
+func (w *TIMEWriter) Stop() {
+    if w.stop == nil {
+        return
+    }
+    select {
+    case <-w.stop:
+        return
+    default:
+    }
+    close(w.stop)
+}
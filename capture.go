@@ -0,0 +1,43 @@
+package canbus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ScanFrames returns an iterator over a raw capture of concatenated
+// can_frame records (the 16-byte MarshalBinary layout), such as bytes read
+// from a SocketCAN socket and written to a file.
+//
+// Each call to next reads one record and returns the decoded frame. At a
+// clean end of input next returns io.EOF; trailing bytes shorter than a
+// record yield an error wrapping io.ErrUnexpectedEOF. Decode errors are
+// returned as from UnmarshalBinary. Once next returns an error, every later
+// call returns the same error.
+//
+// Only classical records are supported: a stream of 72-byte canfd_frame
+// records cannot be told apart from classical ones by size alone and is not
+// decoded.
+func ScanFrames(r io.Reader) (next func() (Frame, error)) {
+	var buf [canMTU]byte
+	var err error
+	return func() (Frame, error) {
+		if err != nil {
+			return Frame{}, err
+		}
+		var n int
+		n, err = io.ReadFull(r, buf[:])
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = fmt.Errorf("canbus: truncated frame record: %d of %d bytes: %w", n, canMTU, err)
+			}
+			return Frame{}, err
+		}
+		var f Frame
+		if err = f.UnmarshalBinary(buf[:]); err != nil {
+			return Frame{}, err
+		}
+		return f, nil
+	}
+}
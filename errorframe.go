@@ -0,0 +1,75 @@
+package canbus
+
+import "errors"
+
+// canErrFlag marks an error frame in the SocketCAN can_id (CAN_ERR_FLAG).
+const canErrFlag = 0x20000000
+
+// ErrorClass is the bit set carried in the identifier of an error frame
+// (see linux/can/error.h). A single frame may report several classes.
+type ErrorClass uint32
+
+// Error classes.
+const (
+	ErrorClassTxTimeout   ErrorClass = 0x001 // TX timeout (by netdevice driver)
+	ErrorClassLostArb     ErrorClass = 0x002 // lost arbitration, see ErrorFrame.ArbitrationBit
+	ErrorClassController  ErrorClass = 0x004 // controller problems, see ErrorFrame.Controller
+	ErrorClassProtocol    ErrorClass = 0x008 // protocol violations, see ErrorFrame.Protocol*
+	ErrorClassTransceiver ErrorClass = 0x010 // transceiver status, see ErrorFrame.Transceiver
+	ErrorClassNoAck       ErrorClass = 0x020 // received no ACK on transmission
+	ErrorClassBusOff      ErrorClass = 0x040 // bus off
+	ErrorClassBusError    ErrorClass = 0x080 // bus error (may flood)
+	ErrorClassRestarted   ErrorClass = 0x100 // controller restarted
+	ErrorClassCounters    ErrorClass = 0x200 // TX/RX error counters are valid
+
+	// ErrorClassAll selects every error class (CAN_ERR_MASK).
+	ErrorClassAll ErrorClass = 0x1FFFFFFF
+)
+
+// Has reports whether all classes in x are set in c.
+func (c ErrorClass) Has(x ErrorClass) bool { return c&x == x }
+
+// Controller status bits reported with ErrorClassController.
+const (
+	ControllerRxOverflow = 0x01
+	ControllerTxOverflow = 0x02
+	ControllerRxWarning  = 0x04
+	ControllerTxWarning  = 0x08
+	ControllerRxPassive  = 0x10
+	ControllerTxPassive  = 0x20
+	ControllerActive     = 0x40
+)
+
+// ErrorFrame is the decoded form of a SocketCAN error frame. Fields other
+// than Class are only meaningful when the corresponding class bit is set.
+type ErrorFrame struct {
+	Class            ErrorClass
+	ArbitrationBit   uint8 // bit number where arbitration was lost (0 = unspecified)
+	Controller       uint8 // Controller* status bits
+	ProtocolType     uint8 // protocol violation type (CAN_ERR_PROT_*)
+	ProtocolLocation uint8 // protocol violation location (CAN_ERR_PROT_LOC_*)
+	Transceiver      uint8 // transceiver status (CAN_ERR_TRX_*)
+	TxErrors         uint8 // TX error counter
+	RxErrors         uint8 // RX error counter
+}
+
+// ErrNotErrorFrame is returned by DecodeError for frames without the error flag.
+var ErrNotErrorFrame = errors.New("canbus: not an error frame")
+
+// DecodeError decodes an error frame received from SocketCAN. The class
+// bits come from ID and the details from the eight data bytes.
+func (f Frame) DecodeError() (ErrorFrame, error) {
+	if !f.Error {
+		return ErrorFrame{}, ErrNotErrorFrame
+	}
+	return ErrorFrame{
+		Class:            ErrorClass(f.ID),
+		ArbitrationBit:   f.Data[0],
+		Controller:       f.Data[1],
+		ProtocolType:     f.Data[2],
+		ProtocolLocation: f.Data[3],
+		Transceiver:      f.Data[4],
+		TxErrors:         f.Data[6],
+		RxErrors:         f.Data[7],
+	}, nil
+}
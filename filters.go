@@ -1,10 +1,27 @@
 package canbus
 
 // Typed and composable helpers for FrameFilter.
+//
+// The identifier filters (ByID, ByStandardID, ByExtendedID, ByIDs, ByRange,
+// ByMask, StandardOnly and ExtendedOnly) never match error frames: their ID
+// holds ErrorClass bits, not an identifier. Select error frames by testing
+// Frame.Error.
 
 // ByID returns a filter that matches frames with the exact identifier.
 func ByID(id uint32) FrameFilter {
-    return func(f Frame) bool { return f.ID == id }
+    return func(f Frame) bool { return !f.Error && f.ID == id }
+}
+
+// ByStandardID matches standard (11-bit) frames with the exact identifier,
+// so an extended frame with the same numeric ID does not match.
+func ByStandardID(id uint32) FrameFilter {
+    return func(f Frame) bool { return !f.Error && !f.Extended && f.ID == id }
+}
+
+// ByExtendedID matches extended (29-bit) frames with the exact identifier,
+// so a standard frame with the same numeric ID does not match.
+func ByExtendedID(id uint32) FrameFilter {
+    return func(f Frame) bool { return !f.Error && f.Extended && f.ID == id }
 }
 
 // ByIDs returns a filter that matches any of the provided identifiers.
@@ -16,7 +33,7 @@ func ByIDs(ids ...uint32) FrameFilter {
     }
     return func(f Frame) bool {
         _, ok := m[f.ID]
-        return ok
+        return ok && !f.Error
     }
 }
 
@@ -26,23 +43,23 @@ func ByRange(minID, maxID uint32) FrameFilter {
         // swap defensively
         minID, maxID = maxID, minID
     }
-    return func(f Frame) bool { return f.ID >= minID && f.ID <= maxID }
+    return func(f Frame) bool { return !f.Error && f.ID >= minID && f.ID <= maxID }
 }
 
 // ByMask matches when (frame.ID & mask) == (id & mask).
 func ByMask(id uint32, mask uint32) FrameFilter {
     want := id & mask
-    return func(f Frame) bool { return (f.ID & mask) == want }
+    return func(f Frame) bool { return !f.Error && (f.ID&mask) == want }
 }
 
-// StandardOnly matches standard (11-bit) identifiers.
+// StandardOnly matches standard (11-bit) identifiers, not error frames.
 func StandardOnly() FrameFilter {
-    return func(f Frame) bool { return !f.Extended }
+    return func(f Frame) bool { return !f.Error && !f.Extended }
 }
 
-// ExtendedOnly matches extended (29-bit) identifiers.
+// ExtendedOnly matches extended (29-bit) identifiers, not error frames.
 func ExtendedOnly() FrameFilter {
-    return func(f Frame) bool { return f.Extended }
+    return func(f Frame) bool { return !f.Error && f.Extended }
 }
 
 // DataOnly matches non-RTR frames.
@@ -65,6 +82,44 @@ func LenExactly(n uint8) FrameFilter {
     return func(f Frame) bool { return f.Len == n }
 }
 
+// ByByteAt matches data frames whose payload byte at index equals value.
+// Frames too short to have that byte, and RTR frames, do not match.
+func ByByteAt(index int, value byte) FrameFilter {
+    return func(f Frame) bool {
+        d := payload(f)
+        return index >= 0 && index < len(d) && d[index] == value
+    }
+}
+
+// ByDataPrefix matches data frames whose payload starts with prefix. Frames
+// shorter than prefix, and RTR frames, do not match; an empty prefix matches
+// every data frame.
+func ByDataPrefix(prefix []byte) FrameFilter {
+    p := append([]byte(nil), prefix...)
+    return func(f Frame) bool {
+        d := payload(f)
+        return len(d) >= len(p) && string(d[:len(p)]) == string(p)
+    }
+}
+
+// ByCommandSpecifier matches data frames whose first byte carries cs in its
+// top three bits (Data[0]>>5), the command specifier of CANopen SDO frames.
+// Frames without data do not match.
+func ByCommandSpecifier(cs byte) FrameFilter {
+    return func(f Frame) bool {
+        d := payload(f)
+        return len(d) > 0 && d[0]>>5 == cs
+    }
+}
+
+// payload returns the data bytes of f, or nil for RTR frames.
+func payload(f Frame) []byte {
+    if f.RTR || f.Len > 8 {
+        return nil
+    }
+    return f.Data[:f.Len]
+}
+
 // And composes two filters; the result matches when both match.
 func And(a, b FrameFilter) FrameFilter {
     switch {
@@ -89,6 +144,61 @@ func Or(a, b FrameFilter) FrameFilter {
     }
 }
 
+// AllOf composes any number of filters; the result matches when all of them
+// match, testing them in order and stopping at the first mismatch. Nil
+// entries are skipped as in And, and if none remain the result is nil.
+func AllOf(filters ...FrameFilter) FrameFilter {
+    fs := nonNilFilters(filters)
+    switch len(fs) {
+    case 0:
+        return nil
+    case 1:
+        return fs[0]
+    }
+    return func(f Frame) bool {
+        for _, flt := range fs {
+            if !flt(f) {
+                return false
+            }
+        }
+        return true
+    }
+}
+
+// AnyOf composes any number of filters; the result matches when at least
+// one of them matches, testing them in order and stopping at the first
+// match. Nil entries are skipped as in Or, and if none remain the result is
+// nil.
+func AnyOf(filters ...FrameFilter) FrameFilter {
+    fs := nonNilFilters(filters)
+    switch len(fs) {
+    case 0:
+        return nil
+    case 1:
+        return fs[0]
+    }
+    return func(f Frame) bool {
+        for _, flt := range fs {
+            if flt(f) {
+                return true
+            }
+        }
+        return false
+    }
+}
+
+// nonNilFilters returns a copy of filters without nil entries, so later
+// changes to the caller's slice do not affect the composed filter.
+func nonNilFilters(filters []FrameFilter) []FrameFilter {
+    out := make([]FrameFilter, 0, len(filters))
+    for _, f := range filters {
+        if f != nil {
+            out = append(out, f)
+        }
+    }
+    return out
+}
+
 // Not inverts a filter.
 func Not(a FrameFilter) FrameFilter {
     if a == nil {
@@ -97,4 +207,21 @@ func Not(a FrameFilter) FrameFilter {
     return func(f Frame) bool { return !a(f) }
 }
 
+// CANFilter is an identifier filter in the form used by the kernel's
+// CAN_RAW_FILTER socket option (see SocketCANOptions.Filters). A frame
+// matches when (frame.ID & Mask) == (ID & Mask) and its Extended flag equals
+// Extended; Inverted negates the result.
+type CANFilter struct {
+    ID       uint32
+    Mask     uint32
+    Extended bool
+    Inverted bool
+}
 
+// Match reports whether f passes the filter, mirroring the kernel's
+// semantics so the same list can be checked in userspace. The method value
+// c.Match is a FrameFilter.
+func (c CANFilter) Match(f Frame) bool {
+    m := f.Extended == c.Extended && f.ID&c.Mask == c.ID&c.Mask
+    return m != c.Inverted
+}
@@ -1,10 +1,13 @@
 package canbus
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Frame represents a classical CAN (2.0A/2.0B) frame.
@@ -13,6 +16,7 @@ import (
 //   - Standard (11-bit) and Extended (29-bit) identifiers
 //   - Data frames and Remote Transmission Request (RTR)
 //   - Data length 0-8 bytes (classical CAN)
+//   - Error frames as delivered by SocketCAN (see DecodeError)
 //
 // Not implemented: CAN FD specific fields.
 type Frame struct {
@@ -21,6 +25,13 @@ type Frame struct {
 	RTR      bool   // remote transmission request
 	Len      uint8  // 0..8
 	Data     [8]byte
+	// Error marks a SocketCAN error frame (CAN_ERR_FLAG). ID then holds the
+	// ErrorClass bits rather than an identifier.
+	Error bool
+	// Timestamp is the receive time reported by the driver, if any (see
+	// SocketCANOptions.Timestamping). It is not part of the frame on the
+	// wire: binary and text encodings, Equal and Hash ignore it.
+	Timestamp time.Time
 }
 
 // Validation limits.
@@ -32,6 +43,15 @@ const (
 var (
 	ErrInvalidID  = errors.New("canbus: invalid identifier")
 	ErrInvalidLen = errors.New("canbus: invalid data length")
+	// ErrFDFrame is returned when decoding a CAN FD frame whose payload does
+	// not fit a classical frame. CAN FD frames are not supported.
+	ErrFDFrame = errors.New("canbus: CAN FD frame not supported")
+)
+
+// Linux struct sizes for classical and FD frames (CAN_MTU, CANFD_MTU).
+const (
+	canMTU   = 16
+	canFDMTU = 72
 )
 
 // Validate returns an error if the frame is not valid.
@@ -39,7 +59,7 @@ func (f Frame) Validate() error {
 	if f.Len > 8 {
 		return ErrInvalidLen
 	}
-	if f.Extended {
+	if f.Extended || f.Error {
 		if f.ID > maxExtID {
 			return ErrInvalidID
 		}
@@ -51,6 +71,93 @@ func (f Frame) Validate() error {
 	return nil
 }
 
+// Normalize zeroes data bytes beyond Len, and all data bytes of RTR frames,
+// so that semantically equal frames encode identically. It leaves Timestamp
+// alone, so normalized frames compare equal with == only if their
+// timestamps match; use Equal to compare frames.
+func (f *Frame) Normalize() {
+	n := int(f.Len)
+	if n > len(f.Data) {
+		n = len(f.Data)
+	}
+	if f.RTR {
+		n = 0
+	}
+	for i := n; i < len(f.Data); i++ {
+		f.Data[i] = 0
+	}
+}
+
+// Equal reports whether f and g are the same frame: identifier, flags,
+// length and the significant data bytes. Unlike ==, bytes beyond Len and the
+// data of RTR frames are ignored.
+func (f Frame) Equal(g Frame) bool {
+	if f.ID != g.ID || f.Extended != g.Extended || f.RTR != g.RTR || f.Error != g.Error || f.Len != g.Len {
+		return false
+	}
+	if f.RTR {
+		return true
+	}
+	n := int(f.Len)
+	if n > len(f.Data) {
+		n = len(f.Data)
+	}
+	return bytes.Equal(f.Data[:n], g.Data[:n])
+}
+
+// Clone returns a normalized copy of f, Timestamp included. Frame is a
+// value type, so a plain assignment already copies; Clone additionally
+// clears padding so the copy encodes like other normalized frames. Compare
+// frames with Equal, which also ignores Timestamp.
+func (f Frame) Clone() Frame {
+	f.Normalize()
+	return f
+}
+
+// Hash returns a 64-bit FNV-1a hash of the frame's identifier, flags,
+// length and significant data bytes, i.e. of its normalized form: padding
+// beyond Len and the data of RTR frames do not contribute. The value is
+// stable across runs and platforms, so it is suitable as a compact map key
+// or for persisted dedup state. Distinct frames can still collide, though
+// rarely; compare frames when exactness matters.
+func (f Frame) Hash() uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	mix := func(b byte) {
+		h ^= uint64(b)
+		h *= prime64
+	}
+	mix(byte(f.ID))
+	mix(byte(f.ID >> 8))
+	mix(byte(f.ID >> 16))
+	mix(byte(f.ID >> 24))
+	var flags byte
+	if f.Extended {
+		flags |= 1
+	}
+	if f.RTR {
+		flags |= 2
+	}
+	if f.Error {
+		flags |= 4
+	}
+	mix(flags)
+	mix(f.Len)
+	if !f.RTR {
+		n := int(f.Len)
+		if n > len(f.Data) {
+			n = len(f.Data)
+		}
+		for _, b := range f.Data[:n] {
+			mix(b)
+		}
+	}
+	return h
+}
+
 // MustFrame constructs a Frame and panics if invalid. Convenience for examples.
 func MustFrame(id uint32, data []byte) Frame {
 	var f Frame
@@ -69,6 +176,31 @@ func MustFrame(id uint32, data []byte) Frame {
 	return f
 }
 
+// MustStandardFrame constructs a standard (11-bit) Frame and panics if id
+// does not fit in 11 bits or data is longer than 8 bytes. Unlike MustFrame it
+// never promotes to an extended identifier.
+func MustStandardFrame(id uint32, data []byte) Frame {
+	return mustFrame(id, false, data)
+}
+
+// MustExtendedFrame constructs an extended (29-bit) Frame and panics if id
+// does not fit in 29 bits or data is longer than 8 bytes.
+func MustExtendedFrame(id uint32, data []byte) Frame {
+	return mustFrame(id, true, data)
+}
+
+func mustFrame(id uint32, extended bool, data []byte) Frame {
+	if len(data) > 8 {
+		panic(ErrInvalidLen)
+	}
+	f := Frame{ID: id, Extended: extended, Len: uint8(len(data))}
+	copy(f.Data[:], data)
+	if err := f.Validate(); err != nil {
+		panic(err)
+	}
+	return f
+}
+
 // MarshalBinary encodes the frame to the Linux SocketCAN "struct can_frame" layout
 // (16 bytes) for classical CAN. This layout is widely used and suitable for
 // capture or transport. It intentionally does not include timestamping.
@@ -93,6 +225,9 @@ func (f Frame) MarshalBinary() ([]byte, error) {
 	if f.RTR {
 		id |= canRtrFlag
 	}
+	if f.Error {
+		id |= canErrFlag
+	}
 	buf := make([]byte, 16)
 	binary.LittleEndian.PutUint32(buf[0:4], id)
 	buf[4] = f.Len
@@ -101,10 +236,21 @@ func (f Frame) MarshalBinary() ([]byte, error) {
 }
 
 // UnmarshalBinary decodes a frame from the Linux SocketCAN can_frame layout.
+//
+// A 72-byte buffer is treated as the canfd_frame layout, which shares the
+// classical header. It decodes when the payload fits in 8 bytes and fails
+// with ErrFDFrame otherwise rather than silently truncating. A 16-byte
+// classical buffer claiming more than 8 data bytes fails with ErrInvalidLen.
 func (f *Frame) UnmarshalBinary(data []byte) error {
-	if len(data) < 16 {
+	if len(data) < canMTU {
 		return fmt.Errorf("canbus: need 16 bytes, got %d", len(data))
 	}
+	if data[4] > 8 {
+		if len(data) == canFDMTU {
+			return fmt.Errorf("%w: payload length %d exceeds classical 8 bytes", ErrFDFrame, data[4])
+		}
+		return fmt.Errorf("%w: classical frame claims %d data bytes", ErrInvalidLen, data[4])
+	}
 	id := binary.LittleEndian.Uint32(data[0:4])
 	const (
 		canEffFlag = 0x80000000
@@ -114,13 +260,15 @@ func (f *Frame) UnmarshalBinary(data []byte) error {
 	)
 	f.Extended = id&canEffFlag != 0
 	f.RTR = id&canRtrFlag != 0
-	if f.Extended {
+	f.Error = id&canErrFlag != 0
+	if f.Extended || f.Error {
 		f.ID = id & canEffMask
 	} else {
 		f.ID = id & canStdMask
 	}
 	f.Len = uint8(data[4])
 	copy(f.Data[:], data[8:16])
+	f.Normalize()
 	return f.Validate()
 }
 
@@ -129,9 +277,10 @@ func (f *Frame) UnmarshalBinary(data []byte) error {
 //   123 [2] DE AD
 //   1ABCDEFF [0]
 //   123 [4] RTR
+//   00000040 [8] ERR 00 00 00 00 00 00 00 00
 func (f Frame) String() string {
 	width := 3
-	if f.Extended {
+	if f.Extended || f.Error {
 		width = 8
 	}
 	var b strings.Builder
@@ -140,9 +289,12 @@ func (f Frame) String() string {
 		b.WriteString(" RTR")
 		return b.String()
 	}
+	if f.Error {
+		b.WriteString(" ERR")
+	}
 	if f.Len > 0 {
 		b.WriteByte(' ')
-		for i := 0; i < int(f.Len); i++ {
+		for i := 0; i < int(f.Len) && i < len(f.Data); i++ {
 			if i > 0 {
 				b.WriteByte(' ')
 			}
@@ -152,3 +304,130 @@ func (f Frame) String() string {
 	return b.String()
 }
 
+
+// MarshalText implements encoding.TextMarshaler using the candump/cansend
+// frame form without timestamp or interface:
+//   123#DEAD
+//   1ABCDEFF#
+//   123#R      (RTR, zero length)
+//   123#R4     (RTR with requested length)
+//   20000040#0000000000000000 (error frame, bus-off)
+// Standard identifiers use 3 hex digits and extended identifiers use 8,
+// which is how the form distinguishes the two. Error frames use 8 digits
+// with CAN_ERR_FLAG (0x20000000) ORed into the ErrorClass bits, as candump
+// writes them.
+func (f Frame) MarshalText() ([]byte, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return appendFrameText(nil, f), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for the form produced by
+// MarshalText. Data bytes may optionally be separated by '.' as accepted by
+// cansend.
+func (f *Frame) UnmarshalText(text []byte) error {
+	s := string(text)
+	hash := strings.IndexByte(s, '#')
+	if hash < 0 {
+		return fmt.Errorf("canbus: missing '#' in frame text %q", s)
+	}
+	idStr, dataStr := s[:hash], s[hash+1:]
+	var g Frame
+	switch len(idStr) {
+	case 3:
+	case 8:
+		g.Extended = true
+	default:
+		return fmt.Errorf("canbus: frame text id must be 3 or 8 hex digits, got %q", idStr)
+	}
+	id, err := strconv.ParseUint(idStr, 16, 32)
+	if err != nil {
+		return fmt.Errorf("canbus: invalid frame text id %q", idStr)
+	}
+	if g.Extended && id&canErrFlag != 0 {
+		g.Extended, g.Error = false, true
+		id &^= canErrFlag
+	}
+	g.ID = uint32(id)
+	if strings.HasPrefix(dataStr, "R") {
+		g.RTR = true
+		if rest := dataStr[1:]; rest != "" {
+			n, err := strconv.ParseUint(rest, 10, 8)
+			if err != nil || n > 8 {
+				return fmt.Errorf("canbus: invalid RTR length %q", rest)
+			}
+			g.Len = uint8(n)
+		}
+	} else {
+		dataStr = strings.ReplaceAll(dataStr, ".", "")
+		if len(dataStr)%2 != 0 {
+			return fmt.Errorf("canbus: odd number of hex digits in frame text data %q", dataStr)
+		}
+		if len(dataStr) > 16 {
+			return ErrInvalidLen
+		}
+		for i := 0; i < len(dataStr); i += 2 {
+			b, err := strconv.ParseUint(dataStr[i:i+2], 16, 8)
+			if err != nil {
+				return fmt.Errorf("canbus: invalid frame text data %q", dataStr)
+			}
+			g.Data[i/2] = byte(b)
+		}
+		g.Len = uint8(len(dataStr) / 2)
+	}
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	*f = g
+	return nil
+}
+
+// appendFrameText appends the candump frame portion (ID#DATA) of f to b.
+func appendFrameText(b []byte, f Frame) []byte {
+	const hexDigits = "0123456789ABCDEF"
+	id, width := f.ID, 3
+	if f.Extended || f.Error {
+		width = 8
+	}
+	if f.Error {
+		id |= canErrFlag
+	}
+	for i := width - 1; i >= 0; i-- {
+		b = append(b, hexDigits[(id>>(4*uint(i)))&0xF])
+	}
+	b = append(b, '#')
+	if f.RTR {
+		b = append(b, 'R')
+		if f.Len > 0 {
+			b = append(b, '0'+f.Len)
+		}
+		return b
+	}
+	for i := 0; i < int(f.Len) && i < len(f.Data); i++ {
+		b = append(b, hexDigits[f.Data[i]>>4], hexDigits[f.Data[i]&0xF])
+	}
+	return b
+}
+
+// StuffedBitRange returns the on-wire length of the frame in bits, from SOF
+// through EOF, without and with worst-case bit stuffing. The 3-bit interframe
+// space is not included. RTR frames carry no data field regardless of Len.
+//
+// Bit stuffing applies from SOF to the end of the CRC sequence; at worst one
+// stuff bit is inserted after every 4 bits following the first.
+func (f Frame) StuffedBitRange() (min, max int) {
+	dataBits := 0
+	if !f.RTR {
+		dataBits = 8 * int(f.Len)
+	}
+	// SOF + arbitration + control + data + CRC sequence.
+	stuffable := 1 + 11 + 1 + 1 + 1 + 4 + dataBits + 15 // SOF, ID, RTR, IDE, r0, DLC, data, CRC
+	if f.Extended {
+		stuffable = 1 + 11 + 1 + 1 + 18 + 1 + 1 + 1 + 4 + dataBits + 15 // SOF, ID, SRR, IDE, ID ext, RTR, r1, r0, DLC, data, CRC
+	}
+	// CRC delimiter, ACK slot, ACK delimiter and EOF are not stuffed.
+	min = stuffable + 1 + 1 + 1 + 7
+	max = min + (stuffable-1)/4
+	return min, max
+}
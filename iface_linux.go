@@ -181,3 +181,21 @@ func ConfigureLinuxCANInterface(name string, opts LinuxCANInterfaceOptions) erro
 	return nil
 }
 
+// RestartCANInterface restarts a CAN controller, e.g. after bus-off, using
+// `ip link set dev NAME type can restart`. Drivers only accept a manual
+// restart while the controller is bus-off; if the command fails, the
+// interface is cycled down and up instead, which also resets the
+// controller. Requires CAP_NET_ADMIN (or root).
+func RestartCANInterface(name string) error {
+	if len(name) == 0 || len(name) >= ifNameSize {
+		return fmt.Errorf("canbus: invalid interface name %q", name)
+	}
+	cmd := exec.Command("ip", "link", "set", "dev", name, "type", "can", "restart")
+	if _, err := cmd.CombinedOutput(); err == nil {
+		return nil
+	}
+	if err := SetInterfaceDown(name); err != nil {
+		return RequireRootOrCapNetAdmin(err)
+	}
+	return RequireRootOrCapNetAdmin(SetInterfaceUp(name))
+}
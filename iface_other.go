@@ -0,0 +1,10 @@
+//go:build !linux
+
+package canbus
+
+import "errors"
+
+// RestartCANInterface is only supported on Linux.
+func RestartCANInterface(name string) error {
+	return errors.New("canbus: RestartCANInterface is only supported on Linux")
+}
@@ -0,0 +1,250 @@
+package canbus
+
+// Kernel translation of identifier filters.
+//
+// A FrameFilter is an opaque function, so its kernel form cannot be
+// recovered. KernelFilterSpec pairs the filter built by ByID, ByMask, ... with
+// its CAN_RAW_FILTER form; the Kernel* constructors below mirror those
+// builders and compose with KernelAllOf and KernelAnyOf.
+
+// maxKernelFilters is CAN_RAW_FILTER_MAX, the most filters the kernel
+// accepts on one socket.
+const maxKernelFilters = 512
+
+// FrameMatcher reports whether a frame is selected. FrameFilter, CANFilter
+// and KernelFilterSpec implement it.
+type FrameMatcher interface {
+    Match(f Frame) bool
+}
+
+// Match calls ff; a nil FrameFilter matches every frame.
+func (ff FrameFilter) Match(f Frame) bool {
+    return ff == nil || ff(f)
+}
+
+// KernelFilterSpec is an identifier filter with an exact kernel form. The
+// zero value matches every frame. Build specs with KernelByID and the other
+// Kernel* constructors; Match and Filter use the same plain FrameFilter the
+// corresponding builder returns, so a spec costs nothing extra per frame.
+type KernelFilterSpec struct {
+    filter FrameFilter
+    kernel func() []CANFilter // OR-ed; computed on demand
+}
+
+// Match reports whether f passes the filter.
+func (s KernelFilterSpec) Match(f Frame) bool {
+    return s.filter == nil || s.filter(f)
+}
+
+// Filter returns the spec as a FrameFilter, e.g. for Mux.Subscribe. It is
+// nil for the zero spec.
+func (s KernelFilterSpec) Filter() FrameFilter {
+    return s.filter
+}
+
+func (s KernelFilterSpec) isZero() bool { return s.filter == nil }
+
+// kernelFilters returns the spec's kernel form.
+func (s KernelFilterSpec) kernelFilters() []CANFilter {
+    if s.isZero() {
+        return []CANFilter{{}, {Extended: true}}
+    }
+    return s.kernel()
+}
+
+// KernelByID is ByID with its kernel form.
+func KernelByID(id uint32) KernelFilterSpec {
+    return KernelFilterSpec{ByID(id), func() []CANFilter { return maskFilters(id, ^uint32(0)) }}
+}
+
+// KernelByStandardID is ByStandardID with its kernel form.
+func KernelByStandardID(id uint32) KernelFilterSpec {
+    return KernelFilterSpec{ByStandardID(id), func() []CANFilter { return maskFilter(id, ^uint32(0), false) }}
+}
+
+// KernelByExtendedID is ByExtendedID with its kernel form.
+func KernelByExtendedID(id uint32) KernelFilterSpec {
+    return KernelFilterSpec{ByExtendedID(id), func() []CANFilter { return maskFilter(id, ^uint32(0), true) }}
+}
+
+// KernelByIDs is ByIDs with its kernel form.
+func KernelByIDs(ids ...uint32) KernelFilterSpec {
+    list := make([]uint32, 0, len(ids))
+    seen := make(map[uint32]bool, len(ids))
+    for _, id := range ids {
+        if !seen[id] {
+            seen[id] = true
+            list = append(list, id)
+        }
+    }
+    return KernelFilterSpec{ByIDs(list...), func() []CANFilter {
+        var out []CANFilter
+        for _, id := range list {
+            out = append(out, maskFilters(id, ^uint32(0))...)
+        }
+        return out
+    }}
+}
+
+// KernelByRange is ByRange with its kernel form.
+func KernelByRange(minID, maxID uint32) KernelFilterSpec {
+    if maxID < minID {
+        minID, maxID = maxID, minID
+    }
+    return KernelFilterSpec{ByRange(minID, maxID), func() []CANFilter { return rangeFilters(minID, maxID) }}
+}
+
+// KernelByMask is ByMask with its kernel form.
+func KernelByMask(id, mask uint32) KernelFilterSpec {
+    return KernelFilterSpec{ByMask(id, mask), func() []CANFilter { return maskFilters(id, mask) }}
+}
+
+// KernelStandardOnly is StandardOnly with its kernel form.
+func KernelStandardOnly() KernelFilterSpec {
+    return KernelFilterSpec{StandardOnly(), func() []CANFilter { return []CANFilter{{}} }}
+}
+
+// KernelExtendedOnly is ExtendedOnly with its kernel form.
+func KernelExtendedOnly() KernelFilterSpec {
+    return KernelFilterSpec{ExtendedOnly(), func() []CANFilter { return []CANFilter{{Extended: true}} }}
+}
+
+// KernelAllOf is AllOf over specs. Zero specs are skipped as nil filters are
+// in AllOf; if none remain the result is the zero spec.
+func KernelAllOf(specs ...KernelFilterSpec) KernelFilterSpec {
+    specs, filters := nonZeroSpecs(specs)
+    if len(specs) == 0 {
+        return KernelFilterSpec{}
+    }
+    return KernelFilterSpec{AllOf(filters...), func() []CANFilter {
+        acc := []CANFilter{{}, {Extended: true}}
+        for _, s := range specs {
+            var next []CANFilter
+            for _, a := range acc {
+                for _, b := range s.kernelFilters() {
+                    if c, ok := intersectCANFilters(a, b); ok && !containsCANFilter(next, c) {
+                        next = append(next, c)
+                    }
+                }
+            }
+            if len(next) > maxKernelFilters {
+                return next // CompileKernelFilter rejects it
+            }
+            acc = next
+        }
+        return acc
+    }}
+}
+
+// KernelAnyOf is AnyOf over specs. Zero specs are skipped as nil filters are
+// in AnyOf; if none remain the result is the zero spec.
+func KernelAnyOf(specs ...KernelFilterSpec) KernelFilterSpec {
+    specs, filters := nonZeroSpecs(specs)
+    if len(specs) == 0 {
+        return KernelFilterSpec{}
+    }
+    return KernelFilterSpec{AnyOf(filters...), func() []CANFilter {
+        var acc []CANFilter
+        for _, s := range specs {
+            for _, c := range s.kernelFilters() {
+                if !containsCANFilter(acc, c) {
+                    acc = append(acc, c)
+                }
+            }
+        }
+        return acc
+    }}
+}
+
+// nonZeroSpecs returns a copy of specs without zero entries and their
+// filters.
+func nonZeroSpecs(specs []KernelFilterSpec) ([]KernelFilterSpec, []FrameFilter) {
+    out := make([]KernelFilterSpec, 0, len(specs))
+    filters := make([]FrameFilter, 0, len(specs))
+    for _, s := range specs {
+        if !s.isZero() {
+            out = append(out, s)
+            filters = append(filters, s.filter)
+        }
+    }
+    return out, filters
+}
+
+// CompileKernelFilter returns the kernel filters (OR-ed, as installed by
+// SocketCANOptions.Filters without JoinFilters) that pass exactly the data
+// and RTR frames spec matches. The zero spec translates to "everything".
+// The bool is false when more than 512 kernel filters would be needed;
+// callers should then filter in userspace with spec.Match.
+//
+// Error frames are outside the translation: the kernel delivers them
+// according to SocketCANOptions.ErrorMask regardless of filters.
+func CompileKernelFilter(spec KernelFilterSpec) ([]CANFilter, bool) {
+    filters := spec.kernelFilters()
+    if len(filters) > maxKernelFilters {
+        return nil, false
+    }
+    return filters, true
+}
+
+func containsCANFilter(list []CANFilter, c CANFilter) bool {
+    for _, x := range list {
+        if x == c {
+            return true
+        }
+    }
+    return false
+}
+
+// idLimit is the identifier mask of the frame format.
+func idLimit(extended bool) uint32 {
+    if extended {
+        return maxExtID
+    }
+    return maxStdID
+}
+
+// maskFilter returns the kernel filter matching frames of one format with
+// (ID & mask) == (id & mask), or none if no such frame exists.
+func maskFilter(id, mask uint32, extended bool) []CANFilter {
+    lim := idLimit(extended)
+    if id&mask&^lim != 0 {
+        return nil
+    }
+    return []CANFilter{{ID: id & mask, Mask: mask & lim, Extended: extended}}
+}
+
+// intersectCANFilters returns the filter matching frames that pass both a
+// and b, or false if none do. Both must be normalized as by maskFilter.
+func intersectCANFilters(a, b CANFilter) (CANFilter, bool) {
+    if a.Extended != b.Extended || (a.ID^b.ID)&a.Mask&b.Mask != 0 {
+        return CANFilter{}, false
+    }
+    return CANFilter{ID: a.ID | b.ID, Mask: a.Mask | b.Mask, Extended: a.Extended}, true
+}
+
+// maskFilters returns filters for ByMask(id, mask) over both formats.
+func maskFilters(id, mask uint32) []CANFilter {
+    return append(maskFilter(id, mask, false), maskFilter(id, mask, true)...)
+}
+
+// rangeFilters returns filters for IDs in [minID, maxID] over both formats,
+// splitting each range into aligned power-of-two blocks.
+func rangeFilters(minID, maxID uint32) []CANFilter {
+    var out []CANFilter
+    for _, ext := range []bool{false, true} {
+        lim := uint64(idLimit(ext))
+        lo, hi := uint64(minID), uint64(maxID)
+        if hi > lim {
+            hi = lim
+        }
+        for lo <= hi {
+            size := uint64(1)
+            for lo&(size*2-1) == 0 && lo+size*2-1 <= hi {
+                size *= 2
+            }
+            out = append(out, CANFilter{ID: uint32(lo), Mask: uint32(lim &^ (size - 1)), Extended: ext})
+            lo += size
+        }
+    }
+    return out
+}
@@ -3,6 +3,9 @@ package canbus
 import (
     "context"
     "log/slog"
+    "sync"
+    "sync/atomic"
+    "time"
 )
 
 // LoggedBus is a Bus decorator that logs Send/Receive operations using a
@@ -42,25 +45,153 @@ func NewLoggedBusWithFilter(inner Bus, logger *slog.Logger, level slog.Level, op
     }
 }
 
+// LoggedBusOptions configures NewLoggedBusWithOptions. The zero value logs
+// nothing; set Ops to select operations.
+type LoggedBusOptions struct {
+    Level  slog.Level
+    Ops    LogOption
+    Filter FrameFilter // nil considers every frame, as in NewLoggedBus
+    // SampleEvery logs only the first of every N frames passing Filter,
+    // counted across Send and Receive. Zero or one logs every frame.
+    SampleEvery uint64
+    // MaxPerSecond caps frame log lines per one-second window; zero means
+    // no cap. Frames over the cap are counted and the count is reported in
+    // a "canbus log suppressed" line with a "dropped" attribute before the
+    // next frame logged in a later window.
+    MaxPerSecond int
+    // Attrs, if set, returns extra attributes appended to every frame log
+    // line, e.g. CANopen function code and node decoded with
+    // canopen.ParseCOBID. It is called only for frames that are logged and
+    // must be safe for concurrent use.
+    Attrs func(Frame) []slog.Attr
+}
+
+// NewLoggedBusWithOptions wraps the given Bus and logs as configured by
+// opts. Sampling and rate limiting keep logs of a busy bus readable; errors
+// are always logged.
+func NewLoggedBusWithOptions(inner Bus, logger *slog.Logger, opts LoggedBusOptions) Bus {
+    l := &loggedBus{
+        inner:       inner,
+        logger:      logger,
+        level:       opts.Level,
+        opts:        opts.Ops,
+        filter:      opts.Filter,
+        sampleEvery: opts.SampleEvery,
+        attrs:       opts.Attrs,
+    }
+    if opts.MaxPerSecond > 0 {
+        l.limit = &logLimiter{max: opts.MaxPerSecond, now: time.Now}
+    }
+    return l
+}
+
+// NewGatedLoggedBus wraps the given Bus and logs selected operations, but
+// routine traffic is suppressed until SetVerbose(true) is called. Frames
+// matching always (e.g. EMCY and NMT) are logged regardless of verbosity.
+// Errors are always logged. The bus starts non-verbose.
+func NewGatedLoggedBus(inner Bus, logger *slog.Logger, level slog.Level, opts LogOption, always FrameFilter) *GatedLoggedBus {
+    return &GatedLoggedBus{&loggedBus{
+        inner:     inner,
+        logger:    logger,
+        level:     level,
+        opts:      opts,
+        always:    always,
+        gated:     true,
+    }}
+}
+
+// GatedLoggedBus is a logging Bus decorator whose routine frame logging can
+// be switched on and off at runtime, e.g. enabled when an EMCY is observed to
+// capture context around a fault.
+type GatedLoggedBus struct {
+    *loggedBus
+}
+
+// SetVerbose enables or disables logging of frames not matched by the
+// always-filter. It is safe to call concurrently with Send and Receive.
+func (g *GatedLoggedBus) SetVerbose(v bool) {
+    g.verbose.Store(v)
+}
+
 type loggedBus struct {
     inner     Bus
     logger    *slog.Logger
     level     slog.Level
     opts      LogOption
     filter    FrameFilter
+    always    FrameFilter
+    gated     bool
+    verbose   atomic.Bool
+
+    sampleEvery uint64
+    sampled     atomic.Uint64 // frames considered for sampling
+    limit       *logLimiter   // nil when not rate limited
+    attrs       func(Frame) []slog.Attr
+}
+
+// logLimiter caps log lines per one-second window and counts the excess.
+type logLimiter struct {
+    max int
+    now func() time.Time
+
+    mu      sync.Mutex
+    start   time.Time // beginning of the current window
+    count   int       // lines logged in the current window
+    dropped uint64    // lines suppressed since the last summary
+}
+
+// allow reports whether a line may be logged now. When a new window opens
+// it also returns the number of lines suppressed before it.
+func (r *logLimiter) allow() (ok bool, dropped uint64) {
+    now := r.now()
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if now.Sub(r.start) >= time.Second {
+        r.start, r.count = now, 0
+        dropped, r.dropped = r.dropped, 0
+    }
+    if r.count >= r.max {
+        r.dropped++
+        return false, dropped
+    }
+    r.count++
+    return true, dropped
+}
+
+// shouldLog reports whether a frame passes the always-filter, the verbosity
+// gate and the filter.
+func (l *loggedBus) shouldLog(f Frame) bool {
+    if l.always != nil && l.always(f) {
+        return true
+    }
+    if l.gated && !l.verbose.Load() {
+        return false
+    }
+    return l.filter == nil || l.filter(f)
+}
+
+// admit applies sampling and rate limiting to a frame that passed
+// shouldLog, logging a summary of suppressed lines when one is due.
+func (l *loggedBus) admit() bool {
+    if l.sampleEvery > 1 && (l.sampled.Add(1)-1)%l.sampleEvery != 0 {
+        return false
+    }
+    if l.limit == nil {
+        return true
+    }
+    ok, dropped := l.limit.allow()
+    if dropped > 0 {
+        l.logger.Log(context.Background(), l.level, "canbus log suppressed",
+            "dropped", dropped,
+        )
+    }
+    return ok
 }
 
 // Send logs the frame and the result when write logging is enabled.
 func (l *loggedBus) Send(frame Frame) error {
-    if l.opts&LogWrite != 0 && (l.filter == nil || l.filter(frame)) {
-        l.logger.Log(context.Background(), l.level, "canbus send",
-            "id", frame.ID,
-            "extended", frame.Extended,
-            "rtr", frame.RTR,
-            "len", int(frame.Len),
-            "data", frame.Data[:frame.Len],
-            "string", frame.String(),
-        )
+    if l.opts&LogWrite != 0 && l.shouldLog(frame) && l.admit() {
+        l.logFrame("canbus send", frame)
     }
     err := l.inner.Send(frame)
     if l.opts&LogWrite != 0 && err != nil {
@@ -81,21 +212,32 @@ func (l *loggedBus) Receive() (Frame, error) {
                 "error", err,
             )
         } else {
-            if l.filter == nil || l.filter(f) {
-                l.logger.Log(context.Background(), l.level, "canbus receive",
-                "id", f.ID,
-                "extended", f.Extended,
-                "rtr", f.RTR,
-                "len", int(f.Len),
-                "data", f.Data[:f.Len],
-                "string", f.String(),
-                )
+            if l.shouldLog(f) && l.admit() {
+                l.logFrame("canbus receive", f)
             }
         }
     }
     return f, err
 }
 
+// logFrame logs f with the standard attributes followed by any custom ones.
+func (l *loggedBus) logFrame(msg string, f Frame) {
+    args := []any{
+        "id", f.ID,
+        "extended", f.Extended,
+        "rtr", f.RTR,
+        "len", int(f.Len),
+        "data", f.Data[:f.Len],
+        "string", f.String(),
+    }
+    if l.attrs != nil {
+        for _, a := range l.attrs(f) {
+            args = append(args, a)
+        }
+    }
+    l.logger.Log(context.Background(), l.level, msg, args...)
+}
+
 // Close forwards to the inner Bus without logging.
 func (l *loggedBus) Close() error {
     return l.inner.Close()
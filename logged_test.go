@@ -3,7 +3,10 @@ package canbus
 import (
     "context"
     "log/slog"
+    "strings"
+    "sync"
     "testing"
+    "time"
 )
 
 type recordSink struct{
@@ -96,8 +99,8 @@ func TestLoggedBus_FilterSkipsSYNCAndHeartbeat(t *testing.T) {
     defer receiver.Close()
 
     // Build one SYNC, one heartbeat (node 1), and one arbitrary data frame
-    syncFrame := MustFrame(0x080, nil)
-    hbFrame := MustFrame(0x700+0x01, []byte{0x05})
+    syncFrame := MustStandardFrame(0x080, nil)
+    hbFrame := MustStandardFrame(0x700+0x01, []byte{0x05})
     dataFrame := MustFrame(0x123, []byte{0xDE, 0xAD})
 
     if err := sender.Send(syncFrame); err != nil { t.Fatalf("send sync: %v", err) }
@@ -119,3 +122,194 @@ func TestLoggedBus_FilterSkipsSYNCAndHeartbeat(t *testing.T) {
     if recvCount != 1 { t.Fatalf("expected 1 receive log, got %d", recvCount) }
 }
 
+
+func TestGatedLoggedBus_SetVerbose(t *testing.T) {
+    lb := NewLoopbackBus()
+    defer lb.Close()
+
+    sink := &recordSink{}
+    logger := slog.New(sink)
+
+    // EMCY (0x081-0x0FF) and NMT (0x000) are always logged.
+    always := Or(ByID(0x000), ByRange(0x081, 0x0FF))
+    sender := NewGatedLoggedBus(lb.Open(), logger, slog.LevelInfo, LogWrite, always)
+    rx := lb.Open()
+    defer sender.Close()
+    defer rx.Close()
+
+    countSends := func() int {
+        n := 0
+        for _, r := range sink.records {
+            if r.Message == "canbus send" { n++ }
+        }
+        return n
+    }
+    send := func(f Frame) {
+        if err := sender.Send(f); err != nil { t.Fatalf("send: %v", err) }
+        if _, err := rx.Receive(); err != nil { t.Fatalf("receive: %v", err) }
+    }
+
+    send(MustStandardFrame(0x123, []byte{1}))
+    if n := countSends(); n != 0 {
+        t.Fatalf("routine frame logged while not verbose: %d", n)
+    }
+    send(MustStandardFrame(0x085, make([]byte, 8)))
+    send(MustStandardFrame(0x000, []byte{0x01, 0x00}))
+    if n := countSends(); n != 2 {
+        t.Fatalf("expected EMCY and NMT logged, got %d", n)
+    }
+
+    sender.SetVerbose(true)
+    send(MustStandardFrame(0x123, []byte{2}))
+    if n := countSends(); n != 3 {
+        t.Fatalf("routine frame not logged while verbose: %d", n)
+    }
+    sender.SetVerbose(false)
+    send(MustStandardFrame(0x123, []byte{3}))
+    if n := countSends(); n != 3 {
+        t.Fatalf("routine frame logged after verbose off: %d", n)
+    }
+}
+
+func TestLoggedBus_SampleEvery(t *testing.T) {
+    lb := NewLoopbackBus()
+    defer lb.Close()
+
+    sink := &recordSink{}
+    sender := NewLoggedBusWithOptions(lb.Open(), slog.New(sink), LoggedBusOptions{
+        Level: slog.LevelInfo, Ops: LogWrite, Filter: Not(ByID(0x080)), SampleEvery: 3,
+    })
+    defer sender.Close()
+
+    for i := 0; i < 7; i++ {
+        if err := sender.Send(MustFrame(0x123, []byte{byte(i)})); err != nil { t.Fatalf("send: %v", err) }
+        // Filtered frames do not count towards sampling.
+        if err := sender.Send(MustFrame(0x080, nil)); err != nil { t.Fatalf("send sync: %v", err) }
+    }
+    var got []byte
+    for _, r := range sink.records {
+        r.Attrs(func(a slog.Attr) bool {
+            if a.Key == "data" { got = append(got, a.Value.Any().([]byte)[0]) }
+            return true
+        })
+    }
+    if string(got) != string([]byte{0, 3, 6}) {
+        t.Fatalf("sampled frames = %v, want [0 3 6]", got)
+    }
+}
+
+func TestLoggedBus_MaxPerSecond(t *testing.T) {
+    lb := NewLoopbackBus()
+    defer lb.Close()
+
+    sink := &recordSink{}
+    bus := NewLoggedBusWithOptions(lb.Open(), slog.New(sink), LoggedBusOptions{
+        Level: slog.LevelInfo, Ops: LogWrite, MaxPerSecond: 2,
+    })
+    defer bus.Close()
+    now := time.Unix(100, 0)
+    bus.(*loggedBus).limit.now = func() time.Time { return now }
+
+    send := func(n int) {
+        for i := 0; i < n; i++ {
+            if err := bus.Send(MustFrame(0x123, nil)); err != nil { t.Fatalf("send: %v", err) }
+        }
+    }
+    send(5)
+    if n := len(sink.records); n != 2 {
+        t.Fatalf("expected 2 lines in the first second, got %d", n)
+    }
+    now = now.Add(time.Second)
+    send(1)
+    if n := len(sink.records); n != 4 {
+        t.Fatalf("expected summary and frame line, got %d records", n)
+    }
+    r := sink.records[2]
+    if r.Message != "canbus log suppressed" {
+        t.Fatalf("expected summary line, got %q", r.Message)
+    }
+    r.Attrs(func(a slog.Attr) bool {
+        if a.Key == "dropped" && a.Value.Uint64() != 3 {
+            t.Fatalf("dropped = %v, want 3", a.Value)
+        }
+        return true
+    })
+}
+
+func TestLoggedBus_LimitsConcurrent(t *testing.T) {
+    lb := NewLoopbackBus()
+    defer lb.Close()
+
+    var mu sync.Mutex
+    lines := 0
+    handler := slog.NewTextHandler(writerFunc(func(p []byte) (int, error) {
+        mu.Lock()
+        lines++
+        mu.Unlock()
+        return len(p), nil
+    }), nil)
+    bus := NewLoggedBusWithOptions(lb.Open(), slog.New(handler), LoggedBusOptions{
+        Level: slog.LevelInfo, Ops: LogWrite, SampleEvery: 2, MaxPerSecond: 1000,
+    })
+    defer bus.Close()
+
+    var wg sync.WaitGroup
+    for g := 0; g < 4; g++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := 0; i < 100; i++ {
+                _ = bus.Send(MustFrame(0x123, nil))
+            }
+        }()
+    }
+    wg.Wait()
+    if lines != 200 {
+        t.Fatalf("expected 200 sampled lines, got %d", lines)
+    }
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestLoggedBus_Attrs(t *testing.T) {
+    lb := NewLoopbackBus()
+    defer lb.Close()
+
+    sink := &recordSink{}
+    opts := LoggedBusOptions{
+        Level: slog.LevelInfo,
+        Ops:   LogAll,
+        Attrs: func(f Frame) []slog.Attr {
+            return []slog.Attr{slog.Int("node", int(f.ID&0x7F)), slog.String("service", "tpdo1")}
+        },
+    }
+    sender := NewLoggedBusWithOptions(lb.Open(), slog.New(sink), opts)
+    receiver := NewLoggedBusWithOptions(lb.Open(), slog.New(sink), opts)
+    defer sender.Close()
+    defer receiver.Close()
+
+    if err := sender.Send(MustFrame(0x185, []byte{1})); err != nil { t.Fatalf("send: %v", err) }
+    if _, err := receiver.Receive(); err != nil { t.Fatalf("receive: %v", err) }
+
+    if len(sink.records) != 2 {
+        t.Fatalf("expected 2 records, got %d", len(sink.records))
+    }
+    for _, r := range sink.records {
+        var keys []string
+        var node int64
+        r.Attrs(func(a slog.Attr) bool {
+            keys = append(keys, a.Key)
+            if a.Key == "node" { node = a.Value.Int64() }
+            return true
+        })
+        want := "id extended rtr len data string node service"
+        if got := strings.Join(keys, " "); got != want {
+            t.Fatalf("%s attrs = %q, want %q", r.Message, got, want)
+        }
+        if node != 5 {
+            t.Fatalf("node = %d, want 5", node)
+        }
+    }
+}
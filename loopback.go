@@ -1,7 +1,11 @@
 package canbus
 
 import (
+	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // LoopbackBus is an in-memory CAN bus for tests and simulations.
@@ -10,18 +14,207 @@ type LoopbackBus struct {
 	mu        sync.RWMutex
 	closed    bool
 	endpoints map[*loopEndpoint]struct{}
+	opts      LoopbackOptions
+	taps      map[uint64]chan Frame
+	nextTap   uint64
+
+	// Arbitration state: sends queue in pending and the arbiter goroutine
+	// hands each round, in priority order, to the targets' inboxes.
+	arbMu   sync.Mutex
+	arbSeq  uint64
+	pending []*arbRequest
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+// LoopbackOptions configures a LoopbackBus.
+type LoopbackOptions struct {
+	// DropWhenFull makes Send drop a frame for an endpoint whose receive
+	// buffer is full instead of blocking until it has room. Dropped frames
+	// are counted per endpoint (see DropCounter) and in DeliveryReport.
+	DropWhenFull bool
+
+	// Arbitration approximates CAN arbitration: frames sent concurrently
+	// are collected for ArbitrationWindow and then delivered in bus
+	// priority order (lower identifier first; for equal base identifiers a
+	// standard frame beats an extended one and a data frame beats an RTR)
+	// instead of FIFO. It is an approximation: there is no bit timing, a
+	// round only contains frames whose Send started before the window
+	// ended, and Send blocks until its frame has been delivered. Each
+	// endpoint receives frames in arbitration order, but a full endpoint
+	// only holds up the frames addressed to it, so an endpoint may reply
+	// from its receive loop without stalling the bus.
+	Arbitration bool
+	// ArbitrationWindow is how long the arbiter waits after the first
+	// pending send before ordering a round. Zero orders whatever is queued
+	// when the arbiter wakes, which rarely groups frames from different
+	// goroutines.
+	ArbitrationWindow time.Duration
+
+	// MinDelay and MaxDelay simulate bus latency: each frame is held for a
+	// random delay in [MinDelay, MaxDelay] before it is delivered to the
+	// other endpoints. Send blocks for the delay, so frames from one
+	// endpoint keep their order; concurrent frames from different endpoints
+	// are delivered in the order their delays expire (with Arbitration,
+	// delays run one after another in arbitration order). A MaxDelay below
+	// MinDelay is treated as MinDelay.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// Sleep waits out the simulated delay; nil uses time.Sleep. Tests can
+	// supply a fake clock's sleep to control latency deterministically.
+	Sleep func(time.Duration)
+
+	// InjectFault, if set, is called once per destination endpoint before
+	// the frame is delivered to it. Returning false drops the frame for that
+	// endpoint only; returning a modified frame (e.g. with flipped data bits)
+	// delivers the corrupted copy instead. The returned frame is not
+	// validated. InjectFault may be called concurrently from several senders.
+	InjectFault func(f Frame) (Frame, bool)
+}
+
+// delay returns the simulated latency for the next frame.
+func (o LoopbackOptions) delay() time.Duration {
+	d := o.MinDelay
+	if o.MaxDelay > d {
+		d += time.Duration(rand.Int63n(int64(o.MaxDelay-d) + 1))
+	}
+	return d
+}
+
+// arbRequest is a send waiting for its arbitration round.
+type arbRequest struct {
+	from  *loopEndpoint
+	frame Frame
+	seq   uint64
+	err   error
+	done  chan struct{}
+
+	mu        sync.Mutex
+	rep       DeliveryReport
+	remaining int // targets that have not taken the frame yet
+}
+
+// finish records one target's outcome and releases the sender once every
+// target has been served.
+func (r *arbRequest) finish(o deliveryOutcome) {
+	r.mu.Lock()
+	r.rep.add(o)
+	r.remaining--
+	last := r.remaining == 0
+	r.mu.Unlock()
+	if last {
+		close(r.done)
+	}
 }
 
-// NewLoopbackBus creates a new loopback bus.
+// defaultLoopbackBuffer is the receive buffer size used by Open.
+const defaultLoopbackBuffer = 64
+
+// tapBuffer is the channel buffer of a Tap.
+const tapBuffer = 1024
+
+// NewLoopbackBus creates a new loopback bus. Senders block while a
+// receiving endpoint's buffer is full.
 func NewLoopbackBus() *LoopbackBus {
-	return &LoopbackBus{endpoints: make(map[*loopEndpoint]struct{})}
+	return NewLoopbackBusWithOptions(LoopbackOptions{})
+}
+
+// NewLoopbackBusWithOptions creates a new loopback bus configured by opts.
+func NewLoopbackBusWithOptions(opts LoopbackOptions) *LoopbackBus {
+	b := &LoopbackBus{endpoints: make(map[*loopEndpoint]struct{}), opts: opts}
+	if opts.Arbitration {
+		b.wake = make(chan struct{}, 1)
+		b.done = make(chan struct{})
+		go b.arbitrate()
+	}
+	return b
+}
+
+// NewLoopbackPair returns two connected endpoints on a fresh loopback bus
+// and a function that closes the bus and both endpoints. Use NewLoopbackBus
+// and Open when more than two endpoints are needed.
+func NewLoopbackPair() (a, b Bus, closeFn func()) {
+	bus := NewLoopbackBus()
+	a, b = bus.Open(), bus.Open()
+	return a, b, func() { _ = bus.Close() }
 }
 
-// Open creates a new endpoint attached to the bus.
+// arbitrate delivers queued sends in rounds ordered by arbitration priority.
+func (b *LoopbackBus) arbitrate() {
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-b.wake:
+		}
+		if b.opts.ArbitrationWindow > 0 {
+			t := time.NewTimer(b.opts.ArbitrationWindow)
+			select {
+			case <-b.done:
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+		b.arbMu.Lock()
+		round := b.pending
+		b.pending = nil
+		b.arbMu.Unlock()
+		sort.Slice(round, func(i, j int) bool {
+			ki, kj := arbitrationKey(round[i].frame), arbitrationKey(round[j].frame)
+			if ki != kj {
+				return ki < kj
+			}
+			return round[i].seq < round[j].seq
+		})
+		for _, r := range round {
+			targets, err := r.from.targets(r.frame)
+			if err != nil || len(targets) == 0 {
+				r.err = err
+				close(r.done)
+				continue
+			}
+			r.remaining = len(targets)
+			for _, t := range targets {
+				t.push(r)
+			}
+		}
+	}
+}
+
+// arbitrationKey orders frames as CAN arbitration would: by the 11-bit base
+// identifier, then standard before extended (SRR/IDE are recessive), then
+// the 18-bit identifier extension, then data before RTR.
+func arbitrationKey(f Frame) uint64 {
+	var base, ext, ide uint64
+	if f.Extended {
+		base, ext, ide = uint64(f.ID>>18), uint64(f.ID&0x3FFFF), 1
+	} else {
+		base = uint64(f.ID & 0x7FF)
+	}
+	var rtr uint64
+	if f.RTR {
+		rtr = 1
+	}
+	return base<<20 | ide<<19 | ext<<1 | rtr
+}
+
+// Open creates a new endpoint attached to the bus with a 64-frame receive
+// buffer.
 func (b *LoopbackBus) Open() Bus {
+	return b.OpenBuffered(defaultLoopbackBuffer)
+}
+
+// OpenBuffered creates a new endpoint whose receive buffer holds size
+// frames. A size of 0 makes every delivery a rendezvous with Receive (or a
+// drop, with DropWhenFull); negative sizes are treated as 0.
+func (b *LoopbackBus) OpenBuffered(size int) Bus {
+	if size < 0 {
+		size = 0
+	}
 	ep := &loopEndpoint{
 		bus:    b,
-		ch:     make(chan Frame, 64),
+		ch:     make(chan Frame, size),
 		closed: make(chan struct{}),
 	}
 	b.mu.Lock()
@@ -35,6 +228,38 @@ func (b *LoopbackBus) Open() Bus {
 	return ep
 }
 
+// Tap returns a channel that receives a copy of every frame sent on the
+// bus, once per send and regardless of which endpoints receive it. Taps
+// never slow delivery: the channel buffers 1024 frames and further frames
+// are dropped until the reader catches up. The cancel function closes the
+// channel; closing the bus closes all taps. Tapping a closed bus returns an
+// already-closed channel.
+func (b *LoopbackBus) Tap() (<-chan Frame, func()) {
+	ch := make(chan Frame, tapBuffer)
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if b.taps == nil {
+		b.taps = make(map[uint64]chan Frame)
+	}
+	id := b.nextTap
+	b.nextTap++
+	b.taps[id] = ch
+	b.mu.Unlock()
+	cancel := func() {
+		b.mu.Lock()
+		if cur, ok := b.taps[id]; ok {
+			close(cur)
+			delete(b.taps, id)
+		}
+		b.mu.Unlock()
+	}
+	return ch, cancel
+}
+
 // Close closes the bus and detaches all endpoints.
 func (b *LoopbackBus) Close() error {
 	b.mu.Lock()
@@ -47,34 +272,208 @@ func (b *LoopbackBus) Close() error {
 		ep.closeNoLock()
 	}
 	b.endpoints = nil
+	for id, ch := range b.taps {
+		close(ch)
+		delete(b.taps, id)
+	}
 	b.mu.Unlock()
+	if b.done != nil {
+		close(b.done)
+		// Release sends still waiting for a round.
+		b.arbMu.Lock()
+		for _, r := range b.pending {
+			r.err = ErrClosed
+			close(r.done)
+		}
+		b.pending = nil
+		b.arbMu.Unlock()
+	}
 	return nil
 }
 
+// DeliveryReport describes the outcome of a loopback send.
+type DeliveryReport struct {
+	Delivered int // endpoints that received the frame
+	Closed    int // endpoints that closed before the frame was delivered
+	Dropped   int // endpoints whose full buffer dropped the frame (DropWhenFull)
+	Lost      int // endpoints whose copy was dropped by InjectFault
+}
+
+// deliveryOutcome is what happened to one endpoint's copy of a frame.
+type deliveryOutcome int
+
+const (
+	outcomeDelivered deliveryOutcome = iota
+	outcomeClosed
+	outcomeDropped
+	outcomeLost
+)
+
+func (r *DeliveryReport) add(o deliveryOutcome) {
+	switch o {
+	case outcomeDelivered:
+		r.Delivered++
+	case outcomeClosed:
+		r.Closed++
+	case outcomeDropped:
+		r.Dropped++
+	case outcomeLost:
+		r.Lost++
+	}
+}
+
+// DeliveryReporter is implemented by endpoints returned from
+// LoopbackBus.Open. SendReport behaves like Send but also reports how many
+// endpoints received the frame, which lets simulations detect frames that
+// reached no live node.
+type DeliveryReporter interface {
+	SendReport(frame Frame) (DeliveryReport, error)
+}
+
+// DropCounter is implemented by endpoints returned from LoopbackBus.Open
+// and OpenBuffered. Dropped reports how many frames addressed to the
+// endpoint were discarded because its buffer was full.
+type DropCounter interface {
+	Dropped() uint64
+}
+
+// RTRResponder is implemented by endpoints returned from LoopbackBus.Open
+// and OpenBuffered. RespondToRTR registers a canned reply: whenever another
+// endpoint sends a remote frame with identifier id (and the same format,
+// standard or extended, as resp), this endpoint sends resp as if its owner
+// had answered. Registering the same id again replaces the reply. The
+// remote frame is still delivered to the endpoint, and only a delivered
+// copy is answered: a request dropped by a full buffer (DropWhenFull) or by
+// InjectFault, or addressed to a closed endpoint, gets no reply. Replies
+// are sent asynchronously once the request is in the endpoint's buffer and
+// are not ordered with respect to other traffic.
+type RTRResponder interface {
+	RespondToRTR(id uint32, resp Frame)
+}
+
+type rtrKey struct {
+	id       uint32
+	extended bool
+}
+
 type loopEndpoint struct {
-	bus    *LoopbackBus
-	ch     chan Frame
-	mu     sync.Mutex
-	dead   bool
-	closed chan struct{}
+	bus     *LoopbackBus
+	ch      chan Frame
+	mu      sync.Mutex
+	dead    bool
+	closed  chan struct{}
+	dropped atomic.Uint64
+	rtr     map[rtrKey]Frame
+
+	// With Arbitration, the arbiter queues sends in inbox and a pump
+	// goroutine, running while inbox is non-empty, hands them to ch.
+	inbox   []*arbRequest
+	pumping bool
+}
+
+// RespondToRTR registers resp as the automatic reply to remote frames with
+// identifier id.
+func (e *loopEndpoint) RespondToRTR(id uint32, resp Frame) {
+	e.mu.Lock()
+	if e.rtr == nil {
+		e.rtr = make(map[rtrKey]Frame)
+	}
+	e.rtr[rtrKey{id: id, extended: resp.Extended}] = resp
+	e.mu.Unlock()
+}
+
+// answerRTR sends the registered reply, if any, to the remote frame f.
+func (e *loopEndpoint) answerRTR(f Frame) {
+	if !f.RTR {
+		return
+	}
+	e.mu.Lock()
+	resp, ok := e.rtr[rtrKey{id: f.ID, extended: f.Extended}]
+	e.mu.Unlock()
+	if ok {
+		go func() { _ = e.Send(resp) }()
+	}
+}
+
+// Dropped returns the number of frames dropped for this endpoint.
+func (e *loopEndpoint) Dropped() uint64 {
+	return e.dropped.Load()
 }
 
 // Send broadcasts the frame to all other endpoints on the same bus.
 func (e *loopEndpoint) Send(frame Frame) error {
+	_, err := e.SendReport(frame)
+	return err
+}
+
+// SendReport broadcasts the frame like Send and reports delivery counts.
+func (e *loopEndpoint) SendReport(frame Frame) (DeliveryReport, error) {
+	var rep DeliveryReport
 	if err := frame.Validate(); err != nil {
-		return err
+		return rep, err
 	}
 	e.mu.Lock()
 	if e.dead {
 		e.mu.Unlock()
-		return ErrClosed
+		return rep, ErrClosed
 	}
 	e.mu.Unlock()
+	if e.bus.opts.Arbitration {
+		return e.bus.enqueue(e, frame)
+	}
+	return e.deliver(frame)
+}
+
+// enqueue submits a send to the arbiter and waits for its round.
+func (b *LoopbackBus) enqueue(from *loopEndpoint, frame Frame) (DeliveryReport, error) {
+	r := &arbRequest{from: from, frame: frame, done: make(chan struct{})}
+	b.arbMu.Lock()
+	select {
+	case <-b.done:
+		b.arbMu.Unlock()
+		return DeliveryReport{}, ErrClosed
+	default:
+	}
+	b.arbSeq++
+	r.seq = b.arbSeq
+	b.pending = append(b.pending, r)
+	b.arbMu.Unlock()
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+	<-r.done
+	return r.rep, r.err
+}
+
+// deliver broadcasts frame to all other live endpoints.
+func (e *loopEndpoint) deliver(frame Frame) (DeliveryReport, error) {
+	var rep DeliveryReport
+	targets, err := e.targets(frame)
+	if err != nil {
+		return rep, err
+	}
+	for _, t := range targets {
+		rep.add(t.accept(frame))
+	}
+	return rep, nil
+}
+
+// targets waits out the simulated delay, feeds frame to the taps and
+// returns the other live endpoints.
+func (e *loopEndpoint) targets(frame Frame) ([]*loopEndpoint, error) {
+	if d := e.bus.opts.delay(); d > 0 {
+		if e.bus.opts.Sleep != nil {
+			e.bus.opts.Sleep(d)
+		} else {
+			time.Sleep(d)
+		}
+	}
 	// Snapshot endpoints under bus lock to avoid holding while sending.
 	e.bus.mu.RLock()
+	defer e.bus.mu.RUnlock()
 	if e.bus.closed {
-		e.bus.mu.RUnlock()
-		return ErrClosed
+		return nil, ErrClosed
 	}
 	targets := make([]*loopEndpoint, 0, len(e.bus.endpoints))
 	for ep := range e.bus.endpoints {
@@ -82,25 +481,89 @@ func (e *loopEndpoint) Send(frame Frame) error {
 			targets = append(targets, ep)
 		}
 	}
-	e.bus.mu.RUnlock()
+	// Taps are fed under the read lock so cancel cannot close them mid-send.
+	for _, ch := range e.bus.taps {
+		select {
+		case ch <- frame:
+		default:
+		}
+	}
+	return targets, nil
+}
 
-	// Deliver to targets.
-	for _, t := range targets {
+// accept hands one copy of frame to e, applying fault injection and the
+// bus's full-buffer policy.
+func (e *loopEndpoint) accept(frame Frame) deliveryOutcome {
+	if inject := e.bus.opts.InjectFault; inject != nil {
+		var ok bool
+		if frame, ok = inject(frame); !ok {
+			return outcomeLost
+		}
+	}
+	if e.bus.opts.DropWhenFull {
 		select {
-		case t.ch <- frame:
-		case <-t.closed:
+		case e.ch <- frame:
+			e.answerRTR(frame)
+			return outcomeDelivered
+		case <-e.closed:
+			return outcomeClosed
+		default:
+			e.dropped.Add(1)
+			return outcomeDropped
 		}
 	}
-	return nil
+	select {
+	case e.ch <- frame:
+		e.answerRTR(frame)
+		return outcomeDelivered
+	case <-e.closed:
+		return outcomeClosed
+	}
+}
+
+// push queues an arbitrated send for e, starting its pump if idle.
+func (e *loopEndpoint) push(r *arbRequest) {
+	e.mu.Lock()
+	e.inbox = append(e.inbox, r)
+	start := !e.pumping
+	e.pumping = true
+	e.mu.Unlock()
+	if start {
+		go e.pump()
+	}
+}
+
+// pump delivers queued sends in order until the inbox is empty. Once e is
+// closed the remaining sends resolve as Closed without blocking.
+func (e *loopEndpoint) pump() {
+	for {
+		e.mu.Lock()
+		if len(e.inbox) == 0 {
+			e.pumping = false
+			e.mu.Unlock()
+			return
+		}
+		r := e.inbox[0]
+		e.inbox[0] = nil
+		e.inbox = e.inbox[1:]
+		e.mu.Unlock()
+		r.finish(e.accept(r.frame))
+	}
 }
 
 // Receive waits for the next frame.
 func (e *loopEndpoint) Receive() (Frame, error) {
-	f, ok := <-e.ch
-	if !ok {
+	select {
+	case <-e.closed:
+		return Frame{}, ErrClosed
+	default:
+	}
+	select {
+	case f := <-e.ch:
+		return f, nil
+	case <-e.closed:
 		return Frame{}, ErrClosed
 	}
-	return f, nil
 }
 
 // Close detaches endpoint from bus and closes its channel.
@@ -118,8 +581,9 @@ func (e *loopEndpoint) closeNoLock() {
 		return
 	}
 	e.dead = true
+	// e.ch is left open: senders may still be selecting on it, and closing
+	// it would race with them. Receivers observe closure via e.closed.
 	close(e.closed)
-	close(e.ch)
 	if e.bus.endpoints != nil {
 		delete(e.bus.endpoints, e)
 	}
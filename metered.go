@@ -0,0 +1,66 @@
+package canbus
+
+import "sync/atomic"
+
+// BusMetrics holds counters maintained by a bus returned from
+// NewMeteredBus. All fields are updated atomically and may be read at any
+// time, e.g. by a Prometheus collector, while the bus is in use.
+type BusMetrics struct {
+	FramesSent     atomic.Uint64
+	FramesReceived atomic.Uint64
+	BytesSent      atomic.Uint64 // data bytes (Len) of sent frames
+	BytesReceived  atomic.Uint64 // data bytes (Len) of received frames
+	SendErrors     atomic.Uint64
+	ReceiveErrors  atomic.Uint64 // including the error returned after Close
+
+	// ByFunction counts sent and received frames by the high four bits of
+	// the 11-bit identifier (ID bits 10..7), which is the CANopen function
+	// code. Extended frames are counted by bits 10..7 of their ID too,
+	// matching CANopen's 29-bit layout that keeps the standard COB-ID in the
+	// low 11 bits.
+	ByFunction [16]atomic.Uint64
+}
+
+// FunctionBucket returns the ByFunction index for a frame.
+func FunctionBucket(f Frame) int {
+	return int(f.ID&0x7FF) >> 7
+}
+
+// NewMeteredBus wraps inner and counts frames, bytes and errors in the
+// returned BusMetrics. Counting costs a few atomic adds per frame.
+func NewMeteredBus(inner Bus) (Bus, *BusMetrics) {
+	m := &BusMetrics{}
+	return &meteredBus{inner: inner, m: m}, m
+}
+
+type meteredBus struct {
+	inner Bus
+	m     *BusMetrics
+}
+
+func (b *meteredBus) Send(frame Frame) error {
+	if err := b.inner.Send(frame); err != nil {
+		b.m.SendErrors.Add(1)
+		return err
+	}
+	b.m.FramesSent.Add(1)
+	b.m.BytesSent.Add(uint64(frame.Len))
+	b.m.ByFunction[FunctionBucket(frame)].Add(1)
+	return nil
+}
+
+func (b *meteredBus) Receive() (Frame, error) {
+	f, err := b.inner.Receive()
+	if err != nil {
+		b.m.ReceiveErrors.Add(1)
+		return f, err
+	}
+	b.m.FramesReceived.Add(1)
+	b.m.BytesReceived.Add(uint64(f.Len))
+	b.m.ByFunction[FunctionBucket(f)].Add(1)
+	return f, nil
+}
+
+func (b *meteredBus) Close() error {
+	return b.inner.Close()
+}
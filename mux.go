@@ -1,7 +1,13 @@
 package canbus
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // FrameFilter decides whether a frame should be delivered to a subscriber.
@@ -14,73 +20,513 @@ type FrameFilter func(Frame) bool
 // having multiple goroutines competing to Receive and enables non-blocking,
 // filtered consumption for higher-level protocols like CANopen SDO.
 //
-// Send is not proxied; callers should keep using the original Bus to Send.
+// The reader dispatches from an immutable snapshot of the subscriber list
+// that Subscribe and cancel replace copy-on-write, so the per-frame path
+// takes no mux lock regardless of how many subscribers there are or how
+// often they change.
+//
+// Send may go directly to the original Bus or through Mux.Send, which
+// applies any interceptors configured with WithSendInterceptors.
 type Mux struct {
-	bus   Bus
-	stop  chan struct{}
+	bus      Bus
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu     sync.RWMutex
+	subs   map[uint64]*subscriber
+	next   uint64
+	closed bool  // set once subscribers are torn down; guarded by mu
+	err    error // reason the mux closed; guarded by mu
+
+	// snap is the subscriber list read by the dispatch loop. It is replaced
+	// under mu whenever subs changes and never modified once published.
+	snap atomic.Pointer[[]*subscriber]
+
+	stats   *frameStats // nil unless WithStats is used
+	dropped atomic.Uint64
+
+	outboxLimit int // per-subscriber SubscribeBlocking queue bound
+
+	intercept []SendInterceptor // fixed at construction
+}
+
+// ErrMuxClosed is reported by Mux.Err once the mux has stopped delivering
+// frames, either because Close was called or because the underlying Bus
+// failed. In the latter case the Bus error is wrapped as well.
+var ErrMuxClosed = errors.New("canbus: mux closed")
+
+// MuxOption configures a Mux during construction.
+type MuxOption func(*Mux)
+
+// WithStats enables introspection: the mux records per-identifier frame
+// counts and the last frame observed, retrievable via Snapshot. At most
+// maxIDs distinct identifiers are tracked; frames with further identifiers
+// are not recorded. Disabled by default to avoid the per-frame overhead.
+func WithStats(maxIDs int) MuxOption {
+	return func(m *Mux) { m.stats = newFrameStats(maxIDs) }
+}
 
-	mu    sync.RWMutex
-	subs  map[uint64]*subscriber
-	next  uint64
+// DefaultOutboxLimit is the number of frames a SubscribeBlocking subscriber
+// may have queued before further frames are dropped, unless changed with
+// WithOutboxLimit.
+const DefaultOutboxLimit = 1024
+
+// WithOutboxLimit bounds the queue of each SubscribeBlocking subscriber to
+// n frames; n < 1 is treated as 1. Frames arriving while the queue is full
+// are dropped and counted by TotalDropped.
+func WithOutboxLimit(n int) MuxOption {
+	return func(m *Mux) {
+		if n < 1 {
+			n = 1
+		}
+		m.outboxLimit = n
+	}
+}
+
+// SendInterceptor inspects an outgoing frame before Mux.Send transmits it.
+// It returns the frame to send, possibly modified, and false to drop it.
+type SendInterceptor func(Frame) (Frame, bool)
+
+// WithSendInterceptors installs interceptors applied in order by Mux.Send.
+// They run in the sending goroutine without holding any mux lock, so they
+// may block (e.g. to throttle) without stalling receive fan-out.
+func WithSendInterceptors(fns ...SendInterceptor) MuxOption {
+	return func(m *Mux) { m.intercept = append(m.intercept, fns...) }
+}
+
+// Send passes frame through the configured interceptors and forwards the
+// result to the underlying Bus. A frame dropped by an interceptor is not
+// sent and Send returns nil, as a Bus that silently discards it would.
+func (m *Mux) Send(frame Frame) error {
+	for _, fn := range m.intercept {
+		var ok bool
+		if frame, ok = fn(frame); !ok {
+			return nil
+		}
+	}
+	return m.bus.Send(frame)
+}
+
+// FrameStat summarizes the frames observed by a Mux for one identifier.
+type FrameStat struct {
+	Count    uint64    // number of frames received
+	Last     Frame     // most recently received frame
+	LastSeen time.Time // receive time of Last
 }
 
 type subscriber struct {
-	filter FrameFilter
-	ch     chan Frame
+	id      uint64
+	filter  atomic.Pointer[FrameFilter] // nil matches everything
+	ch      chan Frame
+	out     *outbox // nil unless subscribed with SubscribeBlocking
+	dropped atomic.Uint64
+	state   atomic.Int32 // subIdle, subSending or subClosed
+}
+
+// Subscriber states. The dispatch loop moves a subscriber from idle to
+// sending around each channel send, and close only closes the channel from
+// idle, so a send never races with close.
+const (
+	subIdle int32 = iota
+	subSending
+	subClosed
+)
+
+func (s *subscriber) match(f Frame) bool {
+	fp := s.filter.Load()
+	return fp == nil || *fp == nil || (*fp)(f)
+}
+
+func (s *subscriber) setFilter(filter FrameFilter) {
+	s.filter.Store(&filter)
+}
+
+// deliver offers f to s without blocking and reports whether it was
+// dropped because the channel, or for a blocking subscriber its outbox, was
+// full.
+func (s *subscriber) deliver(f Frame) (dropped bool) {
+	if s.out != nil {
+		return !s.out.push(f)
+	}
+	if !s.state.CompareAndSwap(subIdle, subSending) {
+		return false // closed
+	}
+	select {
+	case s.ch <- f:
+	default:
+		dropped = true
+	}
+	s.state.Store(subIdle)
+	return dropped
+}
+
+// close ends delivery to s. Blocking subscribers are closed by their
+// forwarding goroutine, which may be sending on ch. Otherwise close waits
+// out an in-flight non-blocking send before closing ch.
+func (s *subscriber) close() {
+	if s.out != nil {
+		s.out.close()
+		return
+	}
+	for !s.state.CompareAndSwap(subIdle, subClosed) {
+		if s.state.Load() == subClosed {
+			return
+		}
+		runtime.Gosched()
+	}
+	close(s.ch)
+}
+
+// publish replaces the dispatch snapshot with the current subscribers,
+// dropping removed (if non-nil) and appending added (if non-nil). It must be
+// called with mu held; the old snapshot is never modified, so the reader may
+// still be iterating it.
+func (m *Mux) publish(added, removed *subscriber) {
+	var old []*subscriber
+	if p := m.snap.Load(); p != nil {
+		old = *p
+	}
+	list := make([]*subscriber, 0, len(old)+1)
+	for _, s := range old {
+		if s != removed {
+			list = append(list, s)
+		}
+	}
+	if added != nil {
+		list = append(list, added)
+	}
+	m.snap.Store(&list)
+}
+
+// outbox queues frames for a blocking subscriber. The mux appends without
+// waiting and a per-subscriber goroutine forwards them to the channel, so a
+// slow subscriber delays only itself. At most limit frames are pending,
+// counting those the forwarder has taken but not yet delivered.
+type outbox struct {
+	mu      sync.Mutex
+	queue   []Frame
+	pending int
+	limit   int
+	wake    chan struct{}
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func newOutbox(limit int) *outbox {
+	return &outbox{limit: limit, wake: make(chan struct{}, 1), stop: make(chan struct{})}
+}
+
+// push queues f and reports whether there was room for it.
+func (o *outbox) push(f Frame) bool {
+	o.mu.Lock()
+	if o.pending >= o.limit {
+		o.mu.Unlock()
+		return false
+	}
+	o.queue = append(o.queue, f)
+	o.pending++
+	o.mu.Unlock()
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (o *outbox) close() {
+	o.once.Do(func() { close(o.stop) })
+}
+
+// forward delivers queued frames to ch in order until the outbox is closed,
+// then closes ch. Frames still queued at that point are discarded.
+func (o *outbox) forward(ch chan Frame) {
+	defer close(ch)
+	for {
+		o.mu.Lock()
+		q := o.queue
+		o.queue = nil
+		o.mu.Unlock()
+		for _, f := range q {
+			select {
+			case ch <- f:
+			case <-o.stop:
+				return
+			}
+			o.mu.Lock()
+			o.pending--
+			o.mu.Unlock()
+		}
+		select {
+		case <-o.wake:
+		case <-o.stop:
+			return
+		}
+	}
 }
 
 // NewMux creates and starts a multiplexer bound to the given Bus.
-func NewMux(bus Bus) *Mux {
+func NewMux(bus Bus, opts ...MuxOption) *Mux {
 	m := &Mux{
-		bus:  bus,
-		stop: make(chan struct{}),
-		subs: make(map[uint64]*subscriber),
+		bus:         bus,
+		stop:        make(chan struct{}),
+		subs:        make(map[uint64]*subscriber),
+		outboxLimit: DefaultOutboxLimit,
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
 	go m.run()
 	return m
 }
 
 // Close stops the background reader and closes all subscriber channels.
+// It is idempotent and safe to call concurrently with closing the
+// underlying Bus. The reader stops delivering before subscribers are
+// closed, so no frame is sent on a closed subscriber channel.
 func (m *Mux) Close() error {
-	select {
-	case <-m.stop:
-		return nil
-	default:
-	}
-	close(m.stop)
-	// Best-effort drain/close of subscribers
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.closeSubscribers(ErrMuxClosed)
+	return nil
+}
+
+// Err returns nil while the mux is running. Once subscriber channels have
+// been closed it returns an error wrapping ErrMuxClosed that explains why.
+func (m *Mux) Err() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.err
+}
+
+// closeSubscribers marks the mux closed with reason err and closes all
+// subscriber channels.
+func (m *Mux) closeSubscribers(err error) {
 	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	m.closed = true
+	m.err = err
 	for id, s := range m.subs {
-		close(s.ch)
+		s.close()
 		delete(m.subs, id)
 	}
-	m.mu.Unlock()
-	return nil
+	m.snap.Store(new([]*subscriber))
 }
 
 // Subscribe registers a new subscriber with the provided filter and channel buffer.
 // The returned channel will receive frames that match the filter. The cancel
 // function should be called when no longer needed; it will close the channel.
+//
+// Subscribing to a closed mux returns an already-closed channel and a no-op
+// cancel; Err reports why the mux closed.
 func (m *Mux) Subscribe(filter FrameFilter, buffer int) (<-chan Frame, func()) {
+	return m.subscribe(filter, buffer, false)
+}
+
+// SubscribeBlocking is like Subscribe, but matching frames are not dropped
+// when the channel is full. Instead they queue in a per-subscriber outbox
+// drained by a dedicated goroutine, so a slow subscriber does not stall the
+// mux or other subscribers. Use it where a missed frame is costly, such as
+// waiting for an SDO response. The outbox holds at most DefaultOutboxLimit
+// frames (see WithOutboxLimit); once a subscriber that stops reading fills
+// it, further frames for that subscriber are dropped and counted by
+// TotalDropped.
+//
+// Cancel and Close discard frames still queued; the channel is closed by
+// the forwarding goroutine shortly after.
+func (m *Mux) SubscribeBlocking(filter FrameFilter, buffer int) (<-chan Frame, func()) {
+	return m.subscribe(filter, buffer, true)
+}
+
+// Subscription is a subscription handle returned by SubscribeWithStats. Its
+// filter can be replaced with Mux.UpdateFilter.
+type Subscription struct {
+	C      <-chan Frame // matching frames; closed on Cancel or mux close
+	Cancel func()       // ends the subscription and closes C
+
+	s *subscriber
+}
+
+// DroppedFrames returns how many matching frames were dropped because C
+// was full.
+func (s *Subscription) DroppedFrames() uint64 {
+	return s.s.dropped.Load()
+}
+
+// SubscribeWithStats is like Subscribe but returns a handle that also
+// reports how many frames were dropped for this subscriber and whose filter
+// can be changed with UpdateFilter.
+func (m *Mux) SubscribeWithStats(filter FrameFilter, buffer int) *Subscription {
+	s, cancel := m.subscribeSub(filter, buffer, false)
+	return &Subscription{C: s.ch, Cancel: cancel, s: s}
+}
+
+// UpdateFilter replaces the filter of an active subscription without
+// resubscribing, so no frames are missed during the change. The swap is
+// atomic: every frame is tested against either the old or the new filter.
+// Subscriptions that were cancelled or belong to another mux are left
+// unchanged.
+func (m *Mux) UpdateFilter(sub *Subscription, filter FrameFilter) {
+	m.mu.Lock()
+	if cur, ok := m.subs[sub.s.id]; ok && cur == sub.s {
+		cur.setFilter(filter)
+	}
+	m.mu.Unlock()
+}
+
+// TotalDropped returns how many frames the mux has dropped across all
+// subscribers, past and present, because their channels or, for
+// SubscribeBlocking, their outboxes were full.
+func (m *Mux) TotalDropped() uint64 {
+	return m.dropped.Load()
+}
+
+func (m *Mux) subscribe(filter FrameFilter, buffer int, blocking bool) (<-chan Frame, func()) {
+	s, cancel := m.subscribeSub(filter, buffer, blocking)
+	return s.ch, cancel
+}
+
+func (m *Mux) subscribeSub(filter FrameFilter, buffer int, blocking bool) (*subscriber, func()) {
 	if buffer < 0 {
 		buffer = 0
 	}
-	s := &subscriber{filter: filter, ch: make(chan Frame, buffer)}
+	s := &subscriber{ch: make(chan Frame, buffer)}
+	s.setFilter(filter)
+	if blocking {
+		s.out = newOutbox(m.outboxLimit)
+	}
 	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		close(s.ch)
+		return s, func() {}
+	}
 	id := m.next
 	m.next++
+	s.id = id
 	m.subs[id] = s
+	m.publish(s, nil)
 	m.mu.Unlock()
+	if s.out != nil {
+		go s.out.forward(s.ch)
+	}
 
 	cancel := func() {
 		m.mu.Lock()
 		if cur, ok := m.subs[id]; ok && cur == s {
-			close(cur.ch)
+			cur.close()
 			delete(m.subs, id)
+			m.publish(nil, cur)
 		}
 		m.mu.Unlock()
 	}
-	return s.ch, cancel
+	return s, cancel
+}
+
+// SubscribeOnce waits for the first frame matching filter and returns it,
+// cancelling the subscription before returning. It returns ctx.Err() if ctx
+// is done first, or an error wrapping ErrClosed (and the reason reported by
+// Err) if the mux closes.
+//
+// The subscription starts when SubscribeOnce is called, so it cannot catch
+// the reply to a request sent beforehand; for request/response exchanges
+// subscribe with Subscribe first, then send, then wait.
+func (m *Mux) SubscribeOnce(ctx context.Context, filter FrameFilter) (Frame, error) {
+	ch, cancel := m.Subscribe(filter, 1)
+	defer cancel()
+	select {
+	case f, ok := <-ch:
+		if !ok {
+			if err := m.Err(); err != nil {
+				return Frame{}, fmt.Errorf("%w: %w", ErrClosed, err)
+			}
+			return Frame{}, ErrClosed
+		}
+		return f, nil
+	case <-ctx.Done():
+		return Frame{}, ctx.Err()
+	}
+}
+
+// SubscriptionGroup tracks subscriptions made on a shared Mux so that a
+// component can cancel all of its own subscriptions on shutdown while the
+// mux keeps running for others. It is safe for concurrent use.
+type SubscriptionGroup struct {
+	mux *Mux
+
+	mu      sync.Mutex
+	cancels map[uint64]func()
+	next    uint64
+}
+
+// NewSubscriptionGroup returns an empty group subscribing on m.
+func NewSubscriptionGroup(m *Mux) *SubscriptionGroup {
+	return &SubscriptionGroup{mux: m}
+}
+
+// Subscribe calls Mux.Subscribe and tracks the subscription. The returned
+// cancel also removes it from the group.
+func (g *SubscriptionGroup) Subscribe(filter FrameFilter, buffer int) (<-chan Frame, func()) {
+	ch, cancel := g.mux.Subscribe(filter, buffer)
+	return ch, g.Add(cancel)
+}
+
+// SubscribeBlocking calls Mux.SubscribeBlocking and tracks the subscription.
+// The returned cancel also removes it from the group.
+func (g *SubscriptionGroup) SubscribeBlocking(filter FrameFilter, buffer int) (<-chan Frame, func()) {
+	ch, cancel := g.mux.SubscribeBlocking(filter, buffer)
+	return ch, g.Add(cancel)
+}
+
+// Add tracks a cancel function obtained elsewhere, e.g. from a helper that
+// subscribes on the group's mux, and returns a cancel that calls it and
+// stops tracking it. Cancel functions must be idempotent, as those returned
+// by Subscribe are.
+func (g *SubscriptionGroup) Add(cancel func()) func() {
+	g.mu.Lock()
+	if g.cancels == nil {
+		g.cancels = make(map[uint64]func())
+	}
+	id := g.next
+	g.next++
+	g.cancels[id] = cancel
+	g.mu.Unlock()
+	return func() {
+		g.mu.Lock()
+		delete(g.cancels, id)
+		g.mu.Unlock()
+		cancel()
+	}
+}
+
+// Len returns the number of subscriptions the group tracks.
+func (g *SubscriptionGroup) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.cancels)
+}
+
+// CancelAll cancels every tracked subscription and empties the group, which
+// may then be reused.
+func (g *SubscriptionGroup) CancelAll() {
+	g.mu.Lock()
+	cancels := g.cancels
+	g.cancels = nil
+	g.mu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
+
+// Snapshot returns per-identifier statistics keyed by frame ID. It returns
+// nil unless the mux was created with WithStats. Standard and extended frames
+// sharing the same numeric ID are counted together.
+func (m *Mux) Snapshot() map[uint32]FrameStat {
+	if m.stats == nil {
+		return nil
+	}
+	return m.stats.snapshot()
 }
 
 func (m *Mux) run() {
@@ -93,25 +539,91 @@ func (m *Mux) run() {
 		f, err := m.bus.Receive()
 		if err != nil {
 			// On error, propagate closure to subscribers and exit.
-			m.mu.Lock()
-			for id, s := range m.subs {
-				close(s.ch)
-				delete(m.subs, id)
-			}
-			m.mu.Unlock()
+			m.closeSubscribers(fmt.Errorf("%w: %w", ErrMuxClosed, err))
 			return
 		}
-		m.mu.RLock()
-		for _, s := range m.subs {
-			if s.filter == nil || s.filter(f) {
-				select {
-				case s.ch <- f:
-				default:
-					// Drop if subscriber is slow and channel is full.
-				}
-			}
+		if m.stats != nil {
+			m.stats.record(f)
+		}
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+		m.dispatch(f)
+	}
+}
+
+// dispatch fans f out to the current subscriber snapshot without locking.
+func (m *Mux) dispatch(f Frame) {
+	snap := m.snap.Load()
+	if snap == nil {
+		return
+	}
+	for _, s := range *snap {
+		if s.match(f) && s.deliver(f) {
+			// Dropped: the subscriber is slow and its channel is full.
+			s.dropped.Add(1)
+			m.dropped.Add(1)
 		}
-		m.mu.RUnlock()
 	}
 }
 
+// frameStats tracks per-identifier counters. It is written only by the mux
+// reader goroutine and read concurrently by Snapshot: the identifier table is
+// replaced copy-on-write when a new identifier appears and entries are
+// updated atomically, so neither side takes a lock.
+type frameStats struct {
+	max   int
+	table atomic.Pointer[map[uint32]*statEntry]
+}
+
+type statEntry struct {
+	count atomic.Uint64
+	last  atomic.Pointer[statSample]
+}
+
+type statSample struct {
+	frame Frame
+	at    time.Time
+}
+
+func newFrameStats(max int) *frameStats {
+	s := &frameStats{max: max}
+	t := make(map[uint32]*statEntry)
+	s.table.Store(&t)
+	return s
+}
+
+func (s *frameStats) record(f Frame) {
+	t := *s.table.Load()
+	e, ok := t[f.ID]
+	if !ok {
+		if len(t) >= s.max {
+			return
+		}
+		next := make(map[uint32]*statEntry, len(t)+1)
+		for id, v := range t {
+			next[id] = v
+		}
+		e = &statEntry{}
+		next[f.ID] = e
+		s.table.Store(&next)
+	}
+	e.last.Store(&statSample{frame: f, at: time.Now()})
+	e.count.Add(1)
+}
+
+func (s *frameStats) snapshot() map[uint32]FrameStat {
+	t := *s.table.Load()
+	out := make(map[uint32]FrameStat, len(t))
+	for id, e := range t {
+		st := FrameStat{Count: e.count.Load()}
+		if last := e.last.Load(); last != nil {
+			st.Last = last.frame
+			st.LastSeen = last.at
+		}
+		out[id] = st
+	}
+	return out
+}
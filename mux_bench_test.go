@@ -0,0 +1,70 @@
+package canbus
+
+import "testing"
+
+// benchBus feeds n copies of frame followed by last, then blocks until
+// closed. Receive is only called from the mux goroutine.
+type benchBus struct {
+	n           int
+	frame, last Frame
+	sentLast    bool
+	done        chan struct{}
+}
+
+func (b *benchBus) Send(Frame) error { return nil }
+
+func (b *benchBus) Receive() (Frame, error) {
+	if b.n > 0 {
+		b.n--
+		return b.frame, nil
+	}
+	if !b.sentLast {
+		b.sentLast = true
+		return b.last, nil
+	}
+	<-b.done
+	return Frame{}, ErrClosed
+}
+
+func (b *benchBus) Close() error { return nil }
+
+// BenchmarkMuxFanout measures dispatch of one frame to 100 subscribers that
+// each filter on their own identifier, as CANopen per-node consumers do. The
+// churn variant subscribes and cancels concurrently, as SDO transfers do.
+func BenchmarkMuxFanout(b *testing.B) {
+	b.Run("static", func(b *testing.B) { benchmarkMuxFanout(b, false) })
+	b.Run("churn", func(b *testing.B) { benchmarkMuxFanout(b, true) })
+}
+
+func benchmarkMuxFanout(b *testing.B, churn bool) {
+	bus := &benchBus{n: b.N, frame: MustFrame(0x180, []byte{1}), last: MustFrame(0x7FF, nil), done: make(chan struct{})}
+	defer close(bus.done)
+	m := &Mux{bus: bus, stop: make(chan struct{}), subs: make(map[uint64]*subscriber)}
+	for i := 0; i < 100; i++ {
+		id := uint32(0x180 + i)
+		_, cancel := m.Subscribe(func(f Frame) bool { return f.ID == id }, 1)
+		defer cancel()
+	}
+	last, cancel := m.Subscribe(func(f Frame) bool { return f.ID == 0x7FF }, 1)
+	defer cancel()
+	stop := make(chan struct{})
+	if churn {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_, cancel := m.Subscribe(func(f Frame) bool { return f.ID == 0x581 }, 1)
+				cancel()
+			}
+		}()
+	}
+	b.ResetTimer()
+	go m.run()
+	<-last
+	b.StopTimer()
+	close(stop)
+	m.Close()
+}
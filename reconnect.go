@@ -0,0 +1,193 @@
+package canbus
+
+import (
+	"sync"
+	"time"
+)
+
+// BackoffPolicy controls the delay between reconnect attempts: the first
+// retry waits Initial and each further one waits Multiplier times longer, up
+// to Max. The zero value retries after 100ms, 200ms, 400ms, ... up to 10s.
+type BackoffPolicy struct {
+	Initial    time.Duration // first delay; zero means 100ms
+	Max        time.Duration // upper bound; zero means 10s
+	Multiplier float64       // growth per attempt; values <= 1 mean 2
+}
+
+// Delay returns the wait before retry number attempt (starting at 0).
+func (p BackoffPolicy) Delay(attempt int) time.Duration {
+	d, max, mult := p.Initial, p.Max, p.Multiplier
+	if d <= 0 {
+		d = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 10 * time.Second
+	}
+	if mult <= 1 {
+		mult = 2
+	}
+	for i := 0; i < attempt && d < max; i++ {
+		d = time.Duration(float64(d) * mult)
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// NewReconnectingBus returns a Bus that obtains its connection from dial and
+// replaces it whenever Send or Receive fails, e.g. after a USB adapter is
+// unplugged and plugged back in. The first connection is dialed on first
+// use. Failed dials are retried with backoff until one succeeds or the bus
+// is closed.
+//
+// Send re-sends the frame on the new connection, so a frame may reach the
+// bus twice if the old connection failed after transmitting it. Invalid
+// frames are rejected without reconnecting. Errors from the underlying bus
+// are never returned: Send and Receive block until they succeed, and return
+// ErrClosed only after Close.
+func NewReconnectingBus(dial func() (Bus, error), backoff BackoffPolicy) Bus {
+	return &reconnectingBus{dial: dial, backoff: backoff, done: make(chan struct{})}
+}
+
+type reconnectingBus struct {
+	dial    func() (Bus, error)
+	backoff BackoffPolicy
+
+	dialMu sync.Mutex // serializes dialing
+
+	mu     sync.Mutex
+	inner  Bus
+	gen    uint64 // incremented for every connection
+	fails  int    // consecutive failures, drives backoff
+	closed bool
+	done   chan struct{}
+}
+
+// conn returns the current connection, dialing a new one if needed.
+func (r *reconnectingBus) conn() (Bus, uint64, error) {
+	if b, gen, ok, err := r.current(); ok || err != nil {
+		return b, gen, err
+	}
+	r.dialMu.Lock()
+	defer r.dialMu.Unlock()
+	for {
+		b, gen, ok, err := r.current()
+		if ok || err != nil {
+			return b, gen, err
+		}
+		r.mu.Lock()
+		fails := r.fails
+		r.mu.Unlock()
+		if fails > 0 {
+			t := time.NewTimer(r.backoff.Delay(fails - 1))
+			select {
+			case <-r.done:
+				t.Stop()
+				return nil, 0, ErrClosed
+			case <-t.C:
+			}
+		}
+		nb, err := r.dial()
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			if err == nil {
+				_ = nb.Close()
+			}
+			return nil, 0, ErrClosed
+		}
+		if err != nil {
+			r.fails++
+			r.mu.Unlock()
+			continue
+		}
+		r.inner = nb
+		r.gen++
+		gen = r.gen
+		r.mu.Unlock()
+		return nb, gen, nil
+	}
+}
+
+// current returns the live connection, if any.
+func (r *reconnectingBus) current() (Bus, uint64, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, 0, false, ErrClosed
+	}
+	return r.inner, r.gen, r.inner != nil, nil
+}
+
+// fail discards connection gen after an error so the next call re-dials.
+func (r *reconnectingBus) fail(gen uint64) {
+	r.mu.Lock()
+	if r.closed || r.gen != gen || r.inner == nil {
+		r.mu.Unlock()
+		return
+	}
+	b := r.inner
+	r.inner = nil
+	r.fails++
+	r.mu.Unlock()
+	_ = b.Close()
+}
+
+func (r *reconnectingBus) ok() {
+	r.mu.Lock()
+	r.fails = 0
+	r.mu.Unlock()
+}
+
+// Send transmits frame, reconnecting and retrying until it succeeds.
+func (r *reconnectingBus) Send(frame Frame) error {
+	if err := frame.Validate(); err != nil {
+		return err
+	}
+	for {
+		b, gen, err := r.conn()
+		if err != nil {
+			return err
+		}
+		if err := b.Send(frame); err == nil {
+			r.ok()
+			return nil
+		}
+		r.fail(gen)
+	}
+}
+
+// Receive returns the next frame, reconnecting after errors.
+func (r *reconnectingBus) Receive() (Frame, error) {
+	for {
+		b, gen, err := r.conn()
+		if err != nil {
+			return Frame{}, err
+		}
+		f, err := b.Receive()
+		if err == nil {
+			r.ok()
+			return f, nil
+		}
+		r.fail(gen)
+	}
+}
+
+// Close closes the current connection and stops reconnecting.
+func (r *reconnectingBus) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+	b := r.inner
+	r.inner = nil
+	r.mu.Unlock()
+	if b != nil {
+		return b.Close()
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package canbus
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+// Interface names written by NewRecordingBus to mark frame direction.
+const (
+	RecordTx = "tx"
+	RecordRx = "rx"
+)
+
+// NewRecordingBus wraps inner and appends every successfully sent and
+// received frame to w as a candump log line (see AppendCandump). The
+// interface column records the direction, RecordTx or RecordRx, so logs can
+// be filtered or mapped to a real interface with canplayer (e.g. can0=rx).
+// Frames are stamped with Frame.Timestamp when set and the current time
+// otherwise; a sent frame is stamped before it is forwarded.
+//
+// Lines are written in the order frames were handed to Send or returned by
+// Receive, so a reply read concurrently is never logged before its request.
+// Lines received while a send is in flight are held until it completes.
+//
+// Write errors never affect traffic; the first one is returned by Close.
+func NewRecordingBus(inner Bus, w io.Writer) Bus {
+	return &recordingBus{inner: inner, w: w}
+}
+
+type recordingBus struct {
+	inner Bus
+
+	mu      sync.Mutex
+	w       io.Writer
+	err     error
+	pending []recordLine // lines not yet written, oldest first
+	base    uint64       // sequence number of pending[0]
+}
+
+// recordLine is a log line awaiting its turn. A tx line is not done until
+// its send returns; a failed send leaves an empty line.
+type recordLine struct {
+	line string
+	done bool
+}
+
+func recordLineFor(dir string, f Frame) string {
+	ts := f.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return f.AppendCandump(dir, ts) + "\n"
+}
+
+// enqueue appends l and returns its sequence number. It must be called with
+// mu held.
+func (r *recordingBus) enqueue(l recordLine) uint64 {
+	r.pending = append(r.pending, l)
+	return r.base + uint64(len(r.pending)-1)
+}
+
+// flush writes the completed lines at the head of pending. It must be called
+// with mu held.
+func (r *recordingBus) flush() {
+	n := 0
+	for ; n < len(r.pending) && r.pending[n].done; n++ {
+		if r.err == nil && r.pending[n].line != "" {
+			_, r.err = io.WriteString(r.w, r.pending[n].line)
+		}
+	}
+	r.pending = append(r.pending[:0], r.pending[n:]...)
+	r.base += uint64(n)
+}
+
+func (r *recordingBus) Send(frame Frame) error {
+	line := recordLineFor(RecordTx, frame)
+	r.mu.Lock()
+	seq := r.enqueue(recordLine{line: line})
+	r.mu.Unlock()
+
+	err := r.inner.Send(frame)
+
+	r.mu.Lock()
+	l := &r.pending[seq-r.base]
+	l.done = true
+	if err != nil {
+		l.line = ""
+	}
+	r.flush()
+	r.mu.Unlock()
+	return err
+}
+
+func (r *recordingBus) Receive() (Frame, error) {
+	f, err := r.inner.Receive()
+	if err != nil {
+		return f, err
+	}
+	line := recordLineFor(RecordRx, f)
+	r.mu.Lock()
+	r.enqueue(recordLine{line: line, done: true})
+	r.flush()
+	r.mu.Unlock()
+	return f, nil
+}
+
+// Close closes the inner bus and reports the first write error, if any.
+func (r *recordingBus) Close() error {
+	err := r.inner.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return err
+}
+
+// NewReplayBus reads a candump log (as written by NewRecordingBus or
+// candump -l) and returns a Bus whose Receive yields its frames in order,
+// with Frame.Timestamp set from the log. Frames recorded as sent (RecordTx)
+// are skipped. When realtime is true, Receive waits so that frames are
+// spaced as their timestamps were; otherwise they are returned immediately.
+// After the last frame Receive returns io.EOF. Send discards frames.
+//
+// The whole log is parsed up front and a malformed line is reported as an
+// error. To check an application's sends against a script instead, see
+// ReplayBus.
+func NewReplayBus(r io.Reader, realtime bool) (Bus, error) {
+	var frames []Frame
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if len(line) == 0 {
+			continue
+		}
+		iface, ts, f, err := ParseCandumpLine(line)
+		if err != nil {
+			return nil, err
+		}
+		if iface == RecordTx {
+			continue
+		}
+		f.Timestamp = ts
+		frames = append(frames, f)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return &fileReplayBus{frames: frames, realtime: realtime, done: make(chan struct{})}, nil
+}
+
+type fileReplayBus struct {
+	realtime bool
+	done     chan struct{}
+
+	mu     sync.Mutex
+	frames []Frame
+	pos    int
+	start  time.Time // wall time the first frame was returned
+	closed bool
+}
+
+func (b *fileReplayBus) Send(Frame) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (b *fileReplayBus) Receive() (Frame, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return Frame{}, ErrClosed
+	}
+	if b.pos >= len(b.frames) {
+		b.mu.Unlock()
+		return Frame{}, io.EOF
+	}
+	f := b.frames[b.pos]
+	b.pos++
+	var wait time.Duration
+	if b.realtime {
+		if b.start.IsZero() {
+			b.start = time.Now()
+		} else if first := b.frames[0].Timestamp; !first.IsZero() && !f.Timestamp.IsZero() {
+			wait = time.Until(b.start.Add(f.Timestamp.Sub(first)))
+		}
+	}
+	b.mu.Unlock()
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		select {
+		case <-b.done:
+			t.Stop()
+			return Frame{}, ErrClosed
+		case <-t.C:
+		}
+	}
+	return f, nil
+}
+
+func (b *fileReplayBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package canbus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// EventKind distinguishes sent and received frames in a session script.
+type EventKind uint8
+
+const (
+	EventSend EventKind = iota
+	EventReceive
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventSend:
+		return "send"
+	case EventReceive:
+		return "receive"
+	default:
+		return fmt.Sprintf("EventKind(%d)", uint8(k))
+	}
+}
+
+// Event is one step of a recorded session.
+type Event struct {
+	Kind  EventKind
+	Frame Frame
+}
+
+// ErrReplayMismatch is returned when a replayed session diverges from its
+// script.
+var ErrReplayMismatch = errors.New("canbus: replay mismatch")
+
+// SessionBus is a Bus decorator that records every successful Send and
+// Receive, in order, as a script that can later be replayed with ReplayBus.
+type SessionBus struct {
+	inner Bus
+
+	mu     sync.Mutex
+	events []sessionEvent
+}
+
+// sessionEvent is a recorded event; failed marks a send the inner Bus
+// rejected after it was recorded.
+type sessionEvent struct {
+	Event
+	failed bool
+}
+
+// NewSessionBus wraps inner and starts recording.
+func NewSessionBus(inner Bus) *SessionBus {
+	return &SessionBus{inner: inner}
+}
+
+// Send forwards to the inner Bus and records the frame on success. The send
+// is recorded before it is forwarded, so a reply received concurrently (e.g.
+// by a Mux reading this bus) is always recorded after it; a send that fails
+// is then left out of the script.
+func (s *SessionBus) Send(frame Frame) error {
+	i := s.record(Event{Kind: EventSend, Frame: frame})
+	if err := s.inner.Send(frame); err != nil {
+		s.mu.Lock()
+		s.events[i].failed = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Receive forwards to the inner Bus and records the frame on success.
+func (s *SessionBus) Receive() (Frame, error) {
+	f, err := s.inner.Receive()
+	if err != nil {
+		return f, err
+	}
+	s.record(Event{Kind: EventReceive, Frame: f})
+	return f, nil
+}
+
+// Close closes the inner Bus. The script remains available.
+func (s *SessionBus) Close() error {
+	return s.inner.Close()
+}
+
+// Script returns a copy of the events recorded so far.
+func (s *SessionBus) Script() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Event, 0, len(s.events))
+	for _, e := range s.events {
+		if !e.failed {
+			out = append(out, e.Event)
+		}
+	}
+	return out
+}
+
+// record appends e and returns its index.
+func (s *SessionBus) record(e Event) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, sessionEvent{Event: e})
+	return len(s.events) - 1
+}
+
+// Replay is a Bus that deterministically replays a recorded script.
+//
+// Receive returns the script's received frames in order, waiting until all
+// sends scripted before them have occurred. Send checks each frame against
+// the next scripted send and fails with ErrReplayMismatch on divergence.
+// Once the script is exhausted Receive blocks until Close.
+type Replay struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	script []Event
+	pos    int
+	err    error
+	closed bool
+}
+
+// ReplayBus creates a Replay for script.
+func ReplayBus(script []Event) *Replay {
+	r := &Replay{script: append([]Event(nil), script...)}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+// Send consumes the next scripted event, which must be a send of an
+// identical frame.
+func (r *Replay) Send(frame Frame) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if r.pos >= len(r.script) {
+		r.err = fmt.Errorf("%w: unexpected send %s after end of script", ErrReplayMismatch, frame)
+		return r.err
+	}
+	want := r.script[r.pos]
+	if want.Kind != EventSend || !want.Frame.Equal(frame) {
+		r.err = fmt.Errorf("%w: event %d: got send %s, want %s %s", ErrReplayMismatch, r.pos, frame, want.Kind, want.Frame)
+		r.cond.Broadcast()
+		return r.err
+	}
+	r.pos++
+	r.cond.Broadcast()
+	return nil
+}
+
+// Receive waits until the next scripted event is a receive and returns its
+// frame.
+func (r *Replay) Receive() (Frame, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		if r.closed {
+			return Frame{}, ErrClosed
+		}
+		if r.err != nil {
+			return Frame{}, r.err
+		}
+		if r.pos < len(r.script) && r.script[r.pos].Kind == EventReceive {
+			f := r.script[r.pos].Frame
+			r.pos++
+			r.cond.Broadcast()
+			return f, nil
+		}
+		r.cond.Wait()
+	}
+}
+
+// Close unblocks pending Receive calls.
+func (r *Replay) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.cond.Broadcast()
+	r.mu.Unlock()
+	return nil
+}
+
+// Verify returns the first mismatch, or an error if part of the script was
+// not consumed.
+func (r *Replay) Verify() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if r.pos < len(r.script) {
+		return fmt.Errorf("%w: %d of %d events not replayed", ErrReplayMismatch, len(r.script)-r.pos, len(r.script))
+	}
+	return nil
+}
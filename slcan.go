@@ -0,0 +1,98 @@
+package canbus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MarshalSLCAN encodes f as an SLCAN (Lawicel) ASCII command:
+//
+//	tiiiLdd..       standard data frame
+//	Tiiiiiiiidd..   extended data frame (with L after the id)
+//	riiiL           standard RTR frame
+//	RiiiiiiiiL      extended RTR frame
+//
+// where L is the DLC digit. The trailing carriage return that terminates
+// commands on the wire is not included. SLCAN has no error frame command,
+// so error frames fail.
+func (f Frame) MarshalSLCAN() (string, error) {
+	if err := f.Validate(); err != nil {
+		return "", err
+	}
+	if f.Error {
+		return "", fmt.Errorf("canbus: slcan cannot encode error frames")
+	}
+	var b strings.Builder
+	cmd, width := byte('t'), 3
+	if f.Extended {
+		cmd, width = 'T', 8
+	}
+	if f.RTR {
+		cmd -= 't' - 'r'
+	}
+	b.WriteByte(cmd)
+	fmt.Fprintf(&b, "%0*X%d", width, f.ID, f.Len)
+	if !f.RTR {
+		for _, d := range f.Data[:f.Len] {
+			fmt.Fprintf(&b, "%02X", d)
+		}
+	}
+	return b.String(), nil
+}
+
+// UnmarshalSLCAN decodes an SLCAN frame command produced by MarshalSLCAN or
+// an adapter. A trailing carriage return or newline is ignored. Lengths are
+// validated strictly, so commands with optional timestamps must have them
+// removed first.
+func UnmarshalSLCAN(s string) (Frame, error) {
+	s = strings.TrimRight(s, "\r\n")
+	if s == "" {
+		return Frame{}, fmt.Errorf("canbus: empty slcan command")
+	}
+	var f Frame
+	width := 3
+	switch s[0] {
+	case 't':
+	case 'T':
+		f.Extended, width = true, 8
+	case 'r':
+		f.RTR = true
+	case 'R':
+		f.Extended, f.RTR, width = true, true, 8
+	default:
+		return Frame{}, fmt.Errorf("canbus: unknown slcan command %q", s[0])
+	}
+	if len(s) < 1+width+1 {
+		return Frame{}, fmt.Errorf("canbus: slcan command %q too short", s)
+	}
+	id, err := strconv.ParseUint(s[1:1+width], 16, 32)
+	if err != nil {
+		return Frame{}, fmt.Errorf("canbus: invalid slcan id in %q", s)
+	}
+	f.ID = uint32(id)
+	dlc := s[1+width]
+	if dlc < '0' || dlc > '8' {
+		return Frame{}, fmt.Errorf("canbus: invalid slcan dlc %q", dlc)
+	}
+	f.Len = dlc - '0'
+	data := s[2+width:]
+	want := 0
+	if !f.RTR {
+		want = 2 * int(f.Len)
+	}
+	if len(data) != want {
+		return Frame{}, fmt.Errorf("canbus: slcan command %q has %d data digits, want %d", s, len(data), want)
+	}
+	for i := 0; i < want; i += 2 {
+		v, err := strconv.ParseUint(data[i:i+2], 16, 8)
+		if err != nil {
+			return Frame{}, fmt.Errorf("canbus: invalid slcan data in %q", s)
+		}
+		f.Data[i/2] = byte(v)
+	}
+	if err := f.Validate(); err != nil {
+		return Frame{}, err
+	}
+	return f, nil
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"net"
 	"os"
+	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -15,8 +17,33 @@ type socketCAN struct {
 	fd     int
 	file   *os.File
 	closed chan struct{}
+	// noBufsTimeout bounds how long Send retries on ENOBUFS; <= 0 disables.
+	noBufsTimeout time.Duration
+	// timestamps requests SO_TIMESTAMPNS control messages on receive.
+	timestamps bool
+	// filter drops received data frames in userspace when
+	// SocketCANOptions.Filter could not be installed in the kernel.
+	filter FrameFilter
+
+	// rxMu guards the receive buffer; rxBuf[rxHead:rxCount] holds frames
+	// (and their ifindexes in rxIf) read by the last batch but not yet
+	// returned, and rxErr a decode error that ended that batch early.
+	rxMu    sync.Mutex
+	rxBuf   [rxBatch]Frame
+	rxIf    [rxBatch]int32
+	rxHead  int
+	rxCount int
+	rxErr   error
+
+	// ifMu guards ifNames, the cache of interface names by index.
+	ifMu    sync.Mutex
+	ifNames map[int32]string
 }
 
+// defaultNoBufsRetryTimeout is used when SocketCANOptions.NoBufsRetryTimeout
+// is zero.
+const defaultNoBufsRetryTimeout = 100 * time.Millisecond
+
 // SocketCANOptions configures Linux SocketCAN behavior.
 // All fields are optional; zero value preserves kernel defaults.
 type SocketCANOptions struct {
@@ -28,10 +55,65 @@ type SocketCANOptions struct {
 	SendBufferBytes int
 	// ReceiveBufferBytes sets SO_RCVBUF if > 0.
 	ReceiveBufferBytes int
+	// NoBufsRetryTimeout bounds how long Send keeps retrying when write
+	// returns ENOBUFS, which is transient while the interface TX queue is
+	// full. Zero uses a 100ms default; a negative value returns ENOBUFS
+	// immediately. Raising the interface txqueuelen
+	// (ip link set can0 txqueuelen 1000) also reduces ENOBUFS under bursts.
+	NoBufsRetryTimeout time.Duration
+	// ErrorMask sets CAN_RAW_ERR_FILTER, the ErrorClass bits for which the
+	// kernel delivers error frames (Frame.Error), e.g. ErrorClassBusOff |
+	// ErrorClassController, or ErrorClassAll. If nil, the kernel default
+	// (no error frames) is preserved. The error mask is independent of
+	// Filters: data filters do not enable error frames, and both options are
+	// applied with separate setsockopt calls when set together.
+	ErrorMask *ErrorClass
+	// Timestamping enables SO_TIMESTAMPNS so Receive fills Frame.Timestamp
+	// with the kernel receive time. If nil or false, frames carry no
+	// timestamp and Receive uses plain reads.
+	Timestamping *bool
+	// Filters installs CAN_RAW_FILTER so the kernel drops frames matching
+	// none of the filters before they reach userspace. Empty keeps the
+	// kernel default of receiving everything.
+	Filters []CANFilter
+	// JoinFilters sets CAN_RAW_JOIN_FILTERS (Linux 4.1+): a frame is then
+	// delivered only if it matches all Filters, not any of them. Combined
+	// with Inverted filters this expresses selections such as "0x100-0x1FF
+	// except 0x180". It has no effect with fewer than two filters.
+	JoinFilters bool
+	// Filter selects received data frames. A KernelFilterSpec is compiled
+	// with CompileKernelFilter and installed as Filters; any other matcher,
+	// such as a FrameFilter, or a spec needing more than 512 kernel filters,
+	// is applied in userspace: the socket receives everything and Receive,
+	// ReceiveFrom and ReceiveBatch drop the frames Filter rejects. Error
+	// frames are not filtered. Filter cannot be combined with Filters or
+	// JoinFilters.
+	Filter FrameMatcher
 }
 
 // DialSocketCANWithOptions opens a raw CAN socket on iface and applies options.
+// An empty iface binds to all CAN interfaces (ifindex 0): the socket then
+// receives from every interface, and ReceiveFrom reports which one. Such a
+// socket cannot send, as the kernel has no interface to transmit on.
 func DialSocketCANWithOptions(iface string, opts *SocketCANOptions) (Bus, error) {
+	var kernelFilters []CANFilter
+	var userFilter FrameFilter
+	if opts != nil {
+		kernelFilters = opts.Filters
+		if opts.Filter != nil {
+			if len(opts.Filters) > 0 || opts.JoinFilters {
+				return nil, errors.New("canbus: SocketCANOptions.Filter cannot be combined with Filters or JoinFilters")
+			}
+			userFilter = opts.Filter.Match
+			// An empty translation (nothing matches) cannot be installed
+			// as Filters; leave it to userspace.
+			if spec, ok := opts.Filter.(KernelFilterSpec); ok {
+				if fs, ok := CompileKernelFilter(spec); ok && len(fs) > 0 {
+					kernelFilters, userFilter = fs, nil
+				}
+			}
+		}
+	}
 	// Create socket: AF_CAN, SOCK_RAW, CAN_RAW (protocol 1)
 	const AF_CAN = 29
 	const CAN_RAW = 1
@@ -44,6 +126,9 @@ func DialSocketCANWithOptions(iface string, opts *SocketCANOptions) (Bus, error)
 		const SOL_CAN_RAW = 101
 		const CAN_RAW_LOOPBACK = 3
 		const CAN_RAW_RECV_OWN_MSGS = 4
+		const CAN_RAW_FILTER = 1
+		const CAN_RAW_ERR_FILTER = 2
+		const CAN_RAW_JOIN_FILTERS = 6
 
 		if opts.Loopback != nil {
 			val := 0
@@ -65,6 +150,33 @@ func DialSocketCANWithOptions(iface string, opts *SocketCANOptions) (Bus, error)
 				return nil, err
 			}
 		}
+		if opts.JoinFilters {
+			if err := syscall.SetsockoptInt(fd, SOL_CAN_RAW, CAN_RAW_JOIN_FILTERS, 1); err != nil {
+				syscall.Close(fd)
+				return nil, err
+			}
+		}
+		if len(kernelFilters) > 0 {
+			buf := encodeCANFilters(kernelFilters)
+			_, _, e := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd), SOL_CAN_RAW, CAN_RAW_FILTER,
+				uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0)
+			if e != 0 {
+				syscall.Close(fd)
+				return nil, e
+			}
+		}
+		if opts.ErrorMask != nil {
+			if err := syscall.SetsockoptInt(fd, SOL_CAN_RAW, CAN_RAW_ERR_FILTER, int(*opts.ErrorMask&ErrorClassAll)); err != nil {
+				syscall.Close(fd)
+				return nil, err
+			}
+		}
+		if opts.Timestamping != nil && *opts.Timestamping {
+			if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_TIMESTAMPNS, 1); err != nil {
+				syscall.Close(fd)
+				return nil, err
+			}
+		}
 		if opts.SendBufferBytes > 0 {
 			if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF, opts.SendBufferBytes); err != nil {
 				syscall.Close(fd)
@@ -79,23 +191,20 @@ func DialSocketCANWithOptions(iface string, opts *SocketCANOptions) (Bus, error)
 		}
 	}
 
-	// Query interface index via net.InterfaceByName
-	netIf, err := net.InterfaceByName(iface)
-	if err != nil {
-		syscall.Close(fd)
-		return nil, err
+	// Query interface index via net.InterfaceByName; 0 binds to all.
+	var ifindex int
+	if iface != "" {
+		netIf, err := net.InterfaceByName(iface)
+		if err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+		ifindex = netIf.Index
 	}
 
 	// Bind to interface
-	// struct sockaddr_can { sa_family_t can_family; int can_ifindex; union { ... } addr; };
 	// We provide a compatible memory layout via unsafe and call bind(2) directly.
-	type sockaddrCAN struct {
-		Family  uint16
-		_pad    uint16
-		Ifindex int32
-		Addr    [8]byte
-	}
-	sa := sockaddrCAN{Family: AF_CAN, Ifindex: int32(netIf.Index)}
+	sa := sockaddrCAN{Family: AF_CAN, Ifindex: int32(ifindex)}
 	_, _, e := syscall.Syscall(syscall.SYS_BIND, uintptr(fd), uintptr(unsafe.Pointer(&sa)), unsafe.Sizeof(sa))
 	if e != 0 {
 		syscall.Close(fd)
@@ -108,8 +217,24 @@ func DialSocketCANWithOptions(iface string, opts *SocketCANOptions) (Bus, error)
 		return nil, err
 	}
 
+	noBufs := defaultNoBufsRetryTimeout
+	if opts != nil && opts.NoBufsRetryTimeout != 0 {
+		noBufs = opts.NoBufsRetryTimeout
+	}
+
+	timestamps := opts != nil && opts.Timestamping != nil && *opts.Timestamping
+
 	f := os.NewFile(uintptr(fd), "socketcan")
-	return &socketCAN{fd: fd, file: f, closed: make(chan struct{})}, nil
+	return &socketCAN{fd: fd, file: f, closed: make(chan struct{}), noBufsTimeout: noBufs, timestamps: timestamps, filter: userFilter}, nil
+}
+
+// sockaddrCAN mirrors struct sockaddr_can { sa_family_t can_family; int
+// can_ifindex; union { ... } can_addr; }.
+type sockaddrCAN struct {
+	Family  uint16
+	_pad    uint16
+	Ifindex int32
+	Addr    [16]byte
 }
 
 // DialSocketCAN opens a raw CAN socket bound to the given interface name (e.g., "can0").
@@ -137,6 +262,7 @@ func (s *socketCAN) Send(frame Frame) error {
 	if err != nil {
 		return err
 	}
+	var noBufsDeadline time.Time
 	for {
 		// Try write
 		n, werr := syscall.Write(s.fd, buf)
@@ -151,31 +277,237 @@ func (s *socketCAN) Send(frame Frame) error {
 			syscall.Select(0, nil, nil, nil, &syscall.Timeval{Usec: 1000})
 			continue
 		}
+		if werr == syscall.ENOBUFS && s.noBufsTimeout > 0 {
+			// TX queue full: treat like EAGAIN until the retry bound expires.
+			if noBufsDeadline.IsZero() {
+				noBufsDeadline = time.Now().Add(s.noBufsTimeout)
+			}
+			if time.Now().Before(noBufsDeadline) {
+				syscall.Select(0, nil, nil, nil, &syscall.Timeval{Usec: 1000})
+				continue
+			}
+		}
 		return werr
 	}
 }
 
-// Receive reads one frame (blocking respecting context).
+// rxBatch is the number of frames Receive pulls per recvmmsg call.
+const rxBatch = 16
+
+// Receive reads one frame (blocking respecting context). Frames are pulled
+// from the kernel in batches and served from an internal buffer.
 func (s *socketCAN) Receive() (Frame, error) {
-	var f Frame
-	buf := make([]byte, 16)
+	f, _, err := s.receiveOne()
+	return f, err
+}
+
+// ReceiveFrom implements InterfaceReceiver: it is like Receive but also
+// returns the name of the interface the frame arrived on, which is useful
+// for sockets bound to all interfaces.
+func (s *socketCAN) ReceiveFrom() (Frame, string, error) {
+	f, ifindex, err := s.receiveOne()
+	if err != nil {
+		return Frame{}, "", err
+	}
+	return f, s.ifaceName(ifindex), nil
+}
+
+// receiveOne returns the next buffered frame and its ifindex, refilling the
+// buffer with one recvmmsg call when empty.
+func (s *socketCAN) receiveOne() (Frame, int32, error) {
+	s.rxMu.Lock()
+	defer s.rxMu.Unlock()
 	for {
-		n, rerr := syscall.Read(s.fd, buf)
-		if rerr == nil {
-			if n != len(buf) {
-				return Frame{}, errors.New("canbus: short read")
+		if s.rxHead == s.rxCount {
+			if err := s.rxErr; err != nil {
+				s.rxErr = nil
+				return Frame{}, 0, err
+			}
+			n, err := s.recvmmsg(s.rxBuf[:], s.rxIf[:])
+			if n == 0 {
+				return Frame{}, 0, err
 			}
-			if err := f.UnmarshalBinary(buf); err != nil {
-				return Frame{}, err
+			s.rxHead, s.rxCount, s.rxErr = 0, n, err
+		}
+		i := s.rxHead
+		s.rxHead++
+		if s.accept(s.rxBuf[i]) {
+			return s.rxBuf[i], s.rxIf[i], nil
+		}
+	}
+}
+
+// accept applies the userspace fallback of SocketCANOptions.Filter.
+func (s *socketCAN) accept(f Frame) bool {
+	return s.filter == nil || f.Error || s.filter(f)
+}
+
+// ifaceName resolves and caches interface names by index.
+func (s *socketCAN) ifaceName(ifindex int32) string {
+	s.ifMu.Lock()
+	defer s.ifMu.Unlock()
+	if name, ok := s.ifNames[ifindex]; ok {
+		return name
+	}
+	var name string
+	if netIf, err := net.InterfaceByIndex(int(ifindex)); err == nil {
+		name = netIf.Name
+	}
+	if s.ifNames == nil {
+		s.ifNames = make(map[int32]string)
+	}
+	s.ifNames[ifindex] = name
+	return name
+}
+
+// ReceiveBatch implements BatchReceiver using recvmmsg(2): it blocks until
+// at least one frame is available and then fills frames with up to
+// len(frames) frames in a single syscall. Frames already buffered by Receive
+// are returned first.
+func (s *socketCAN) ReceiveBatch(frames []Frame) (int, error) {
+	if len(frames) == 0 {
+		return 0, nil
+	}
+	s.rxMu.Lock()
+	defer s.rxMu.Unlock()
+	for {
+		var n int
+		var err error
+		if s.rxHead < s.rxCount {
+			n = copy(frames, s.rxBuf[s.rxHead:s.rxCount])
+			s.rxHead += n
+		} else if err = s.rxErr; err != nil {
+			s.rxErr = nil
+			return 0, err
+		} else {
+			n, err = s.recvmmsg(frames, nil)
+		}
+		if s.filter == nil {
+			return n, err
+		}
+		kept := 0
+		for _, f := range frames[:n] {
+			if s.accept(f) {
+				frames[kept] = f
+				kept++
 			}
-			return f, nil
 		}
-		if rerr == syscall.EAGAIN || rerr == syscall.EWOULDBLOCK {
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// mmsghdr mirrors struct mmsghdr; Go's struct padding matches the C layout.
+type mmsghdr struct {
+	Hdr syscall.Msghdr
+	Len uint32
+}
+
+// recvmmsg reads up to len(frames) frames in one syscall, retrying while
+// none are available. If ifindex is non-nil, the source interface index of
+// each frame is stored there. If a frame fails to decode, the frames before
+// it are returned together with the error. recvmmsg(2) is invoked directly
+// because the syscall package has no wrapper and cannot decode AF_CAN
+// addresses.
+func (s *socketCAN) recvmmsg(frames []Frame, ifindex []int32) (int, error) {
+	bufs := make([]byte, canMTU*len(frames))
+	iovs := make([]syscall.Iovec, len(frames))
+	msgs := make([]mmsghdr, len(frames))
+	var names []sockaddrCAN
+	if ifindex != nil {
+		names = make([]sockaddrCAN, len(frames))
+	}
+	var oob []byte
+	oobLen := syscall.CmsgSpace(int(unsafe.Sizeof(syscall.Timespec{})))
+	if s.timestamps {
+		oob = make([]byte, oobLen*len(frames))
+	}
+	for i := range msgs {
+		iovs[i].Base = &bufs[i*canMTU]
+		iovs[i].SetLen(canMTU)
+		msgs[i].Hdr.Iov = &iovs[i]
+		msgs[i].Hdr.Iovlen = 1
+		if names != nil {
+			msgs[i].Hdr.Name = (*byte)(unsafe.Pointer(&names[i]))
+			msgs[i].Hdr.Namelen = uint32(unsafe.Sizeof(names[i]))
+		}
+		if s.timestamps {
+			msgs[i].Hdr.Control = &oob[i*oobLen]
+			msgs[i].Hdr.SetControllen(oobLen)
+		}
+	}
+	for {
+		r, _, e := syscall.Syscall6(syscall.SYS_RECVMMSG, uintptr(s.fd), uintptr(unsafe.Pointer(&msgs[0])), uintptr(len(msgs)), 0, 0, 0)
+		if e == syscall.EAGAIN || e == syscall.EWOULDBLOCK {
 			syscall.Select(0, nil, nil, nil, &syscall.Timeval{Usec: 1000})
 			continue
 		}
-		return Frame{}, rerr
+		if e != 0 {
+			return 0, e
+		}
+		n := int(r)
+		for i := 0; i < n; i++ {
+			if msgs[i].Len != canMTU {
+				return i, errors.New("canbus: short read")
+			}
+			var f Frame
+			if err := f.UnmarshalBinary(bufs[i*canMTU : (i+1)*canMTU]); err != nil {
+				return i, err
+			}
+			if s.timestamps {
+				f.Timestamp = parseTimestampNS(oob[i*oobLen : i*oobLen+int(msgs[i].Hdr.Controllen)])
+			}
+			frames[i] = f
+			if names != nil {
+				ifindex[i] = names[i].Ifindex
+			}
+		}
+		return n, nil
+	}
+}
+
+// parseTimestampNS returns the SO_TIMESTAMPNS time carried in a control
+// message buffer, or the zero time if there is none.
+func parseTimestampNS(oob []byte) time.Time {
+	cmsgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}
+	}
+	for _, m := range cmsgs {
+		if m.Header.Level == syscall.SOL_SOCKET && m.Header.Type == syscall.SO_TIMESTAMPNS &&
+			len(m.Data) >= int(unsafe.Sizeof(syscall.Timespec{})) {
+			t := (*syscall.Timespec)(unsafe.Pointer(&m.Data[0]))
+			return time.Unix(t.Unix())
+		}
+	}
+	return time.Time{}
+}
+
+// encodeCANFilters lays out filters as an array of struct can_filter
+// { canid_t can_id; canid_t can_mask; } in host byte order. The EFF flag is
+// always part of the mask so standard and extended frames never alias.
+func encodeCANFilters(filters []CANFilter) []byte {
+	const (
+		canEffFlag   = 0x80000000
+		canInvFilter = 0x20000000
+		canEffMask   = 0x1FFFFFFF
+		canStdMask   = 0x7FF
+	)
+	buf := make([]byte, 8*len(filters))
+	for i, c := range filters {
+		id, mask := c.ID&canStdMask, c.Mask&canStdMask
+		if c.Extended {
+			id, mask = c.ID&canEffMask|canEffFlag, c.Mask&canEffMask
+		}
+		mask |= canEffFlag
+		if c.Inverted {
+			id |= canInvFilter
+		}
+		*(*uint32)(unsafe.Pointer(&buf[8*i])) = id
+		*(*uint32)(unsafe.Pointer(&buf[8*i+4])) = mask
 	}
+	return buf
 }
 
 // Helpers for FD sets since x/sys is not allowed.
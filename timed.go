@@ -0,0 +1,64 @@
+package canbus
+
+import "time"
+
+// TimedFrame pairs a Frame with the time it was observed, as recorded by
+// capture tooling. Time is set by the capturer and need not match
+// Frame.Timestamp, the driver's receive time, which is often unset.
+type TimedFrame struct {
+	Frame Frame
+	Time  time.Time
+}
+
+// TimedFilter decides whether a timestamped frame should be selected.
+type TimedFilter func(TimedFrame) bool
+
+// WithinWindow matches frames observed between start and end, inclusive.
+func WithinWindow(start, end time.Time) TimedFilter {
+	if end.Before(start) {
+		start, end = end, start
+	}
+	return func(tf TimedFrame) bool {
+		return !tf.Time.Before(start) && !tf.Time.After(end)
+	}
+}
+
+// Around matches frames observed within d before or after t, e.g. the
+// traffic surrounding a trigger frame.
+func Around(t time.Time, d time.Duration) TimedFilter {
+	return WithinWindow(t.Add(-d), t.Add(d))
+}
+
+// Timed adapts a FrameFilter to match on the frame of a TimedFrame, so it
+// can be combined with time predicates via AndTimed.
+func Timed(filter FrameFilter) TimedFilter {
+	if filter == nil {
+		return nil
+	}
+	return func(tf TimedFrame) bool { return filter(tf.Frame) }
+}
+
+// AndTimed composes two timed filters; the result matches when both match.
+// A nil filter matches everything.
+func AndTimed(a, b TimedFilter) TimedFilter {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	default:
+		return func(tf TimedFrame) bool { return a(tf) && b(tf) }
+	}
+}
+
+// SelectTimed returns the frames of a recording matching filter, in order.
+// A nil filter selects every frame.
+func SelectTimed(frames []TimedFrame, filter TimedFilter) []TimedFrame {
+	var out []TimedFrame
+	for _, tf := range frames {
+		if filter == nil || filter(tf) {
+			out = append(out, tf)
+		}
+	}
+	return out
+}